@@ -1,24 +1,12 @@
 // Package agent contains implementation for metric agent.
 //
-// The agent collects counter and gauge metrics to storage and sends their to server with interval.
+// The agent collects counter and gauge metrics to storage and sends their to servers with interval.
 package agent
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"crypto/hmac"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
-	"encoding/json"
-	"encoding/pem"
-	"fmt"
 	"log"
 	math "math/rand"
-	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -26,10 +14,17 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/mem"
 
+	"github.com/kaa-it/go-devops/internal/agent/push"
+	"github.com/kaa-it/go-devops/internal/agent/rules"
+	"github.com/kaa-it/go-devops/internal/agent/scrape"
+	"github.com/kaa-it/go-devops/internal/agent/script"
+	"github.com/kaa-it/go-devops/internal/agent/statsd"
+	"github.com/kaa-it/go-devops/internal/agent/status"
+	"github.com/kaa-it/go-devops/internal/agent/tail"
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
 	"github.com/kaa-it/go-devops/internal/api"
 )
 
@@ -39,46 +34,38 @@ const (
 
 // Agent describes metric agent.
 type Agent struct {
-	storage   *Storage
-	config    *Config
-	client    *resty.Client
-	publicKey *rsa.PublicKey
+	storage      *Storage
+	config       *Config
+	destinations []*destination
+	rules        *rules.Processor
+	clock        Clock
+	state        *state
+
+	// reloadConfig creates new configuration on SIGHUP
+	reloadConfig func(current *Config) (*Config, error)
 }
 
 // New creates new metric agent
 //
-// Takes client to connect to metric server and config with total configuration of agent.
-func New(client *resty.Client, config *Config) (*Agent, error) {
-	client.SetTimeout(_requestTimeout)
-
-	var publicKey *rsa.PublicKey
-
-	if config.Agent.PublicKeyPath != "" {
-		publicKeyPEM, err := os.ReadFile(config.Agent.PublicKeyPath)
-		if err != nil {
-			return nil, err
-		}
-
-		publicKeyBlock, _ := pem.Decode(publicKeyPEM)
-		pub, err := x509.ParsePKIXPublicKey(publicKeyBlock.Bytes)
-		if err != nil {
-			return nil, err
-		}
-
-		pubKey, ok := pub.(*rsa.PublicKey)
-		if !ok {
-			return nil, fmt.Errorf("wrong key format")
-		}
+// Takes config with total configuration of agent.
+func New(config *Config) (*Agent, error) {
+	a := &Agent{
+		storage:      NewStorage(),
+		config:       config,
+		rules:        rules.New(&config.Rules),
+		clock:        realClock{},
+		state:        newState(),
+		reloadConfig: (*Config).Reload,
+	}
 
-		publicKey = pubKey
+	destinations, err := newDestinations(config, a.storage, a.clock, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Agent{
-		storage:   NewStorage(),
-		config:    config,
-		client:    client,
-		publicKey: publicKey,
-	}, nil
+	a.destinations = destinations
+
+	return a, nil
 }
 
 // Run runs metric agent and control its lifecycle.
@@ -88,58 +75,238 @@ func (a *Agent) Run() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	wg := new(sync.WaitGroup)
-	wg.Add(3)
+	go func() {
+		<-c
+		cancel()
+	}()
 
-	go a.runPoller(ctx, wg)
-	go a.runAdditionalPoller(ctx, wg)
-	go a.runReporter(ctx, wg)
+	a.run(ctx, reload)
 
-	<-c
+	log.Println("Agent terminated")
+}
 
-	cancel()
+// run runs all agent goroutines until context is cancelled.
+//
+// Every signal from reload channel makes agent reload configuration.
+//
+// After cancellation agent stops collectors, sends queued batches, polls metrics the last time
+// and sends final report. Shutdown takes no longer than shutdown timeout,
+// metrics that could not be sent in time are saved to spool and restored on the next start.
+func (a *Agent) run(ctx context.Context, reload <-chan os.Signal) {
+	a.restoreSpool()
 
-	wg.Wait()
+	// Requests are not bound to ctx, so queued batches are sent after cancellation
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
 
-	log.Println("Agent terminated")
+	workers := new(sync.WaitGroup)
+	a.startWorkers(sendCtx, workers, a.destinations)
+
+	collectorsCtx, cancelCollectors := context.WithCancel(ctx)
+	collectors := a.startCollectors(collectorsCtx)
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-reload:
+			config, destinations, err := a.prepareReload()
+			if err != nil {
+				log.Printf("new configuration is rejected, the old one is kept: %s", err)
+				continue
+			}
+
+			// Collectors are restarted with new settings, collected metrics stay in storage
+			cancelCollectors()
+			collectors.Wait()
+
+			for _, d := range a.applyReload(config, destinations) {
+				workers.Add(1)
+				go d.retire(sendCtx, workers, a.config.Agent.ShutdownTimeout)
+			}
+
+			a.startWorkers(sendCtx, workers, destinations)
+
+			collectorsCtx, cancelCollectors = context.WithCancel(ctx)
+			collectors = a.startCollectors(collectorsCtx)
+
+			log.Println("Configuration reloaded")
+		}
+	}
+
+	cancelCollectors()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), a.config.Agent.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Abort requests of workers when shutdown time is over
+	stop := context.AfterFunc(shutdownCtx, cancelSend)
+	defer stop()
+
+	collectors.Wait()
+
+	for _, d := range a.destinations {
+		close(d.jobs)
+	}
+
+	workers.Wait()
+
+	a.flush(shutdownCtx)
+}
+
+// startCollectors starts pollers, reporter, collectors and status endpoint configured for agent.
+//
+// Returned wait group is done when all of them stop after context cancellation.
+func (a *Agent) startCollectors(ctx context.Context) *sync.WaitGroup {
+	collectors := new(sync.WaitGroup)
+	collectors.Add(3)
+
+	go a.runPoller(ctx, collectors)
+	go a.runAdditionalPoller(ctx, collectors)
+	go a.runReporter(ctx, collectors)
+
+	if a.config.StatsD.Enabled() {
+		collectors.Add(1)
+		go a.runStatsD(ctx, collectors)
+	}
+
+	if a.config.Push.Address != "" {
+		collectors.Add(1)
+		go a.runPush(ctx, collectors)
+	}
+
+	for i := range a.config.Scripts {
+		collectors.Add(1)
+		go a.runScript(ctx, collectors, &a.config.Scripts[i])
+	}
+
+	for i := range a.config.Scrape {
+		collectors.Add(1)
+		go a.runScrape(ctx, collectors, &a.config.Scrape[i])
+	}
+
+	if len(a.config.Tail.Files) > 0 {
+		collectors.Add(1)
+		go a.runTail(ctx, collectors)
+	}
+
+	if a.config.Status.Address != "" {
+		collectors.Add(1)
+		go a.runStatus(ctx, collectors)
+	}
+
+	return collectors
+}
+
+// startWorkers starts workers for every destination.
+func (a *Agent) startWorkers(ctx context.Context, wg *sync.WaitGroup, destinations []*destination) {
+	for _, d := range destinations {
+		wg.Add(cap(d.jobs))
+
+		d.workers.Add(cap(d.jobs))
+
+		for i := 0; i < cap(d.jobs); i++ {
+			go func() {
+				defer d.workers.Done()
+
+				d.runWorker(ctx, wg)
+			}()
+		}
+	}
+}
+
+// prepareReload creates and validates new configuration and destinations for it.
+//
+// Nothing in agent is changed, so invalid configuration can be rejected keeping the old one.
+func (a *Agent) prepareReload() (*Config, []*destination, error) {
+	config, err := a.reloadConfig(a.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destinations, err := newDestinations(config, a.storage, a.clock, a.destinations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, destinations, nil
+}
+
+// applyReload replaces configuration and destinations of agent and returns removed destinations.
+//
+// Must be called while collectors are stopped. Workers of old destinations send queued batches and exit,
+// counters of their failed batches are kept by new destinations with the same name.
+func (a *Agent) applyReload(config *Config, destinations []*destination) []*destination {
+	old := a.destinations
+
+	a.config = config
+	a.rules = rules.New(&config.Rules)
+	a.destinations = destinations
+
+	names := make(map[string]struct{}, len(destinations))
+	for _, d := range destinations {
+		names[d.config.Name] = struct{}{}
+	}
+
+	var removed []*destination
+
+	for _, d := range old {
+		close(d.jobs)
+
+		if _, ok := names[d.config.Name]; !ok {
+			removed = append(removed, d)
+		}
+	}
+
+	return removed
 }
 
 func (a *Agent) runPoller(ctx context.Context, wg *sync.WaitGroup) {
-	pollTicker := time.NewTicker(a.config.Agent.PollInterval)
+	pollTicker := a.clock.NewTicker(a.config.Agent.PollInterval)
 	defer pollTicker.Stop()
 
+	a.state.setCollector("runtime", status.Running, nil)
+
 	for {
 		select {
 		case <-ctx.Done():
+			a.state.setCollector("runtime", status.Stopped, nil)
 			log.Println("Poller terminated")
 			wg.Done()
 			return
-		case <-pollTicker.C:
+		case <-pollTicker.C():
 			a.poll()
 		}
 	}
 }
 
 func (a *Agent) runAdditionalPoller(ctx context.Context, wg *sync.WaitGroup) {
-	pollTicker := time.NewTicker(a.config.Agent.PollInterval)
+	pollTicker := a.clock.NewTicker(a.config.Agent.PollInterval)
 	defer pollTicker.Stop()
 
+	a.state.setCollector("system", status.Running, nil)
+
 	for {
 		select {
 		case <-ctx.Done():
+			a.state.setCollector("system", status.Stopped, nil)
 			log.Println("Additional poller terminated")
 			wg.Done()
 			return
-		case <-pollTicker.C:
+		case <-pollTicker.C():
 			a.additionalPoll()
 		}
 	}
 }
 
+// runReporter produces batches of metrics for destinations with report interval.
 func (a *Agent) runReporter(ctx context.Context, wg *sync.WaitGroup) {
-	reportTicker := time.NewTicker(a.config.Agent.ReportInterval)
+	reportTicker := a.clock.NewTicker(a.config.Agent.ReportInterval)
 	defer reportTicker.Stop()
 
 	for {
@@ -148,13 +315,81 @@ func (a *Agent) runReporter(ctx context.Context, wg *sync.WaitGroup) {
 			log.Println("Reporter terminated")
 			wg.Done()
 			return
-		case <-reportTicker.C:
+		case <-reportTicker.C():
 			a.report()
 		}
 	}
 }
 
+func (a *Agent) runStatsD(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	a.state.setCollector("statsd", status.Running, nil)
+
+	listener := statsd.New(&a.config.StatsD, a.storage)
+
+	err := listener.Run(ctx)
+	if err != nil {
+		log.Printf("StatsD listener failed: %s", err)
+	}
+
+	a.state.setCollector("statsd", status.Stopped, err)
+}
+
+func (a *Agent) runPush(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	a.state.setCollector("push", status.Running, nil)
+
+	server := push.New(&a.config.Push, a.storage)
+
+	err := server.Run(ctx)
+	if err != nil {
+		log.Printf("push endpoint failed: %s", err)
+	}
+
+	a.state.setCollector("push", status.Stopped, err)
+}
+
+func (a *Agent) runScript(ctx context.Context, wg *sync.WaitGroup, config *script.Config) {
+	defer wg.Done()
+
+	name := "script." + config.Name
+
+	a.state.setCollector(name, status.Running, nil)
+	script.New(config, a.storage).Run(ctx)
+	a.state.setCollector(name, status.Stopped, nil)
+}
+
+func (a *Agent) runScrape(ctx context.Context, wg *sync.WaitGroup, target *scrape.Target) {
+	defer wg.Done()
+
+	name := "scrape." + target.Name
+
+	a.state.setCollector(name, status.Running, nil)
+	scrape.New(target, a.storage).Run(ctx)
+	a.state.setCollector(name, status.Stopped, nil)
+}
+
+func (a *Agent) runTail(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	a.state.setCollector("tail", status.Running, nil)
+	tail.New(&a.config.Tail, a.storage).Run(ctx)
+	a.state.setCollector("tail", status.Stopped, nil)
+}
+
+func (a *Agent) runStatus(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := status.New(&a.config.Status, a).Run(ctx); err != nil {
+		log.Printf("status endpoint failed: %s", err)
+	}
+}
+
 func (a *Agent) poll() {
+	start := time.Now()
+
 	stats := &runtime.MemStats{}
 
 	runtime.ReadMemStats(stats)
@@ -190,10 +425,14 @@ func (a *Agent) poll() {
 	a.storage.UpdateCounter("PollCount", 1)
 	a.storage.UpdateGauge("RandomValue", math.Float64())
 
+	a.storage.UpdateGauge(telemetry.PollDuration("runtime"), time.Since(start).Seconds())
+
 	log.Println("Poll done")
 }
 
 func (a *Agent) additionalPoll() {
+	start := time.Now()
+
 	v, _ := mem.VirtualMemory()
 	cpu, _ := cpu.Percent(0, false)
 
@@ -201,10 +440,24 @@ func (a *Agent) additionalPoll() {
 	a.storage.UpdateGauge("FreeMemory", float64(v.Free))
 	a.storage.UpdateGauge("CPUutilization1", cpu[0])
 
+	a.storage.UpdateGauge(telemetry.PollDuration("system"), time.Since(start).Seconds())
+
 	log.Println("Additional poll done")
 }
 
+// report queues batch of collected metrics for workers of every destination.
 func (a *Agent) report() {
+	metrics := a.describe(a.rules.Apply(describeRuntime(a.collect())))
+
+	for _, d := range a.destinations {
+		d.enqueue(metrics)
+	}
+}
+
+// collect returns batch with all metrics from storage.
+//
+// Collected counter values are subtracted from storage.
+func (a *Agent) collect() []api.Metrics {
 	var metrics []api.Metrics
 	a.storage.ForEachGauge(func(key string, value float64) {
 		metrics = a.applyGauge(key, value, metrics)
@@ -218,99 +471,35 @@ func (a *Agent) report() {
 		a.storage.UpdateCounter(key, -value)
 	})
 
-	if err := a.sendMetrics(metrics); err != nil {
-		log.Println(err)
-		return
-	}
-
-	log.Println("Report done")
+	return metrics
 }
 
-func (a *Agent) sendMetrics(metrics []api.Metrics) error {
-	req := a.client.R()
-	req.Method = http.MethodPost
+// flush polls metrics the last time and sends all metrics left in storage to every destination.
+func (a *Agent) flush(ctx context.Context) {
+	a.poll()
+	a.additionalPoll()
 
-	url := fmt.Sprintf("http://%s/updates/", a.config.Server.Address)
+	metrics := a.describe(a.rules.Apply(describeRuntime(a.collect())))
 
-	req.URL = url
-
-	if a.publicKey != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	req.Header.Set("Content-Encoding", "gzip")
-
-	buf := bytes.NewBuffer(nil)
-	zw := gzip.NewWriter(buf)
-
-	enc := json.NewEncoder(zw)
-	if err := enc.Encode(metrics); err != nil {
-		return fmt.Errorf("failed to encode metric for %s: %w", url, err)
-	}
-
-	if err := zw.Close(); err != nil {
-		return fmt.Errorf("failed to close gzip writer: %w", err)
-	}
-
-	var body []byte
-
-	if a.publicKey == nil {
-		body = buf.Bytes()
-	} else {
-		var err error
-		body, err = a.encrypt(buf)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt message: %w", err)
-		}
-	}
+	wg := new(sync.WaitGroup)
+	wg.Add(len(a.destinations))
 
-	req.SetBody(body)
+	for _, d := range a.destinations {
+		go func() {
+			defer wg.Done()
 
-	if len(a.config.Agent.Key) > 0 {
-		req.Header.Set("Hash", a.calculateHash(body))
-	}
-
-	resp, err := req.Send()
-	if err != nil {
-		return fmt.Errorf("failed to send request for %s: %w", url, err)
+			d.flush(ctx, metrics)
+		}()
 	}
 
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("received status code %d for %s", resp.StatusCode(), url)
-	}
-
-	return nil
+	wg.Wait()
 }
 
-func (a *Agent) encrypt(buf *bytes.Buffer) ([]byte, error) {
-	encrypted := make([]byte, 0, buf.Len())
-
-	step := a.publicKey.Size() - 11
-	total := buf.Len()
-	msg := buf.Bytes()
-
-	for start := 0; start < total; start += step {
-		finish := start + step
-		if finish > total {
-			finish = total
-		}
-
-		cipher, err := rsa.EncryptPKCS1v15(rand.Reader, a.publicKey, msg[start:finish])
-		if err != nil {
-			return nil, err
-		}
-
-		encrypted = append(encrypted, cipher...)
+// restoreSpool restores metrics saved by previous run for every destination.
+func (a *Agent) restoreSpool() {
+	for _, d := range a.destinations {
+		d.restoreSpool()
 	}
-
-	return encrypted, nil
-}
-
-func (a *Agent) calculateHash(msg []byte) string {
-	h := hmac.New(sha256.New, []byte(a.config.Agent.Key))
-	h.Write(msg)
-
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
 func (a *Agent) applyGauge(name string, value float64, metrics []api.Metrics) []api.Metrics {
@@ -0,0 +1,62 @@
+// Package agenttest contains helpers for tests of agent collectors.
+package agenttest
+
+import "sync"
+
+// Storage is in-memory storage for collectors under test.
+//
+// Gauges and Counters may be read directly after collector is stopped,
+// Gauge and Counter should be used while collector is running.
+type Storage struct {
+	mu       sync.Mutex
+	Gauges   map[string]float64
+	Counters map[string]int64
+}
+
+// NewStorage creates empty storage.
+func NewStorage() *Storage {
+	return &Storage{
+		Gauges:   make(map[string]float64),
+		Counters: make(map[string]int64),
+	}
+}
+
+// UpdateGauge sets value of gauge metric.
+func (s *Storage) UpdateGauge(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Gauges[name] = value
+}
+
+// AddGauge adds delta to value of gauge metric.
+func (s *Storage) AddGauge(name string, delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Gauges[name] += delta
+}
+
+// UpdateCounter adds value to counter metric.
+func (s *Storage) UpdateCounter(name string, value int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Counters[name] += value
+}
+
+// Gauge returns value of gauge metric.
+func (s *Storage) Gauge(name string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Gauges[name]
+}
+
+// Counter returns value of counter metric.
+func (s *Storage) Counter(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Counters[name]
+}
@@ -0,0 +1,139 @@
+// Package breaker contains circuit breaker that stops agent reports after repeated failures.
+//
+// Breaker opens after threshold of consecutive failures and rejects requests during cool-down.
+// After cool-down it lets one probe request through: its success closes breaker,
+// its failure opens breaker for the next cool-down.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned when request is rejected by open breaker.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State describes state of breaker.
+type State int
+
+// Breaker states
+const (
+	Closed   State = iota // requests are allowed
+	Open                  // requests are rejected
+	HalfOpen              // one probe request is allowed
+)
+
+// String returns name of state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker describes circuit breaker.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New creates new closed breaker.
+//
+// threshold - amount of consecutive failures to open breaker, zero disables breaker.
+// cooldown - duration of rejecting requests after breaker opens.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return NewWithClock(threshold, cooldown, time.Now)
+}
+
+// NewWithClock creates new closed breaker that uses given function to get current time.
+func NewWithClock(threshold int, cooldown time.Duration, now func() time.Time) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+	}
+}
+
+// Allow reports whether request may be sent.
+//
+// Every allowed request must be followed by Success or Failure call.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+
+		b.state = HalfOpen
+		b.probing = true
+
+		return true
+	case HalfOpen:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records successful request and closes breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Closed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records failed request and opens breaker if threshold is reached or probe failed.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+}
+
+// State returns current state of breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+
+	return b.state
+}
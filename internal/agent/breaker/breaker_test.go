@@ -0,0 +1,51 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	b := NewWithClock(2, time.Minute, func() time.Time { return now })
+
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, Closed, b.State())
+
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, Open, b.State())
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+
+	assert.Equal(t, HalfOpen, b.State())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow(), "only one probe is allowed")
+
+	b.Failure()
+	assert.Equal(t, Open, b.State())
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, Closed, b.State())
+	assert.True(t, b.Allow())
+}
+
+func TestBreaker_disabled(t *testing.T) {
+	b := New(0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, b.Allow())
+		b.Failure()
+	}
+
+	assert.Equal(t, Closed, b.State())
+}
@@ -0,0 +1,41 @@
+package agent
+
+import "time"
+
+// Ticker describes ticker that delivers ticks with interval.
+type Ticker interface {
+	// C returns channel for ticks.
+	C() <-chan time.Time
+	// Stop turns off ticker.
+	Stop()
+}
+
+// Clock describes source of time and tickers for agent.
+type Clock interface {
+	// Now returns current time.
+	Now() time.Time
+	// NewTicker creates ticker with given interval.
+	NewTicker(d time.Duration) Ticker
+}
+
+type realClock struct{}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{time.NewTicker(d)}
+}
+
+func (r *realTicker) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r *realTicker) Stop() {
+	r.t.Stop()
+}
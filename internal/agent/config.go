@@ -1,31 +1,232 @@
 package agent
 
 import (
-	"encoding/json"
+	"errors"
 	"flag"
-	"os"
-	"strconv"
+	"fmt"
 	"time"
+
+	"github.com/kaa-it/go-devops/internal/agent/push"
+	"github.com/kaa-it/go-devops/internal/agent/retry"
+	"github.com/kaa-it/go-devops/internal/agent/rules"
+	"github.com/kaa-it/go-devops/internal/agent/scrape"
+	"github.com/kaa-it/go-devops/internal/agent/script"
+	"github.com/kaa-it/go-devops/internal/agent/statsd"
+	"github.com/kaa-it/go-devops/internal/agent/status"
+	"github.com/kaa-it/go-devops/internal/agent/tail"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+const (
+	_pollInterval     = 2 * time.Second
+	_reportInterval   = 10 * time.Second
+	_serverAddress    = "localhost:8080"
+	_pushLimit        = 1000
+	_rateLimit        = 1
+	_retryCount       = 3
+	_retryWait        = time.Second
+	_retryMaxWait     = 5 * time.Second
+	_breakerThreshold = 5
+	_breakerCooldown  = 30 * time.Second
+	_shutdownTimeout  = 5 * time.Second
+	_spoolPath        = "/tmp/metrics-agent-spool.json"
 )
 
+// Transports for sending reports
 const (
-	_pollIntervalInSecs   = 2
-	_reportIntervalInSecs = 10
-	_serverAddress        = "localhost:8080"
+	HTTPTransport  = "http"
+	HTTPSTransport = "https"
 )
 
+// ErrInvalidDestination is returned for invalid configuration of destination.
+var ErrInvalidDestination = errors.New("invalid destination configuration")
+
+// configFile describes configuration file, keys are read by config package from json tags.
 type configFile struct {
-	PollInterval   int    `json:"poll_interval"`
-	ReportInterval int    `json:"report_interval"`
-	Address        string `json:"address"`
-	Key            string `json:"key"`
-	PublicKeyPath  string `json:"crypto_key"`
+	PollInterval     time.Duration `json:"poll_interval"`
+	ReportInterval   time.Duration `json:"report_interval"`
+	Address          string        `json:"address"`
+	Transport        string        `json:"transport"`
+	CACertPath       string        `json:"ca_cert"`
+	Key              string        `json:"key"`
+	PublicKeyPath    string        `json:"crypto_key"`
+	RateLimit        int           `json:"rate_limit"`
+	RetryCount       int           `json:"retry_count"`
+	RetryWait        time.Duration `json:"retry_wait"`
+	RetryMaxWait     time.Duration `json:"retry_max_wait"`
+	BreakerThreshold int           `json:"breaker_threshold"`
+	BreakerCooldown  time.Duration `json:"breaker_cooldown"`
+	ShutdownTimeout  time.Duration `json:"shutdown_timeout"`
+	SpoolPath        string        `json:"spool_path"`
+	StatsDAddress    string        `json:"statsd_address"`
+	StatsDSocket     string        `json:"statsd_socket"`
+	PushAddress      string        `json:"push_address"`
+	PushLimit        int           `json:"push_limit"`
+	StatusAddress    string        `json:"status_address"`
+	Scripts          []scriptFile  `json:"scripts"`
+	Tail             []tailFile    `json:"tail"`
+	TailState        string        `json:"tail_state"`
+	Scrape           []scrapeFile  `json:"scrape"`
+
+	Destinations []destinationFile       `json:"destinations"`
+	Rules        []ruleFile              `json:"rules"`
+	Metadata     map[string]metadataFile `json:"metadata"`
+}
+
+// metadataFile describes metadata of metric sent to server with reports.
+type metadataFile struct {
+	Unit        string `json:"unit"`
+	Description string `json:"description"`
+	Owner       string `json:"owner"`
+	Type        string `json:"type"`
+}
+
+type ruleFile struct {
+	Action       string            `json:"action"`
+	Name         string            `json:"name"`
+	Pattern      string            `json:"pattern"`
+	Type         string            `json:"type"`
+	To           string            `json:"to"`
+	Prefix       string            `json:"prefix"`
+	Conversion   string            `json:"convert"`
+	Factor       float64           `json:"factor"`
+	Unit         string            `json:"unit"`
+	Labels       map[string]string `json:"labels"`
+	Label        string            `json:"label"`
+	LabelPattern string            `json:"label_pattern"`
+	Replacement  string            `json:"replacement"`
+}
+
+// destinationFile describes additional destination, absent fields are taken from main destination.
+type destinationFile struct {
+	Name             string         `json:"name"`
+	Address          string         `json:"address"`
+	Transport        *string        `json:"transport"`
+	CACertPath       *string        `json:"ca_cert"`
+	Key              *string        `json:"key"`
+	PublicKeyPath    *string        `json:"crypto_key"`
+	RetryCount       *int           `json:"retry_count"`
+	RetryWait        *time.Duration `json:"retry_wait"`
+	RetryMaxWait     *time.Duration `json:"retry_max_wait"`
+	BreakerThreshold *int           `json:"breaker_threshold"`
+	BreakerCooldown  *time.Duration `json:"breaker_cooldown"`
+}
+
+type scriptFile struct {
+	Name     string        `json:"name"`
+	Command  string        `json:"command"`
+	Args     []string      `json:"args"`
+	Interval time.Duration `json:"interval"`
+	Timeout  time.Duration `json:"timeout"`
+	Format   string        `json:"format"`
 }
 
-// ServerConfig contains configuration if metric server
-type ServerConfig struct {
+type scrapeFile struct {
+	Name     string        `json:"name"`
+	URL      string        `json:"url"`
+	Prefix   string        `json:"prefix"`
+	Interval time.Duration `json:"interval"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+type tailFile struct {
+	Path  string     `json:"path"`
+	Rules []tailRule `json:"rules"`
+}
+
+type tailRule struct {
+	Pattern string `json:"pattern"`
+	Metric  string `json:"metric"`
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+}
+
+// configFlags contains values of command line flags.
+type configFlags struct {
+	address        string
+	reportInterval int
+	pollInterval   int
+	key            string
+	publicKeyPath  string
+	rateLimit      int
+	statsDAddress  string
+	statsDSocket   string
+	pushAddress    string
+	pushLimit      int
+	statusAddress  string
+	configPath     string
+}
+
+// DestinationConfig contains configuration of metric server that receives reports.
+type DestinationConfig struct {
+	// Name - unique name of destination, empty for main destination.
+	Name string
 	// Address - address of metric server.
 	Address string
+	// Transport - protocol for sending reports, http or https.
+	Transport string
+	// CACertPath - path to file with CA certificate to verify server, system pool is used if empty.
+	CACertPath string
+	// Key - cryptographic hash to encoding reports.
+	Key string
+	// PublicKeyPath - path to file with public RSA key to encrypt requests.
+	PublicKeyPath string
+	// Retry - policy for retrying failed requests to server.
+	Retry retry.Policy
+	// BreakerThreshold - amount of consecutive failed reports to stop sending, zero disables breaker.
+	BreakerThreshold int
+	// BreakerCooldown - duration of pause in sending after failed reports.
+	BreakerCooldown time.Duration
+}
+
+// Validate checks configuration of destination.
+//
+// Returned error is config.KeyError naming invalid key.
+func (c *DestinationConfig) Validate() error {
+	if c.Address == "" {
+		return invalid("address", "empty address")
+	}
+
+	if c.Transport != HTTPTransport && c.Transport != HTTPSTransport {
+		return invalid("transport", "unsupported transport %q", c.Transport)
+	}
+
+	if c.CACertPath != "" && c.Transport != HTTPSTransport {
+		return invalid("ca_cert", "CA certificate requires https transport")
+	}
+
+	if c.Retry.MaxRetries < 0 {
+		return invalid("retry_count", "retry count must not be negative")
+	}
+
+	if c.Retry.BaseDelay <= 0 {
+		return invalid("retry_wait", "retry delay must be positive")
+	}
+
+	if c.Retry.MaxDelay <= 0 {
+		return invalid("retry_max_wait", "maximal retry delay must be positive")
+	}
+
+	if c.BreakerThreshold <= 0 {
+		return invalid("breaker_threshold", "breaker threshold must be positive")
+	}
+
+	if c.BreakerCooldown <= 0 {
+		return invalid("breaker_cooldown", "breaker cooldown must be positive")
+	}
+
+	return nil
+}
+
+// invalid returns error of destination configuration key.
+func invalid(key string, format string, args ...any) error {
+	return &config.KeyError{Key: key, Err: fmt.Errorf("%w: %s", ErrInvalidDestination, fmt.Sprintf(format, args...))}
+}
+
+// keyError returns error of configuration key of agent itself.
+func keyError(key string, format string, args ...any) error {
+	return &config.KeyError{Key: key, Err: fmt.Errorf(format, args...)}
 }
 
 // SelfConfig contains configuration for metric client itself.
@@ -34,18 +235,39 @@ type SelfConfig struct {
 	PollInterval time.Duration
 	// ReportInterval - interval for sending reports to server.
 	ReportInterval time.Duration
-	// Key - cryptographic hash to encoding reports.
-	Key string
-	// PublicKeyPath - path to file with public RSA key to encrypt requests.
-	PublicKeyPath string
+	// RateLimit - maximal amount of concurrent requests to every destination.
+	RateLimit int
+	// ShutdownTimeout - maximal duration of sending final report on termination.
+	ShutdownTimeout time.Duration
+	// SpoolPath - path to file for metrics that were not sent on termination, empty value disables spool.
+	// Additional destinations use files with destination name inserted before extension.
+	SpoolPath string
 }
 
 // Config describes total configuration for metric agent.
 type Config struct {
-	// Server - configuration for metric server.
-	Server ServerConfig
+	// Destinations - configuration for metric servers, the first one is main destination.
+	Destinations []DestinationConfig
 	// Agent - configuration for agent itself.
 	Agent SelfConfig
+	// StatsD - configuration for local StatsD listener.
+	StatsD statsd.Config
+	// Push - configuration for local HTTP push endpoint.
+	Push push.Config
+	// Status - configuration for local status endpoint.
+	Status status.Config
+	// Scripts - configuration for external scripts to collect metrics from.
+	Scripts []script.Config
+	// Tail - configuration for log files tailing.
+	Tail tail.Config
+	// Scrape - configuration for scraped Prometheus endpoints.
+	Scrape []scrape.Target
+	// Rules - rules for processing metrics before report.
+	Rules rules.Config
+	// Metadata - metadata of metrics by names after processing by rules, sent with reports.
+	Metadata map[string]api.Metadata
+
+	flags *configFlags
 }
 
 // NewConfig creates total configuration for metric agent.
@@ -80,6 +302,42 @@ func NewConfig() (*Config, error) {
 		"path to file with RSA public crypto key",
 	)
 
+	rateLimit := flag.Int(
+		"l",
+		-1,
+		"maximal amount of concurrent requests to server",
+	)
+
+	statsDAddress := flag.String(
+		"statsd-address",
+		"",
+		"UDP address to listen StatsD metrics",
+	)
+
+	statsDSocket := flag.String(
+		"statsd-socket",
+		"",
+		"path to Unix datagram socket to listen StatsD metrics",
+	)
+
+	pushAddress := flag.String(
+		"push-address",
+		"",
+		"localhost address to listen pushed metrics",
+	)
+
+	pushLimit := flag.Int(
+		"push-limit",
+		-1,
+		"limit of distinct metric names pushed from one source",
+	)
+
+	statusAddress := flag.String(
+		"status-address",
+		"",
+		"localhost address to serve agent status",
+	)
+
 	configPath := flag.String(
 		"c",
 		"",
@@ -88,93 +346,454 @@ func NewConfig() (*Config, error) {
 
 	flag.Parse()
 
-	configFilePath := getEnv("CONFIG", *configPath)
+	flags := &configFlags{
+		address:        *address,
+		reportInterval: *reportInterval,
+		pollInterval:   *pollInterval,
+		key:            *key,
+		publicKeyPath:  *publicKeyPath,
+		rateLimit:      *rateLimit,
+		statsDAddress:  *statsDAddress,
+		statsDSocket:   *statsDSocket,
+		pushAddress:    *pushAddress,
+		pushLimit:      *pushLimit,
+		statusAddress:  *statusAddress,
+		configPath:     *configPath,
+	}
+
+	return loadConfig(flags)
+}
+
+// Reload creates configuration again from the same command line flags, environment and configuration file.
+func (c *Config) Reload() (*Config, error) {
+	if c.flags == nil {
+		return nil, errors.New("configuration was not loaded from command line")
+	}
+
+	return loadConfig(c.flags)
+}
 
-	config := configFile{
-		PollInterval:   _pollIntervalInSecs,
-		ReportInterval: _reportIntervalInSecs,
-		Address:        _serverAddress,
-		Key:            "",
-		PublicKeyPath:  "",
+func loadConfig(flags *configFlags) (*Config, error) {
+	configFilePath := config.GetEnv("CONFIG", flags.configPath)
+
+	file := configFile{
+		PollInterval:     _pollInterval,
+		ReportInterval:   _reportInterval,
+		Address:          _serverAddress,
+		Transport:        HTTPTransport,
+		Key:              "",
+		PublicKeyPath:    "",
+		RateLimit:        _rateLimit,
+		RetryCount:       _retryCount,
+		RetryWait:        _retryWait,
+		RetryMaxWait:     _retryMaxWait,
+		BreakerThreshold: _breakerThreshold,
+		BreakerCooldown:  _breakerCooldown,
+		ShutdownTimeout:  _shutdownTimeout,
+		SpoolPath:        _spoolPath,
+		PushLimit:        _pushLimit,
 	}
 
 	if configFilePath != "" {
-		if err := readConfig(configFilePath, &config); err != nil {
+		if err := config.Read(configFilePath, &file); err != nil {
 			return nil, err
 		}
 	}
 
-	if *address != "" {
-		config.Address = *address
+	if flags.address != "" {
+		file.Address = flags.address
 	}
 
-	if *reportInterval != -1 {
-		config.ReportInterval = *reportInterval
+	if flags.reportInterval != -1 {
+		file.ReportInterval = time.Duration(flags.reportInterval) * time.Second
 	}
 
-	if *pollInterval != -1 {
-		config.PollInterval = *pollInterval
+	if flags.pollInterval != -1 {
+		file.PollInterval = time.Duration(flags.pollInterval) * time.Second
 	}
 
-	if *key != "" {
-		config.Key = *key
+	if flags.key != "" {
+		file.Key = flags.key
 	}
 
-	if *publicKeyPath != "" {
-		config.PublicKeyPath = *publicKeyPath
+	if flags.publicKeyPath != "" {
+		file.PublicKeyPath = flags.publicKeyPath
 	}
 
-	pollDuration := time.Duration(getEnvInt("POLL_INTERVAL", config.PollInterval)) * time.Second
-	reportDuration := time.Duration(getEnvInt("REPORT_INTERVAL", config.ReportInterval)) * time.Second
+	if flags.rateLimit != -1 {
+		file.RateLimit = flags.rateLimit
+	}
 
-	return &Config{
-		Server: ServerConfig{
-			Address: getEnv("ADDRESS", config.Address),
+	if flags.statsDAddress != "" {
+		file.StatsDAddress = flags.statsDAddress
+	}
+
+	if flags.statsDSocket != "" {
+		file.StatsDSocket = flags.statsDSocket
+	}
+
+	if flags.pushAddress != "" {
+		file.PushAddress = flags.pushAddress
+	}
+
+	if flags.pushLimit != -1 {
+		file.PushLimit = flags.pushLimit
+	}
+
+	if flags.statusAddress != "" {
+		file.StatusAddress = flags.statusAddress
+	}
+
+	if err := readEnv(&file); err != nil {
+		return nil, err
+	}
+
+	pollDuration := file.PollInterval
+	reportDuration := file.ReportInterval
+
+	// Tickers panic on not positive intervals, so such configuration is rejected before agent uses it
+	if pollDuration <= 0 {
+		return nil, keyError("poll_interval", "must be positive, got %s", pollDuration)
+	}
+
+	if reportDuration <= 0 {
+		return nil, keyError("report_interval", "must be positive, got %s", reportDuration)
+	}
+
+	limit := file.RateLimit
+	if limit < 1 {
+		return nil, keyError("rate_limit", "must be positive, got %d", limit)
+	}
+
+	if file.ShutdownTimeout <= 0 {
+		return nil, keyError("shutdown_timeout", "must be positive, got %s", file.ShutdownTimeout)
+	}
+
+	// Zero limit disables limit of metric names
+	if file.PushLimit < 0 {
+		return nil, keyError("push_limit", "must not be negative, got %d", file.PushLimit)
+	}
+
+	scripts, err := newScriptsConfig(file.Scripts, pollDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	tailConfig, err := newTailConfig(file.Tail, config.GetEnv("TAIL_STATE", file.TailState), pollDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := newScrapeConfig(file.Scrape, pollDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesConfig, err := newRulesConfig(file.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := newMetadataConfig(file.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	main := DestinationConfig{
+		Address:       config.GetEnv("ADDRESS", file.Address),
+		Transport:     config.GetEnv("TRANSPORT", file.Transport),
+		CACertPath:    config.GetEnv("CA_CERT", file.CACertPath),
+		Key:           config.GetEnv("KEY", file.Key),
+		PublicKeyPath: config.GetEnv("CRYPTO_KEY", file.PublicKeyPath),
+		Retry: retry.Policy{
+			MaxRetries: file.RetryCount,
+			BaseDelay:  file.RetryWait,
+			MaxDelay:   file.RetryMaxWait,
 		},
+		BreakerThreshold: file.BreakerThreshold,
+		BreakerCooldown:  file.BreakerCooldown,
+	}
+
+	destinations, err := newDestinationsConfig(&main, file.Destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Destinations: destinations,
 		Agent: SelfConfig{
-			PollInterval:   pollDuration,
-			ReportInterval: reportDuration,
-			Key:            getEnv("KEY", config.Key),
-			PublicKeyPath:  getEnv("CRYPTO_KEY", config.PublicKeyPath),
+			PollInterval:    pollDuration,
+			ReportInterval:  reportDuration,
+			RateLimit:       limit,
+			ShutdownTimeout: file.ShutdownTimeout,
+			SpoolPath:       config.GetEnv("SPOOL_PATH", file.SpoolPath),
+		},
+		StatsD: statsd.Config{
+			Address:        config.GetEnv("STATSD_ADDRESS", file.StatsDAddress),
+			UnixSocketPath: config.GetEnv("STATSD_SOCKET", file.StatsDSocket),
+			FlushInterval:  pollDuration,
+		},
+		Push: push.Config{
+			Address:             config.GetEnv("PUSH_ADDRESS", file.PushAddress),
+			MaxMetricsPerSource: file.PushLimit,
 		},
+		Status: status.Config{
+			Address: config.GetEnv("STATUS_ADDRESS", file.StatusAddress),
+		},
+		Scripts:  scripts,
+		Tail:     *tailConfig,
+		Scrape:   targets,
+		Rules:    *rulesConfig,
+		Metadata: metadata,
+		flags:    flags,
 	}, nil
 }
 
-func readConfig(configPath string, config *configFile) error {
-	file, err := os.Open(configPath)
-	if err != nil {
-		return err
+// readEnv sets numeric settings from environment variables over values of file and flags.
+func readEnv(file *configFile) error {
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"POLL_INTERVAL", &file.PollInterval},
+		{"REPORT_INTERVAL", &file.ReportInterval},
+		{"RETRY_WAIT", &file.RetryWait},
+		{"RETRY_MAX_WAIT", &file.RetryMaxWait},
+		{"BREAKER_COOLDOWN", &file.BreakerCooldown},
+		{"SHUTDOWN_TIMEOUT", &file.ShutdownTimeout},
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	dec := json.NewDecoder(file)
+	for _, d := range durations {
+		value, err := config.GetEnvDuration(d.key, *d.dst)
+		if err != nil {
+			return err
+		}
+
+		*d.dst = value
+	}
 
-	if err := dec.Decode(config); err != nil {
-		return err
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"RATE_LIMIT", &file.RateLimit},
+		{"RETRY_COUNT", &file.RetryCount},
+		{"BREAKER_THRESHOLD", &file.BreakerThreshold},
+		{"PUSH_LIMIT", &file.PushLimit},
+	}
+
+	for _, i := range ints {
+		value, err := config.GetEnvInt(i.key, *i.dst)
+		if err != nil {
+			return err
+		}
+
+		*i.dst = value
 	}
 
 	return nil
 }
 
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// newDestinationsConfig creates configuration for main and additional destinations.
+//
+// Absent settings of additional destinations are taken from main destination.
+func newDestinationsConfig(main *DestinationConfig, files []destinationFile) ([]DestinationConfig, error) {
+	if err := main.Validate(); err != nil {
+		return nil, err
 	}
 
-	return defaultVal
+	destinations := make([]DestinationConfig, 0, len(files)+1)
+	destinations = append(destinations, *main)
+
+	names := make(map[string]struct{}, len(files))
+
+	for i, f := range files {
+		key := fmt.Sprintf("destinations[%d]", i)
+
+		if f.Name == "" {
+			return nil, config.Field(key, invalid("name", "empty name for %s", f.Address))
+		}
+
+		if _, ok := names[f.Name]; ok {
+			return nil, config.Field(key, invalid("name", "duplicate name %q", f.Name))
+		}
+
+		names[f.Name] = struct{}{}
+
+		d := *main
+		d.Name = f.Name
+		d.Address = f.Address
+
+		setIfPresent(&d.Transport, f.Transport)
+		setIfPresent(&d.CACertPath, f.CACertPath)
+		setIfPresent(&d.Key, f.Key)
+		setIfPresent(&d.PublicKeyPath, f.PublicKeyPath)
+		setIfPresent(&d.Retry.MaxRetries, f.RetryCount)
+		setIfPresent(&d.BreakerThreshold, f.BreakerThreshold)
+		setIfPresent(&d.Retry.BaseDelay, f.RetryWait)
+		setIfPresent(&d.Retry.MaxDelay, f.RetryMaxWait)
+		setIfPresent(&d.BreakerCooldown, f.BreakerCooldown)
+
+		if err := d.Validate(); err != nil {
+			return nil, config.Field(key, err)
+		}
+
+		destinations = append(destinations, d)
+	}
+
+	return destinations, nil
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		val, err := strconv.ParseInt(value, 10, 32)
-		if err != nil {
-			return defaultValue
+func setIfPresent[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func newScriptsConfig(files []scriptFile, pollInterval time.Duration) ([]script.Config, error) {
+	scripts := make([]script.Config, 0, len(files))
+
+	for i, f := range files {
+		c := script.Config{
+			Name:     f.Name,
+			Command:  f.Command,
+			Args:     f.Args,
+			Interval: f.Interval,
+			Timeout:  f.Timeout,
+			Format:   script.Format(f.Format),
+		}
+
+		if f.Interval == 0 {
+			c.Interval = pollInterval
+		}
+
+		if f.Timeout == 0 {
+			c.Timeout = c.Interval
+		}
+
+		if f.Format == "" {
+			c.Format = script.TextFormat
+		}
+
+		if err := c.Validate(); err != nil {
+			return nil, config.Field(fmt.Sprintf("scripts[%d]", i), err)
+		}
+
+		scripts = append(scripts, c)
+	}
+
+	return scripts, nil
+}
+
+func newScrapeConfig(files []scrapeFile, pollInterval time.Duration) ([]scrape.Target, error) {
+	targets := make([]scrape.Target, 0, len(files))
+
+	for i, f := range files {
+		t := scrape.Target{
+			Name:     f.Name,
+			URL:      f.URL,
+			Prefix:   f.Prefix,
+			Interval: f.Interval,
+			Timeout:  f.Timeout,
+		}
+
+		if f.Interval == 0 {
+			t.Interval = pollInterval
+		}
+
+		if f.Timeout == 0 {
+			t.Timeout = t.Interval
+		}
+
+		if err := t.Validate(); err != nil {
+			return nil, config.Field(fmt.Sprintf("scrape[%d]", i), err)
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+func newTailConfig(files []tailFile, statePath string, pollInterval time.Duration) (*tail.Config, error) {
+	tailConfig := &tail.Config{
+		Files:        make([]tail.FileConfig, 0, len(files)),
+		PollInterval: pollInterval,
+		StatePath:    statePath,
+	}
+
+	for _, f := range files {
+		rules := make([]tail.Rule, 0, len(f.Rules))
+
+		for _, r := range f.Rules {
+			rules = append(rules, tail.Rule{
+				Pattern: r.Pattern,
+				Metric:  r.Metric,
+				Type:    api.MetricsType(r.Type),
+				Value:   r.Value,
+			})
+		}
+
+		tailConfig.Files = append(tailConfig.Files, tail.FileConfig{
+			Path:  f.Path,
+			Rules: rules,
+		})
+	}
+
+	if err := tailConfig.Validate(); err != nil {
+		return nil, config.Field("tail", err)
+	}
+
+	return tailConfig, nil
+}
+
+func newRulesConfig(files []ruleFile) (*rules.Config, error) {
+	rulesConfig := &rules.Config{
+		Rules: make([]rules.Rule, 0, len(files)),
+	}
+
+	for _, f := range files {
+		rulesConfig.Rules = append(rulesConfig.Rules, rules.Rule{
+			Action:       rules.Action(f.Action),
+			Name:         f.Name,
+			Pattern:      f.Pattern,
+			Type:         api.MetricsType(f.Type),
+			To:           f.To,
+			Prefix:       f.Prefix,
+			Conversion:   rules.Conversion(f.Conversion),
+			Factor:       f.Factor,
+			Unit:         f.Unit,
+			Labels:       f.Labels,
+			Label:        f.Label,
+			LabelPattern: f.LabelPattern,
+			Replacement:  f.Replacement,
+		})
+	}
+
+	if err := rulesConfig.Validate(); err != nil {
+		return nil, config.Field("rules", err)
+	}
+
+	return rulesConfig, nil
+}
+
+func newMetadataConfig(files map[string]metadataFile) (map[string]api.Metadata, error) {
+	metadata := make(map[string]api.Metadata, len(files))
+
+	for name, f := range files {
+		m := api.Metadata{
+			Unit:        f.Unit,
+			Description: f.Description,
+			Owner:       f.Owner,
+			Type:        api.MetricsType(f.Type),
+		}
+
+		if err := m.Validate(); err != nil {
+			return nil, config.Field("metadata."+name, err)
 		}
 
-		return int(val)
+		metadata[name] = m
 	}
 
-	return defaultValue
+	return metadata, nil
 }
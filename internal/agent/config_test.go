@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+poll_interval: 500ms
+report_interval: 3
+retry_wait: 2s
+destinations:
+  - name: backup
+    address: backup:8080
+    retry_max_wait: 1m
+metadata:
+  OtherSys:
+    owner: platform
+`), 0o600))
+
+	config, err := loadConfig(&configFlags{configPath: path, reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+	require.NoError(t, err)
+
+	assert.Equal(t, 500*time.Millisecond, config.Agent.PollInterval)
+	assert.Equal(t, 3*time.Second, config.Agent.ReportInterval)
+
+	require.Len(t, config.Destinations, 2)
+	assert.Equal(t, 2*time.Second, config.Destinations[1].Retry.BaseDelay)
+	assert.Equal(t, time.Minute, config.Destinations[1].Retry.MaxDelay)
+	assert.Equal(t, _retryMaxWait, config.Destinations[0].Retry.MaxDelay)
+
+	assert.Equal(t, map[string]api.Metadata{"OtherSys": {Owner: "platform"}}, config.Metadata)
+}
+
+func TestLoadConfig_unknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[[destinations]]\nname = \"backup\"\nadress = \"backup:8080\"\n"), 0o600))
+
+	_, err := loadConfig(&configFlags{configPath: path, reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+
+	assert.ErrorContains(t, err, "destinations[0].adress")
+}
+
+func TestLoadConfig_notPositiveDuration(t *testing.T) {
+	for _, key := range []string{"poll_interval", "report_interval", "retry_wait", "breaker_cooldown", "shutdown_timeout"} {
+		path := filepath.Join(t.TempDir(), "agent.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(key+": -1s\n"), 0o600))
+
+		_, err := loadConfig(&configFlags{configPath: path, reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+
+		var keyErr *config.KeyError
+		require.ErrorAs(t, err, &keyErr)
+		assert.Equal(t, key, keyErr.Key)
+	}
+}
+
+func TestLoadConfig_invalidNumber(t *testing.T) {
+	tests := []struct {
+		content string
+		wantKey string
+	}{
+		{content: "rate_limit: 0\n", wantKey: "rate_limit"},
+		{content: "retry_count: -1\n", wantKey: "retry_count"},
+		{content: "breaker_threshold: 0\n", wantKey: "breaker_threshold"},
+		{content: "push_limit: -1\n", wantKey: "push_limit"},
+		{content: "destinations:\n  - name: backup\n    address: backup:8080\n    breaker_threshold: -1\n", wantKey: "destinations[0].breaker_threshold"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.wantKey, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+
+			_, err := loadConfig(&configFlags{configPath: path, reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+
+			var keyErr *config.KeyError
+			require.ErrorAs(t, err, &keyErr)
+			assert.Equal(t, test.wantKey, keyErr.Key)
+		})
+	}
+}
+
+func TestLoadConfig_invalidKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantKey string
+	}{
+		{
+			name:    "destination without name",
+			content: "destinations:\n  - name: backup\n    address: backup:8080\n  - address: other:8080\n",
+			wantKey: "destinations[1].name",
+		},
+		{
+			name:    "destination transport",
+			content: "destinations:\n  - name: backup\n    address: backup:8080\n    transport: ftp\n",
+			wantKey: "destinations[0].transport",
+		},
+		{
+			name:    "script without name",
+			content: "scripts:\n  - command: echo\n",
+			wantKey: "scripts[0].name",
+		},
+		{
+			name:    "scrape without URL",
+			content: "scrape:\n  - name: app\n",
+			wantKey: "scrape[0].url",
+		},
+		{
+			name:    "tail rule without metric",
+			content: "tail:\n  - path: /var/log/app.log\n    rules:\n      - pattern: error\n        type: counter\n",
+			wantKey: "tail[0].rules[0].metric",
+		},
+		{
+			name:    "rename rule without name",
+			content: "rules:\n  - action: drop\n    name: Alloc\n  - action: rename\n    name: Sys\n",
+			wantKey: "rules[1].to",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+
+			_, err := loadConfig(&configFlags{configPath: path, reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+
+			var keyErr *config.KeyError
+			require.ErrorAs(t, err, &keyErr)
+			assert.Equal(t, test.wantKey, keyErr.Key)
+		})
+	}
+}
+
+func TestLoadConfig_invalidEnv(t *testing.T) {
+	t.Setenv("POLL_INTERVAL", "abc")
+
+	_, err := loadConfig(&configFlags{reportInterval: -1, pollInterval: -1, rateLimit: -1, pushLimit: -1})
+
+	assert.ErrorIs(t, err, config.ErrInvalidEnv)
+	assert.ErrorContains(t, err, "POLL_INTERVAL")
+}
@@ -0,0 +1,446 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/agent/breaker"
+	"github.com/kaa-it/go-devops/internal/agent/retry"
+	"github.com/kaa-it/go-devops/internal/agent/spool"
+	"github.com/kaa-it/go-devops/internal/agent/status"
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/pkg/client"
+)
+
+// destination sends batches of metrics to one metric server.
+//
+// Every destination has own workers, retries and circuit breaker. Counters of batches
+// that were not sent are kept by destination and added to its next batch,
+// so failing destination neither blocks others nor makes them receive counters twice.
+type destination struct {
+	config  *DestinationConfig
+	client  *client.Client
+	breaker *breaker.Breaker
+	spool   *spool.Spool
+	clock   Clock
+
+	// storage - agent storage for self-telemetry and restored gauges
+	storage *Storage
+	// pending - counters of unsent batches
+	pending *Storage
+	jobs    chan []api.Metrics
+	// workers - workers started for destination
+	workers sync.WaitGroup
+
+	mu         sync.Mutex
+	lastReport *status.Report
+	// described - metadata of metrics received by server, it is not sent again until changed
+	described map[string]api.Metadata
+}
+
+// newDestinations creates destinations for configuration.
+//
+// New destinations take counters kept by previous destinations with the same name.
+func newDestinations(config *Config, storage *Storage, clock Clock, previous []*destination) ([]*destination, error) {
+	destinations := make([]*destination, 0, len(config.Destinations))
+
+	for i := range config.Destinations {
+		d, err := newDestination(&config.Destinations[i], &config.Agent, storage, clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create destination %q: %w", config.Destinations[i].Name, err)
+		}
+
+		for _, p := range previous {
+			if p.config.Name == d.config.Name {
+				d.pending = p.pending
+				d.lastReport = p.status().LastReport
+			}
+		}
+
+		destinations = append(destinations, d)
+	}
+
+	return destinations, nil
+}
+
+func newDestination(config *DestinationConfig, self *SelfConfig, storage *Storage, clock Clock) (*destination, error) {
+	httpClient := &http.Client{Timeout: _requestTimeout}
+
+	if config.CACertPath != "" {
+		pool, err := client.LoadCACert(config.CACertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    pool,
+				MinVersion: tls.VersionTLS12,
+			},
+		}
+	}
+
+	clientConfig := &client.Config{
+		Address:    config.Address,
+		Transport:  config.Transport,
+		Key:        config.Key,
+		HTTPClient: httpClient,
+	}
+
+	if config.PublicKeyPath != "" {
+		publicKey, err := client.LoadPublicKey(config.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		clientConfig.PublicKey = publicKey
+	}
+
+	d := &destination{
+		config:  config,
+		breaker: breaker.New(config.BreakerThreshold, config.BreakerCooldown),
+		clock:   clock,
+		storage: storage,
+		pending: NewStorage(),
+		// Metadata is sent again after start and reload, as destination is created anew
+		described: make(map[string]api.Metadata),
+		// Buffer size equal to workers amount allows to queue one batch per worker
+		jobs: make(chan []api.Metrics, self.RateLimit),
+	}
+
+	clientConfig.OnBatch = d.recordBatch
+
+	c, err := client.New(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	d.client = c
+
+	if self.SpoolPath != "" {
+		d.spool = spool.New(spoolPath(self.SpoolPath, config.Name), func(delta int) {
+			storage.AddGauge(telemetry.SpoolDepth, float64(delta))
+		})
+	}
+
+	return d, nil
+}
+
+// spoolPath returns path to spool file of destination.
+//
+// Main destination uses given path, additional ones insert their name before extension.
+func spoolPath(path string, name string) string {
+	if name == "" {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// metric returns name of self-telemetry metric for destination.
+func (d *destination) metric(name string) string {
+	return telemetry.ForDestination(name, d.config.Name)
+}
+
+// runWorker sends batches of metrics from jobs channel until it is closed.
+func (d *destination) runWorker(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for metrics := range d.jobs {
+		if err := d.send(ctx, metrics); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		log.Printf("Report to %s done", d.config.Address)
+	}
+}
+
+// enqueue queues batch for workers together with counters kept from previous batches.
+//
+// If breaker is open or all workers are busy, the report is skipped
+// and counters of batch are kept until the next report.
+func (d *destination) enqueue(metrics []api.Metrics) {
+	state := d.breaker.State()
+	d.storage.UpdateGauge(d.metric(telemetry.BreakerState), float64(state))
+
+	if state == breaker.Open {
+		log.Printf("Report to %s skipped: circuit breaker is open", d.config.Address)
+		d.keep(metrics)
+		return
+	}
+
+	// Reporter is the only producer, so free space can not disappear after check
+	if len(d.jobs) == cap(d.jobs) {
+		log.Printf("Report to %s skipped: all workers are busy", d.config.Address)
+		d.keep(metrics)
+		return
+	}
+
+	batch := d.merge(metrics)
+	if len(batch) == 0 {
+		return
+	}
+
+	d.stripMetadata(batch)
+
+	d.jobs <- batch
+}
+
+// send sends batch of metrics with retries guarded by circuit breaker.
+//
+// If server is unavailable counters of batch are kept to be sent with the next report.
+func (d *destination) send(ctx context.Context, metrics []api.Metrics) error {
+	if !d.breaker.Allow() {
+		d.keep(metrics)
+		d.storage.UpdateCounter(d.metric(telemetry.ReportsFailed), 1)
+		d.setReport(len(metrics), breaker.ErrOpen)
+		return breaker.ErrOpen
+	}
+
+	err := d.config.Retry.Do(ctx, func() error {
+		return d.client.Batch(ctx, metrics)
+	})
+
+	d.setReport(len(metrics), err)
+
+	if err == nil {
+		d.breaker.Success()
+		d.rememberMetadata(metrics)
+		d.storage.UpdateCounter(d.metric(telemetry.ReportsSent), 1)
+		d.storage.UpdateGauge(d.metric(telemetry.LastReportTimestamp), float64(d.clock.Now().Unix()))
+		return nil
+	}
+
+	d.storage.UpdateCounter(d.metric(telemetry.ReportsFailed), 1)
+
+	if unavailable(ctx, err) {
+		d.breaker.Failure()
+		d.keep(metrics)
+		return err
+	}
+
+	// Not retriable error means that server is available, but batch can not be sent
+	d.breaker.Success()
+
+	return err
+}
+
+// unavailable reports whether batch failed with error may be sent later.
+//
+// Other errors mean that server is available, but rejects batch.
+func unavailable(ctx context.Context, err error) bool {
+	retriable, _ := retry.Retriable(err)
+
+	return retriable || errors.Is(err, breaker.ErrOpen) || ctx.Err() != nil
+}
+
+// flush sends the final batch together with kept counters.
+//
+// Saves batch to spool if server is unavailable, batch rejected by server is dropped.
+func (d *destination) flush(ctx context.Context, metrics []api.Metrics) {
+	batch := d.merge(metrics)
+	if len(batch) == 0 {
+		return
+	}
+
+	d.stripMetadata(batch)
+
+	err := d.send(ctx, batch)
+	if err == nil {
+		log.Printf("Final report to %s done", d.config.Address)
+		return
+	}
+
+	log.Printf("final report to %s failed: %s", d.config.Address, err)
+
+	if !unavailable(ctx, err) {
+		log.Printf("%d metrics for %s are dropped as server rejected them", len(batch), d.config.Address)
+		return
+	}
+
+	if d.spool == nil {
+		log.Printf("%d metrics for %s are lost as spool is disabled", len(batch), d.config.Address)
+		return
+	}
+
+	if err := d.spool.Append(batch); err != nil {
+		log.Printf("failed to save metrics for %s to spool: %s", d.config.Address, err)
+		return
+	}
+
+	log.Printf("%d metrics for %s saved to spool", len(batch), d.config.Address)
+}
+
+// retire sends counters kept by destination removed from configuration after its workers exit.
+//
+// Counters are sent not longer than timeout. Spool of removed destination is never restored,
+// so counters that could not be sent are dropped and counted in agent storage.
+func (d *destination) retire(ctx context.Context, wg *sync.WaitGroup, timeout time.Duration) {
+	defer wg.Done()
+
+	d.workers.Wait()
+
+	batch := d.merge(nil)
+	if len(batch) == 0 {
+		return
+	}
+
+	log.Printf("Destination %s is removed from configuration, kept counters are sent", d.config.Address)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := d.send(ctx, batch)
+	if err == nil {
+		return
+	}
+
+	var dropped int64
+
+	for _, m := range batch {
+		if m.MType == api.CounterType {
+			dropped++
+		}
+	}
+
+	log.Printf("%d counters of removed destination %s are dropped: %s", dropped, d.config.Address, err)
+
+	d.storage.UpdateCounter(telemetry.DroppedCounters, dropped)
+}
+
+// restoreSpool moves metrics saved by previous run from spool.
+//
+// Counters are kept by destination, gauges are restored to agent storage.
+func (d *destination) restoreSpool() {
+	if d.spool == nil {
+		return
+	}
+
+	batches, err := d.spool.Load()
+	if err != nil {
+		log.Printf("failed to load spool for %s: %s", d.config.Address, err)
+		return
+	}
+
+	for _, batch := range batches {
+		for _, m := range batch {
+			switch {
+			case m.MType == api.CounterType && m.Delta != nil:
+				d.pending.UpdateCounter(m.ID, *m.Delta)
+			case m.MType == api.GaugeType && m.Value != nil:
+				d.storage.UpdateGauge(m.ID, *m.Value)
+			}
+		}
+	}
+
+	if err := d.spool.Clear(); err != nil {
+		log.Printf("failed to clear spool for %s: %s", d.config.Address, err)
+	}
+}
+
+// keep saves counter values of unsent batch to be added to the next batch.
+//
+// Gauges are not kept as agent storage keeps their latest values.
+func (d *destination) keep(metrics []api.Metrics) {
+	for _, m := range metrics {
+		if m.MType == api.CounterType {
+			d.pending.UpdateCounter(m.ID, *m.Delta)
+		}
+	}
+}
+
+// merge returns copy of batch with kept counters added.
+//
+// Batch is shared by destinations, so its metrics are not modified.
+func (d *destination) merge(metrics []api.Metrics) []api.Metrics {
+	batch := make([]api.Metrics, len(metrics))
+	copy(batch, metrics)
+
+	counters := make(map[string]int)
+
+	for i, m := range batch {
+		if m.MType == api.CounterType {
+			counters[m.ID] = i
+		}
+	}
+
+	d.pending.ForEachCounter(func(key string, value int64) {
+		if value == 0 {
+			return
+		}
+
+		d.pending.UpdateCounter(key, -value)
+
+		if i, ok := counters[key]; ok {
+			delta := *batch[i].Delta + value
+			batch[i].Delta = &delta
+
+			return
+		}
+
+		batch = append(batch, api.Metrics{
+			ID:    key,
+			MType: api.CounterType,
+			Delta: &value,
+		})
+	})
+
+	return batch
+}
+
+func (d *destination) setReport(metrics int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastReport = &status.Report{
+		Time:    d.clock.Now(),
+		Metrics: metrics,
+		Success: err == nil,
+	}
+
+	if err != nil {
+		d.lastReport.Error = err.Error()
+	}
+}
+
+// status returns current state of destination.
+func (d *destination) status() status.Destination {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := status.Destination{
+		Name:    d.config.Name,
+		Address: d.config.Address,
+		Breaker: d.breaker.State().String(),
+	}
+
+	if d.lastReport != nil {
+		r := *d.lastReport
+		s.LastReport = &r
+	}
+
+	return s
+}
+
+// recordBatch records self-telemetry metrics of encoded batch.
+func (d *destination) recordBatch(info client.BatchInfo) {
+	d.storage.UpdateGauge(d.metric(telemetry.BatchSize), float64(info.Metrics))
+	d.storage.UpdateGauge(d.metric(telemetry.BatchBytes), float64(info.Bytes))
+	d.storage.UpdateGauge(d.metric(telemetry.BatchGzipBytes), float64(info.GzipBytes))
+
+	if d.config.PublicKeyPath != "" {
+		d.storage.UpdateGauge(d.metric(telemetry.EncryptionSeconds), info.Encryption.Seconds())
+	}
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/agent/breaker"
+	"github.com/kaa-it/go-devops/internal/agent/retry"
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+func TestDestination_workers(t *testing.T) {
+	var active, maxActive, total atomic.Int32
+
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		<-release
+		total.Add(1)
+	}))
+	defer server.Close()
+
+	config := newTestConfig(testAddress(server))
+
+	agent, err := New(config)
+	require.NoError(t, err)
+
+	d := agent.destinations[0]
+
+	wg := new(sync.WaitGroup)
+	wg.Add(config.Agent.RateLimit)
+
+	for i := 0; i < config.Agent.RateLimit; i++ {
+		go d.runWorker(context.Background(), wg)
+	}
+
+	// Two batches are sent by workers and two are queued, the fifth report is skipped
+	for i := 0; i < 5; i++ {
+		agent.storage.UpdateCounter("PollCount", 1)
+		agent.report()
+
+		if i == 1 {
+			require.Eventually(t, func() bool {
+				return active.Load() == 2
+			}, time.Second, 10*time.Millisecond)
+		}
+	}
+
+	// Counter of skipped report is kept for the next one
+	assert.Equal(t, int64(1), counter(d.pending, "PollCount"))
+
+	close(release)
+	close(d.jobs)
+	wg.Wait()
+
+	assert.Equal(t, int32(4), total.Load())
+	assert.Equal(t, int32(2), maxActive.Load())
+}
+
+func TestDestination_breaker(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := newTestConfig(testAddress(server))
+	config.Destinations[0].Retry = retry.Policy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	config.Destinations[0].BreakerThreshold = 1
+	config.Destinations[0].BreakerCooldown = time.Hour
+
+	agent, err := New(config)
+	require.NoError(t, err)
+
+	d := agent.destinations[0]
+
+	agent.storage.UpdateCounter("PollCount", 5)
+	agent.report()
+	close(d.jobs)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	d.runWorker(context.Background(), wg)
+
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, breaker.Open, d.breaker.State())
+
+	// Counter of failed batch is kept
+	assert.Equal(t, int64(5), counter(d.pending, "PollCount"))
+	assert.Equal(t, int64(1), counter(agent.storage, telemetry.ReportsFailed))
+
+	// Report is skipped while breaker is open
+	d.jobs = make(chan []api.Metrics, 1)
+	agent.storage.UpdateCounter("PollCount", 2)
+	agent.report()
+
+	assert.Empty(t, d.jobs)
+	assert.Equal(t, int64(7), counter(d.pending, "PollCount"))
+}
+
+func TestDestination_merge(t *testing.T) {
+	d := &destination{pending: NewStorage()}
+
+	value := 1.5
+	delta := int64(2)
+
+	metrics := []api.Metrics{
+		{ID: "Alloc", MType: api.GaugeType, Value: &value},
+		{ID: "PollCount", MType: api.CounterType, Delta: &delta},
+	}
+
+	d.pending.UpdateCounter("PollCount", 3)
+	d.pending.UpdateCounter("Errors", 1)
+
+	batch := d.merge(metrics)
+
+	assert.Len(t, batch, 3)
+	assert.Equal(t, int64(5), batchCounter(batch, "PollCount"))
+	assert.Equal(t, int64(1), batchCounter(batch, "Errors"))
+
+	// Shared batch is not modified
+	assert.Equal(t, int64(2), *metrics[1].Delta)
+
+	// Kept counters are sent once
+	assert.Equal(t, int64(2), batchCounter(d.merge(metrics), "PollCount"))
+}
+
+func TestSpoolPath(t *testing.T) {
+	assert.Equal(t, "/tmp/spool.json", spoolPath("/tmp/spool.json", ""))
+	assert.Equal(t, "/tmp/spool.backup.json", spoolPath("/tmp/spool.json", "backup"))
+	assert.Equal(t, "/tmp/spool.backup", spoolPath("/tmp/spool", "backup"))
+}
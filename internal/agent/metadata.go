@@ -0,0 +1,108 @@
+package agent
+
+import "github.com/kaa-it/go-devops/internal/api"
+
+// Units of runtime metrics
+const (
+	_unitBytes   = "bytes"
+	_unitObjects = "objects"
+	_unitNs      = "nanoseconds"
+)
+
+// _runtimeMetadata describes metrics collected by poll, descriptions follow runtime.MemStats.
+var _runtimeMetadata = map[string]api.Metadata{
+	"Alloc":         {Unit: _unitBytes, Description: "Bytes of allocated heap objects", Type: api.GaugeType},
+	"BuckHashSys":   {Unit: _unitBytes, Description: "Bytes of memory in profiling bucket hash tables", Type: api.GaugeType},
+	"Frees":         {Unit: _unitObjects, Description: "Cumulative count of heap objects freed", Type: api.GaugeType},
+	"GCCPUFraction": {Description: "Fraction of available CPU time used by GC since program start", Type: api.GaugeType},
+	"GCSys":         {Unit: _unitBytes, Description: "Bytes of memory in garbage collection metadata", Type: api.GaugeType},
+	"HeapAlloc":     {Unit: _unitBytes, Description: "Bytes of allocated heap objects", Type: api.GaugeType},
+	"HeapIdle":      {Unit: _unitBytes, Description: "Bytes in idle (unused) heap spans", Type: api.GaugeType},
+	"HeapInuse":     {Unit: _unitBytes, Description: "Bytes in in-use heap spans", Type: api.GaugeType},
+	"HeapObjects":   {Unit: _unitObjects, Description: "Number of allocated heap objects", Type: api.GaugeType},
+	"HeapReleased":  {Unit: _unitBytes, Description: "Bytes of physical memory returned to the OS", Type: api.GaugeType},
+	"HeapSys":       {Unit: _unitBytes, Description: "Bytes of heap memory obtained from the OS", Type: api.GaugeType},
+	"LastGC":        {Unit: _unitNs, Description: "Time the last garbage collection finished, since Unix epoch", Type: api.GaugeType},
+	"Lookups":       {Description: "Number of pointer lookups performed by the runtime", Type: api.GaugeType},
+	"MCacheInuse":   {Unit: _unitBytes, Description: "Bytes of allocated mcache structures", Type: api.GaugeType},
+	"MCacheSys":     {Unit: _unitBytes, Description: "Bytes of memory obtained from the OS for mcache structures", Type: api.GaugeType},
+	"MSpanInuse":    {Unit: _unitBytes, Description: "Bytes of allocated mspan structures", Type: api.GaugeType},
+	"MSpanSys":      {Unit: _unitBytes, Description: "Bytes of memory obtained from the OS for mspan structures", Type: api.GaugeType},
+	"Mallocs":       {Unit: _unitObjects, Description: "Cumulative count of heap objects allocated", Type: api.GaugeType},
+	"NextGC":        {Unit: _unitBytes, Description: "Target heap size of the next GC cycle", Type: api.GaugeType},
+	"NumForcedGC":   {Description: "Number of GC cycles forced by the application calling runtime.GC", Type: api.GaugeType},
+	"NumGC":         {Description: "Number of completed GC cycles", Type: api.GaugeType},
+	"OtherSys":      {Unit: _unitBytes, Description: "Bytes of memory in miscellaneous off-heap runtime allocations", Type: api.GaugeType},
+	"PauseTotalNs":  {Unit: _unitNs, Description: "Cumulative time spent in GC stop-the-world pauses", Type: api.GaugeType},
+	"StackInuse":    {Unit: _unitBytes, Description: "Bytes in stack spans", Type: api.GaugeType},
+	"StackSys":      {Unit: _unitBytes, Description: "Bytes of stack memory obtained from the OS", Type: api.GaugeType},
+	"Sys":           {Unit: _unitBytes, Description: "Total bytes of memory obtained from the OS", Type: api.GaugeType},
+	"TotalAlloc":    {Unit: _unitBytes, Description: "Cumulative bytes allocated for heap objects", Type: api.GaugeType},
+	"PollCount":     {Description: "Number of runtime polls done by agent", Type: api.CounterType},
+	"RandomValue":   {Description: "Random value updated on every runtime poll", Type: api.GaugeType},
+
+	"TotalMemory":     {Unit: _unitBytes, Description: "Total amount of system memory", Type: api.GaugeType},
+	"FreeMemory":      {Unit: _unitBytes, Description: "Amount of free system memory", Type: api.GaugeType},
+	"CPUutilization1": {Unit: "percent", Description: "Utilization of all CPUs", Type: api.GaugeType},
+}
+
+// describeRuntime attaches built-in metadata to runtime metrics of batch.
+//
+// It is called before rules are applied, so metadata follows renamed metrics.
+func describeRuntime(metrics []api.Metrics) []api.Metrics {
+	for i, m := range metrics {
+		if metadata, ok := _runtimeMetadata[m.ID]; ok {
+			metrics[i].Metadata = &metadata
+		}
+	}
+
+	return metrics
+}
+
+// describe sets configured metadata over metadata of metrics in processed batch.
+func (a *Agent) describe(metrics []api.Metrics) []api.Metrics {
+	for i, m := range metrics {
+		configured, ok := a.config.Metadata[m.ID]
+		if !ok {
+			continue
+		}
+
+		var metadata api.Metadata
+		if m.Metadata != nil {
+			metadata = *m.Metadata
+		}
+
+		metadata = metadata.Merge(configured)
+		metrics[i].Metadata = &metadata
+	}
+
+	return metrics
+}
+
+// stripMetadata removes metadata already received by server of destination from its copy of batch.
+func (d *destination) stripMetadata(batch []api.Metrics) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, m := range batch {
+		if m.Metadata == nil {
+			continue
+		}
+
+		if described, ok := d.described[m.ID]; ok && described == *m.Metadata {
+			batch[i].Metadata = nil
+		}
+	}
+}
+
+// rememberMetadata remembers metadata of batch received by server of destination.
+func (d *destination) rememberMetadata(batch []api.Metrics) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, m := range batch {
+		if m.Metadata != nil {
+			d.described[m.ID] = *m.Metadata
+		}
+	}
+}
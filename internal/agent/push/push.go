@@ -0,0 +1,260 @@
+// Package push contains local HTTP endpoint for pushing application metrics to agent.
+//
+// The endpoint accepts the same JSON bodies as metric server does, so short-lived jobs
+// can push metrics through the agent without knowing server keys.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+)
+
+const (
+	// SourceHeader - request header to identify source of metrics, remote host is used if it is absent.
+	SourceHeader = "X-Metrics-Source"
+
+	_shutdownTimeout = 5 * time.Second
+
+	// Sources are identified by client, so amount of tracked ones is limited and idle ones are forgotten
+	_maxSources = 100
+	_sourceTTL  = 10 * time.Minute
+)
+
+// Storage describes methods of agent storage used by endpoint.
+type Storage interface {
+	// UpdateGauge sets value of gauge metric.
+	UpdateGauge(name string, value float64)
+	// UpdateCounter adds value to counter metric.
+	UpdateCounter(name string, value int64)
+}
+
+// Config describes configuration of push endpoint.
+type Config struct {
+	// Address - address to listen, empty value disables endpoint. Should be bound to localhost.
+	Address string
+	// MaxMetricsPerSource - limit of distinct metric names accepted from one source, zero means no limit.
+	MaxMetricsPerSource int
+}
+
+// Server describes push endpoint.
+type Server struct {
+	config  *Config
+	storage Storage
+
+	mu         sync.Mutex
+	sources    map[string]*source
+	maxSources int
+	now        func() time.Time
+}
+
+// source describes metric names pushed by one source.
+type source struct {
+	names    map[string]struct{}
+	lastSeen time.Time
+}
+
+// New creates new push endpoint that saves received metrics to given storage.
+func New(config *Config, storage Storage) *Server {
+	return &Server{
+		config:     config,
+		storage:    storage,
+		sources:    make(map[string]*source),
+		maxSources: _maxSources,
+		now:        time.Now,
+	}
+}
+
+// Route creates router for all routes of push endpoint.
+func (s *Server) Route() *chi.Mux {
+	mux := chi.NewRouter()
+
+	mux.Post("/update/", gzip.Middleware(s.update))
+	mux.Post("/updates/", gzip.Middleware(s.updates))
+
+	return mux
+}
+
+// Run serves push endpoint until context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    s.config.Address,
+		Handler: s.Route(),
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), _shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Println("Push endpoint terminated")
+
+	return nil
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	var req api.Metrics
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	s.apply(w, r, []api.Metrics{req})
+}
+
+func (s *Server) updates(w http.ResponseWriter, r *http.Request) {
+	var req []api.Metrics
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if len(req) == 0 {
+		http.Error(w, "Metric batch is empty", http.StatusBadRequest)
+		return
+	}
+
+	s.apply(w, r, req)
+}
+
+func (s *Server) apply(w http.ResponseWriter, r *http.Request, metrics []api.Metrics) {
+	for _, m := range metrics {
+		if err := validate(m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.register(sourceName(r), metrics); err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	for _, m := range metrics {
+		if m.MType == api.CounterType {
+			s.storage.UpdateCounter(m.ID, *m.Delta)
+		} else {
+			s.storage.UpdateGauge(m.ID, *m.Value)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// register remembers metric names for source.
+//
+// Returns error without registering anything if source would exceed limit of distinct metric names
+// or if source is new and limit of tracked sources is reached. Sources idle for _sourceTTL are forgotten.
+func (s *Server) register(name string, metrics []api.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	for n, src := range s.sources {
+		if now.Sub(src.lastSeen) > _sourceTTL {
+			delete(s.sources, n)
+		}
+	}
+
+	src, ok := s.sources[name]
+	if !ok {
+		if len(s.sources) >= s.maxSources {
+			return errors.New("source limit exceeded")
+		}
+
+		src = &source{names: make(map[string]struct{})}
+		s.sources[name] = src
+	}
+
+	src.lastSeen = now
+
+	added := make(map[string]struct{})
+
+	for _, m := range metrics {
+		if _, ok := src.names[m.ID]; !ok {
+			added[m.ID] = struct{}{}
+		}
+	}
+
+	limit := s.config.MaxMetricsPerSource
+	if limit > 0 && len(src.names)+len(added) > limit {
+		return errors.New("metric limit exceeded for source")
+	}
+
+	for n := range added {
+		src.names[n] = struct{}{}
+	}
+
+	return nil
+}
+
+func validate(m api.Metrics) error {
+	if m.ID == "" {
+		return errors.New("metric name is empty")
+	}
+
+	if telemetry.Reserved(m.ID) {
+		return fmt.Errorf("metric name prefix %s is reserved", telemetry.Prefix)
+	}
+
+	switch m.MType {
+	case api.GaugeType:
+		if m.Value == nil {
+			return fmt.Errorf("metric value not found for %s", m.ID)
+		}
+	case api.CounterType:
+		if m.Delta == nil {
+			return fmt.Errorf("metric value not found for %s", m.ID)
+		}
+	default:
+		return fmt.Errorf("metric type %s is not supported", m.MType)
+	}
+
+	return nil
+}
+
+func sourceName(r *http.Request) string {
+	if src := r.Header.Get(SourceHeader); src != "" {
+		return src
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,138 @@
+package push
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/agent/agenttest"
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		source   string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "single gauge",
+			path:     "/update/",
+			source:   "job1",
+			body:     `{"id":"Temperature","type":"gauge","value":36.6}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "batch",
+			path:     "/updates/",
+			source:   "job1",
+			body:     `[{"id":"Processed","type":"counter","delta":5},{"id":"Temperature","type":"gauge","value":37}]`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid body",
+			path:     "/update/",
+			source:   "job1",
+			body:     `{"id":`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "empty batch",
+			path:     "/updates/",
+			source:   "job1",
+			body:     `[]`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing value",
+			path:     "/update/",
+			source:   "job1",
+			body:     `{"id":"Processed","type":"counter","value":5}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unsupported type",
+			path:     "/update/",
+			source:   "job1",
+			body:     `{"id":"Processed","type":"histogram","value":5}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "reserved prefix",
+			path:     "/update/",
+			source:   "job1",
+			body:     `{"id":"agent.reports_sent","type":"counter","delta":5}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "limit exceeded",
+			path:     "/updates/",
+			source:   "job1",
+			body:     `[{"id":"A","type":"counter","delta":1},{"id":"B","type":"counter","delta":1}]`,
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name:     "other source has own limit",
+			path:     "/updates/",
+			source:   "job2",
+			body:     `[{"id":"A","type":"counter","delta":1},{"id":"B","type":"counter","delta":1}]`,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	s := agenttest.NewStorage()
+	p := New(&Config{MaxMetricsPerSource: 3}, s)
+
+	srv := httptest.NewServer(p.Route())
+	defer srv.Close()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/json").
+				SetHeader(SourceHeader, test.source).
+				SetBody(test.body).
+				Post(srv.URL + test.path)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+		})
+	}
+
+	assert.Equal(t, 37.0, s.Gauges["Temperature"])
+	assert.Equal(t, int64(5), s.Counters["Processed"])
+	assert.Equal(t, int64(1), s.Counters["A"])
+	assert.Equal(t, int64(1), s.Counters["B"])
+}
+
+func TestServer_sources(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	p := New(&Config{MaxMetricsPerSource: 1}, agenttest.NewStorage())
+	p.maxSources = 2
+	p.now = func() time.Time { return now }
+
+	metrics := []api.Metrics{{ID: "A"}}
+
+	require.NoError(t, p.register("job1", metrics))
+	require.NoError(t, p.register("job2", metrics))
+
+	// Changing source does not bypass limits, amount of tracked sources is limited too
+	assert.Error(t, p.register("job3", metrics))
+
+	now = now.Add(_sourceTTL / 2)
+	require.NoError(t, p.register("job1", metrics))
+
+	// Idle source is forgotten, so new one fits
+	now = now.Add(_sourceTTL/2 + time.Second)
+	require.NoError(t, p.register("job3", metrics))
+	assert.Len(t, p.sources, 2)
+	assert.Contains(t, p.sources, "job1")
+}
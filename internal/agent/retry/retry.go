@@ -0,0 +1,108 @@
+// Package retry contains retry policy with exponential backoff and full jitter for agent requests.
+//
+// Only retriable errors are retried: network failures like refused or reset connections and timeouts,
+// 5xx responses except 501 and 429 responses. Delay requested by server in Retry-After header is honored
+// up to maximal delay of policy.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/kaa-it/go-devops/pkg/client"
+)
+
+// Policy describes retry policy.
+type Policy struct {
+	// MaxRetries - maximal amount of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay - upper bound of delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay - upper bound of delay before any retry.
+	MaxDelay time.Duration
+}
+
+// Backoff returns random delay before retry with given number starting from zero.
+//
+// The delay is uniformly distributed between zero and min(MaxDelay, BaseDelay * 2^attempt).
+func (p *Policy) Backoff(attempt int) time.Duration {
+	limit := p.BaseDelay
+	for i := 0; i < attempt && limit < p.MaxDelay; i++ {
+		limit *= 2
+	}
+
+	limit = min(limit, p.MaxDelay)
+
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// Do calls fn until it succeeds, returns not retriable error, retries are exhausted or context is done.
+//
+// Returns the last error of fn.
+func (p *Policy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retriable, retryAfter := Retriable(err)
+		if !retriable || attempt >= p.MaxRetries {
+			return err
+		}
+
+		// Server may request any delay, so it is limited as backoff is
+		delay := max(p.Backoff(attempt), min(retryAfter, p.MaxDelay))
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Retriable reports whether request failed with given error may be retried
+// and returns delay requested by server.
+func Retriable(err error) (bool, time.Duration) {
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return true, statusErr.RetryAfter
+		case statusErr.StatusCode == http.StatusNotImplemented:
+			return false, 0
+		case statusErr.StatusCode >= http.StatusInternalServerError:
+			return true, statusErr.RetryAfter
+		default:
+			return false, 0
+		}
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, 0
+	}
+
+	return false, 0
+}
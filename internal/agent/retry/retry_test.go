@@ -0,0 +1,154 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kaa-it/go-devops/pkg/client"
+)
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}
+
+	for attempt, limit := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			delay := p.Backoff(attempt)
+
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, limit)
+		}
+	}
+
+	assert.LessOrEqual(t, p.Backoff(1000), time.Second)
+}
+
+func TestRetriable(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantRetriable  bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:          "connection refused",
+			err:           fmt.Errorf("failed to send: %w", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}),
+			wantRetriable: true,
+		},
+		{
+			name:          "server error",
+			err:           client.NewStatusError(http.StatusBadGateway, ""),
+			wantRetriable: true,
+		},
+		{
+			name:          "not implemented",
+			err:           client.NewStatusError(http.StatusNotImplemented, ""),
+			wantRetriable: false,
+		},
+		{
+			name:           "too many requests",
+			err:            client.NewStatusError(http.StatusTooManyRequests, "3"),
+			wantRetriable:  true,
+			wantRetryAfter: 3 * time.Second,
+		},
+		{
+			name:          "bad request",
+			err:           client.NewStatusError(http.StatusBadRequest, ""),
+			wantRetriable: false,
+		},
+		{
+			name:          "other error",
+			err:           errors.New("failed to encrypt"),
+			wantRetriable: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			retriable, retryAfter := Retriable(test.err)
+
+			assert.Equal(t, test.wantRetriable, retriable)
+			assert.Equal(t, test.wantRetryAfter, retryAfter)
+		})
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	p := Policy{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	}
+
+	t.Run("retriable error", func(t *testing.T) {
+		var calls int
+
+		err := p.Do(context.Background(), func() error {
+			calls++
+			return client.NewStatusError(http.StatusServiceUnavailable, "")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("not retriable error", func(t *testing.T) {
+		var calls int
+
+		err := p.Do(context.Background(), func() error {
+			calls++
+			return client.NewStatusError(http.StatusBadRequest, "")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("success after retry", func(t *testing.T) {
+		var calls int
+
+		err := p.Do(context.Background(), func() error {
+			calls++
+			if calls < 2 {
+				return client.NewStatusError(http.StatusInternalServerError, "")
+			}
+
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("long retry after", func(t *testing.T) {
+		var calls int
+
+		start := time.Now()
+
+		err := p.Do(context.Background(), func() error {
+			calls++
+			return client.NewStatusError(http.StatusTooManyRequests, "3600")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 4, calls)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,405 @@
+// Package rules contains processor that filters, renames and relabels metrics before report.
+//
+// Rules are applied to every metric in order they are configured. Metric names may carry labels
+// in format name;label1=value1;label2=value2, rules match the name without labels.
+// Metrics that get the same name after processing are combined: counters are summed,
+// the last gauge value wins. Rules can not produce names with reserved prefix of agent self metrics:
+// such rules are rejected by validation, rename and prefix producing such name at runtime are not applied.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+// Action describes what rule does with matched metric.
+type Action string
+
+// Supported actions
+const (
+	Drop    Action = "drop"    // drops matched metrics
+	Keep    Action = "keep"    // drops metrics that do not match
+	Rename  Action = "rename"  // sets new name
+	Prefix  Action = "prefix"  // adds prefix to name
+	Convert Action = "convert" // multiplies gauge value, counters are not changed
+	Label   Action = "label"   // sets labels
+	Relabel Action = "relabel" // rewrites or removes value of label
+)
+
+// Conversion describes named unit conversion.
+type Conversion string
+
+// Supported conversions
+const (
+	BytesToKiB Conversion = "bytes_to_kib"
+	BytesToMiB Conversion = "bytes_to_mib"
+	BytesToGiB Conversion = "bytes_to_gib"
+	NsToMs     Conversion = "ns_to_ms"
+	NsToS      Conversion = "ns_to_s"
+	MsToS      Conversion = "ms_to_s"
+)
+
+var _conversions = map[Conversion]float64{
+	BytesToKiB: 1.0 / (1 << 10),
+	BytesToMiB: 1.0 / (1 << 20),
+	BytesToGiB: 1.0 / (1 << 30),
+	NsToMs:     1e-6,
+	NsToS:      1e-9,
+	MsToS:      1e-3,
+}
+
+// _conversionUnits - units of converted values, unit of value converted with custom factor is set by rule.
+var _conversionUnits = map[Conversion]string{
+	BytesToKiB: "kibibytes",
+	BytesToMiB: "mebibytes",
+	BytesToGiB: "gibibytes",
+	NsToMs:     "milliseconds",
+	NsToS:      "seconds",
+	MsToS:      "seconds",
+}
+
+// ErrInvalidConfig is returned for invalid rules configuration.
+var ErrInvalidConfig = errors.New("invalid rules configuration")
+
+// Rule describes one processing step.
+//
+// Rule without Name and Pattern matches all metrics.
+type Rule struct {
+	// Action - what to do with matched metric.
+	Action Action
+	// Name - exact metric name to match.
+	Name string
+	// Pattern - regular expression to match the whole metric name.
+	Pattern string
+	// Type - metric type to match, empty value matches all types.
+	Type api.MetricsType
+	// To - new name for rename action, may contain references to capture groups of Pattern like $1.
+	To string
+	// Prefix - prefix for prefix action.
+	Prefix string
+	// Conversion - named unit conversion for convert action.
+	Conversion Conversion
+	// Factor - multiplier for convert action, used if Conversion is empty.
+	Factor float64
+	// Unit - unit of values converted with Factor, it replaces unit in metadata of converted metrics.
+	Unit string
+	// Labels - labels set by label action.
+	Labels map[string]string
+	// Label - label rewritten by relabel action.
+	Label string
+	// LabelPattern - regular expression to match the whole label value for relabel action, empty matches all.
+	LabelPattern string
+	// Replacement - new label value for relabel action, may contain references to capture groups of LabelPattern.
+	// Empty value removes label.
+	Replacement string
+
+	regex      *regexp.Regexp
+	labelRegex *regexp.Regexp
+	factor     float64
+	unit       string
+}
+
+// Config describes configuration of rules processor.
+type Config struct {
+	// Rules - rules applied in order.
+	Rules []Rule
+}
+
+// Validate checks configuration and compiles regex rules.
+//
+// Returned error is config.KeyError naming invalid key like [0].to.
+func (c *Config) Validate() error {
+	for i := range c.Rules {
+		if err := c.Rules[i].validate(); err != nil {
+			return config.Field(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if r.Name != "" && r.Pattern != "" {
+		return invalid("pattern", errors.New("name and pattern are mutually exclusive"))
+	}
+
+	if r.Pattern != "" {
+		regex, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return invalid("pattern", err)
+		}
+
+		r.regex = regex
+	}
+
+	switch r.Type {
+	case "", api.CounterType, api.GaugeType:
+	default:
+		return invalid("type", fmt.Errorf("unknown type %q", r.Type))
+	}
+
+	switch r.Action {
+	case Drop, Keep:
+	case Rename:
+		if r.To == "" {
+			return invalid("to", errors.New("rename requires new name"))
+		}
+
+		// Capture groups are expanded at runtime, so only constant beginning of name is checked
+		if static, _, _ := strings.Cut(r.To, "$"); telemetry.Reserved(static) {
+			return invalid("to", fmt.Errorf("metric name prefix %s is reserved", telemetry.Prefix))
+		}
+	case Prefix:
+		if r.Prefix == "" {
+			return invalid("prefix", errors.New("prefix requires prefix"))
+		}
+
+		if telemetry.Reserved(r.Prefix) {
+			return invalid("prefix", fmt.Errorf("metric name prefix %s is reserved", telemetry.Prefix))
+		}
+	case Convert:
+		return r.validateConversion()
+	case Label:
+		if len(r.Labels) == 0 {
+			return invalid("labels", errors.New("label requires labels"))
+		}
+
+		for name := range r.Labels {
+			if name == "" || strings.ContainsAny(name, ";=") {
+				return invalid("labels", fmt.Errorf("invalid label name %q", name))
+			}
+		}
+	case Relabel:
+		if r.Label == "" {
+			return invalid("label", errors.New("relabel requires label"))
+		}
+
+		pattern := r.LabelPattern
+		if pattern == "" {
+			pattern = ".*"
+		}
+
+		regex, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return invalid("label_pattern", err)
+		}
+
+		r.labelRegex = regex
+	default:
+		return invalid("action", fmt.Errorf("unknown action %q", r.Action))
+	}
+
+	return nil
+}
+
+func (r *Rule) validateConversion() error {
+	if r.Conversion == "" {
+		if r.Factor == 0 {
+			return invalid("convert", errors.New("convert requires conversion or factor"))
+		}
+
+		// Unit of converted values must be replaced, otherwise server keeps unit of source values
+		if r.Unit == "" {
+			return invalid("unit", errors.New("factor requires unit of converted values"))
+		}
+
+		r.factor = r.Factor
+		r.unit = r.Unit
+
+		return nil
+	}
+
+	if r.Factor != 0 {
+		return invalid("factor", errors.New("conversion and factor are mutually exclusive"))
+	}
+
+	if r.Unit != "" {
+		return invalid("unit", errors.New("unit is set by conversion"))
+	}
+
+	factor, ok := _conversions[r.Conversion]
+	if !ok {
+		return invalid("convert", fmt.Errorf("unknown conversion %q", r.Conversion))
+	}
+
+	r.factor = factor
+	r.unit = _conversionUnits[r.Conversion]
+
+	return nil
+}
+
+// invalid returns error of configuration key, reason is wrapped into ErrInvalidConfig.
+func invalid(key string, err error) error {
+	return &config.KeyError{Key: key, Err: fmt.Errorf("%w: %s", ErrInvalidConfig, err)}
+}
+
+func (r *Rule) matches(name string, mType api.MetricsType) bool {
+	if r.Type != "" && r.Type != mType {
+		return false
+	}
+
+	switch {
+	case r.Name != "":
+		return r.Name == name
+	case r.regex != nil:
+		return r.regex.MatchString(name)
+	default:
+		return true
+	}
+}
+
+// Processor describes metrics processor that applies rules.
+type Processor struct {
+	config *Config
+}
+
+// New creates new rules processor.
+//
+// Config must be validated before.
+func New(config *Config) *Processor {
+	return &Processor{config: config}
+}
+
+type key struct {
+	id    string
+	mType api.MetricsType
+}
+
+// Apply returns batch of processed metrics.
+//
+// Given batch is not modified.
+func (p *Processor) Apply(metrics []api.Metrics) []api.Metrics {
+	if len(p.config.Rules) == 0 {
+		return metrics
+	}
+
+	result := make([]api.Metrics, 0, len(metrics))
+	index := make(map[key]int, len(metrics))
+
+	for _, m := range metrics {
+		m, ok := p.apply(m)
+		if !ok {
+			continue
+		}
+
+		k := key{id: m.ID, mType: m.MType}
+
+		i, found := index[k]
+		if !found {
+			index[k] = len(result)
+			result = append(result, m)
+
+			continue
+		}
+
+		if m.MType == api.CounterType {
+			delta := *result[i].Delta + *m.Delta
+			result[i].Delta = &delta
+		} else {
+			result[i].Value = m.Value
+		}
+	}
+
+	return result
+}
+
+// apply applies rules to metric and reports whether metric is kept.
+func (p *Processor) apply(m api.Metrics) (api.Metrics, bool) {
+	name, labels := parseName(m.ID)
+
+	for i := range p.config.Rules {
+		r := &p.config.Rules[i]
+
+		matched := r.matches(name, m.MType)
+
+		if r.Action == Keep {
+			if !matched {
+				return m, false
+			}
+
+			continue
+		}
+
+		if !matched {
+			continue
+		}
+
+		switch r.Action {
+		case Drop:
+			return m, false
+		case Rename:
+			renamed := r.To
+			if r.regex != nil {
+				renamed = r.regex.ReplaceAllString(name, r.To)
+			}
+
+			name = unreserved(name, renamed)
+		case Prefix:
+			name = unreserved(name, r.Prefix+name)
+		case Convert:
+			if m.MType == api.GaugeType && m.Value != nil {
+				value := *m.Value * r.factor
+				m.Value = &value
+
+				if m.Metadata != nil {
+					metadata := *m.Metadata
+					metadata.Unit = r.unit
+					m.Metadata = &metadata
+				}
+			}
+		case Label:
+			for k, v := range r.Labels {
+				labels[k] = v
+			}
+		case Relabel:
+			relabel(r, labels)
+		}
+	}
+
+	m.ID = api.LabeledName(name, labels)
+
+	return m, true
+}
+
+// unreserved returns new name of metric, or old name if new one has reserved prefix of agent self metrics.
+func unreserved(name string, newName string) string {
+	if telemetry.Reserved(newName) {
+		return name
+	}
+
+	return newName
+}
+
+func relabel(r *Rule, labels map[string]string) {
+	value, ok := labels[r.Label]
+	if !ok || !r.labelRegex.MatchString(value) {
+		return
+	}
+
+	value = r.labelRegex.ReplaceAllString(value, r.Replacement)
+	if value == "" {
+		delete(labels, r.Label)
+		return
+	}
+
+	labels[r.Label] = value
+}
+
+// parseName splits metric name to name and labels.
+func parseName(id string) (string, map[string]string) {
+	parts := strings.Split(id, ";")
+	labels := make(map[string]string, len(parts)-1)
+
+	for _, part := range parts[1:] {
+		k, v, _ := strings.Cut(part, "=")
+		labels[k] = v
+	}
+
+	return parts[0], labels
+}
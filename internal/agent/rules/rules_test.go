@@ -0,0 +1,235 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+func gauge(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
+
+func counter(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func TestProcessor_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		metrics []api.Metrics
+		want    []api.Metrics
+	}{
+		{
+			name:    "no rules",
+			metrics: []api.Metrics{gauge("Alloc", 1), counter("PollCount", 2)},
+			want:    []api.Metrics{gauge("Alloc", 1), counter("PollCount", 2)},
+		},
+		{
+			name:    "drop by name",
+			rules:   []Rule{{Action: Drop, Name: "Alloc"}},
+			metrics: []api.Metrics{gauge("Alloc", 1), gauge("HeapAlloc", 2)},
+			want:    []api.Metrics{gauge("HeapAlloc", 2)},
+		},
+		{
+			name:    "drop by pattern matches whole name",
+			rules:   []Rule{{Action: Drop, Pattern: "Heap.*"}},
+			metrics: []api.Metrics{gauge("HeapAlloc", 1), gauge("HeapSys", 2), gauge("GoHeap", 3)},
+			want:    []api.Metrics{gauge("GoHeap", 3)},
+		},
+		{
+			name:    "drop by type",
+			rules:   []Rule{{Action: Drop, Type: api.CounterType}},
+			metrics: []api.Metrics{gauge("Alloc", 1), counter("PollCount", 2)},
+			want:    []api.Metrics{gauge("Alloc", 1)},
+		},
+		{
+			name:    "keep",
+			rules:   []Rule{{Action: Keep, Pattern: "Alloc|PollCount"}},
+			metrics: []api.Metrics{gauge("Alloc", 1), gauge("Frees", 2), counter("PollCount", 3)},
+			want:    []api.Metrics{gauge("Alloc", 1), counter("PollCount", 3)},
+		},
+		{
+			name:    "rename by name",
+			rules:   []Rule{{Action: Rename, Name: "Alloc", To: "memory.alloc"}},
+			metrics: []api.Metrics{gauge("Alloc", 1)},
+			want:    []api.Metrics{gauge("memory.alloc", 1)},
+		},
+		{
+			name:    "rename with capture groups",
+			rules:   []Rule{{Action: Rename, Pattern: "Heap(.+)", To: "heap.$1"}},
+			metrics: []api.Metrics{gauge("HeapSys", 1), gauge("Alloc", 2)},
+			want:    []api.Metrics{gauge("heap.Sys", 1), gauge("Alloc", 2)},
+		},
+		{
+			name:    "rename keeps labels",
+			rules:   []Rule{{Action: Rename, Name: "requests", To: "http.requests"}},
+			metrics: []api.Metrics{counter("requests;code=200", 1)},
+			want:    []api.Metrics{counter("http.requests;code=200", 1)},
+		},
+		{
+			name:    "prefix",
+			rules:   []Rule{{Action: Prefix, Prefix: "service."}},
+			metrics: []api.Metrics{gauge("Alloc", 1), counter("PollCount", 2)},
+			want:    []api.Metrics{gauge("service.Alloc", 1), counter("service.PollCount", 2)},
+		},
+		{
+			name:    "convert bytes to MiB",
+			rules:   []Rule{{Action: Convert, Pattern: ".*Sys", Conversion: BytesToMiB}},
+			metrics: []api.Metrics{gauge("HeapSys", 3*1024*1024), gauge("Alloc", 1024)},
+			want:    []api.Metrics{gauge("HeapSys", 3), gauge("Alloc", 1024)},
+		},
+		{
+			name:    "convert by factor",
+			rules:   []Rule{{Action: Convert, Name: "ratio", Factor: 100, Unit: "percent"}},
+			metrics: []api.Metrics{gauge("ratio", 0.5)},
+			want:    []api.Metrics{gauge("ratio", 50)},
+		},
+		{
+			name:    "rename to reserved name",
+			rules:   []Rule{{Action: Rename, Pattern: "x_(.+)", To: "$1"}},
+			metrics: []api.Metrics{gauge("x_agent.batch_size", 1), gauge("x_Alloc", 2)},
+			want:    []api.Metrics{gauge("x_agent.batch_size", 1), gauge("Alloc", 2)},
+		},
+		{
+			name:    "prefix to reserved name",
+			rules:   []Rule{{Action: Prefix, Prefix: "age"}},
+			metrics: []api.Metrics{gauge("nt.batch_size", 1)},
+			want:    []api.Metrics{gauge("nt.batch_size", 1)},
+		},
+		{
+			name:    "convert skips counters",
+			rules:   []Rule{{Action: Convert, Conversion: BytesToKiB}},
+			metrics: []api.Metrics{counter("bytes", 2048)},
+			want:    []api.Metrics{counter("bytes", 2048)},
+		},
+		{
+			name:    "label",
+			rules:   []Rule{{Action: Label, Labels: map[string]string{"env": "prod", "code": "5;00"}}},
+			metrics: []api.Metrics{counter("requests;code=200;path=/", 1)},
+			want:    []api.Metrics{counter("requests;code=5_00;env=prod;path=/", 1)},
+		},
+		{
+			name:    "relabel",
+			rules:   []Rule{{Action: Relabel, Label: "host", LabelPattern: `(\w+)\.example\.com`, Replacement: "$1"}},
+			metrics: []api.Metrics{gauge("up;host=web1.example.com", 1), gauge("up;host=localhost", 1)},
+			want:    []api.Metrics{gauge("up;host=web1", 1), gauge("up;host=localhost", 1)},
+		},
+		{
+			name:    "relabel removes label",
+			rules:   []Rule{{Action: Relabel, Label: "instance"}},
+			metrics: []api.Metrics{gauge("up;instance=a;job=node", 1)},
+			want:    []api.Metrics{gauge("up;job=node", 1)},
+		},
+		{
+			name: "metrics with the same name are combined",
+			rules: []Rule{
+				{Action: Relabel, Label: "instance"},
+				{Action: Rename, Pattern: "errors_.*", To: "errors"},
+			},
+			metrics: []api.Metrics{
+				counter("requests;instance=a", 1),
+				counter("requests;instance=b", 2),
+				counter("errors_a", 3),
+				counter("errors_b", 4),
+				gauge("up;instance=a", 1),
+				gauge("up;instance=b", 0),
+			},
+			want: []api.Metrics{counter("requests", 3), counter("errors", 7), gauge("up", 0)},
+		},
+		{
+			name: "rules are applied in order",
+			rules: []Rule{
+				{Action: Rename, Name: "Alloc", To: "alloc"},
+				{Action: Drop, Name: "Alloc"},
+				{Action: Prefix, Name: "alloc", Prefix: "mem."},
+			},
+			metrics: []api.Metrics{gauge("Alloc", 1)},
+			want:    []api.Metrics{gauge("mem.alloc", 1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Rules: test.rules}
+			require.NoError(t, config.Validate())
+
+			got := New(config).Apply(test.metrics)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestProcessor_Apply_doesNotModifyBatch(t *testing.T) {
+	config := &Config{Rules: []Rule{
+		{Action: Convert, Conversion: BytesToKiB},
+		{Action: Rename, Pattern: "b", To: "a"},
+	}}
+	require.NoError(t, config.Validate())
+
+	metrics := []api.Metrics{gauge("Alloc", 2048), counter("a", 1), counter("b", 2)}
+
+	New(config).Apply(metrics)
+
+	assert.Equal(t, []api.Metrics{gauge("Alloc", 2048), counter("a", 1), counter("b", 2)}, metrics)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{name: "unknown action", rule: Rule{Action: "hide"}},
+		{name: "name and pattern", rule: Rule{Action: Drop, Name: "a", Pattern: "a"}},
+		{name: "invalid pattern", rule: Rule{Action: Drop, Pattern: "("}},
+		{name: "unknown type", rule: Rule{Action: Drop, Type: "histogram"}},
+		{name: "rename without name", rule: Rule{Action: Rename, Name: "a"}},
+		{name: "empty prefix", rule: Rule{Action: Prefix}},
+		{name: "convert without factor", rule: Rule{Action: Convert}},
+		{name: "unknown conversion", rule: Rule{Action: Convert, Conversion: "bytes_to_pib"}},
+		{name: "conversion and factor", rule: Rule{Action: Convert, Conversion: BytesToMiB, Factor: 2}},
+		{name: "factor without unit", rule: Rule{Action: Convert, Factor: 2}},
+		{name: "conversion and unit", rule: Rule{Action: Convert, Conversion: BytesToMiB, Unit: "bytes"}},
+		{name: "rename to reserved name", rule: Rule{Action: Rename, Pattern: "(.+)", To: "agent.$1"}},
+		{name: "reserved prefix", rule: Rule{Action: Prefix, Prefix: "agent."}},
+		{name: "label without labels", rule: Rule{Action: Label}},
+		{name: "invalid label name", rule: Rule{Action: Label, Labels: map[string]string{"a=b": "c"}}},
+		{name: "relabel without label", rule: Rule{Action: Relabel}},
+		{name: "invalid label pattern", rule: Rule{Action: Relabel, Label: "a", LabelPattern: "["}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Rules: []Rule{test.rule}}
+
+			err := config.Validate()
+
+			assert.True(t, errors.Is(err, ErrInvalidConfig), "got %v", err)
+		})
+	}
+}
+
+func TestProcessor_Apply_convertUnit(t *testing.T) {
+	config := &Config{Rules: []Rule{
+		{Action: Convert, Name: "HeapSys", Conversion: BytesToMiB},
+		{Action: Convert, Name: "ratio", Factor: 100, Unit: "percent"},
+	}}
+	require.NoError(t, config.Validate())
+
+	heap := gauge("HeapSys", 1<<20)
+	heap.Metadata = &api.Metadata{Unit: "bytes", Owner: "platform"}
+	ratio := gauge("ratio", 0.5)
+	ratio.Metadata = &api.Metadata{Unit: "ratio"}
+
+	got := New(config).Apply([]api.Metrics{heap, ratio})
+
+	require.Len(t, got, 2)
+	assert.Equal(t, &api.Metadata{Unit: "mebibytes", Owner: "platform"}, got[0].Metadata)
+	assert.Equal(t, &api.Metadata{Unit: "percent"}, got[1].Metadata)
+}
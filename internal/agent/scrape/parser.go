@@ -0,0 +1,224 @@
+package scrape
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported Prometheus metric family types
+const (
+	counterFamily   = "counter"
+	gaugeFamily     = "gauge"
+	histogramFamily = "histogram"
+	summaryFamily   = "summary"
+	untypedFamily   = "untyped"
+)
+
+// ErrInvalidExposition is returned for invalid Prometheus text exposition.
+var ErrInvalidExposition = errors.New("invalid exposition format")
+
+// label describes one label of sample.
+type label struct {
+	name  string
+	value string
+}
+
+// sample describes one sample of Prometheus text exposition.
+type sample struct {
+	name   string
+	family string
+	kind   string
+	labels []label
+	value  float64
+}
+
+// parse parses Prometheus text exposition format.
+func parse(r io.Reader) ([]sample, error) {
+	types := make(map[string]string)
+
+	var samples []sample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+
+			continue
+		}
+
+		s, err := parseSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidExposition, line, err)
+		}
+
+		s.family, s.kind = family(s.name, types)
+		samples = append(samples, s)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// family finds metric family and its type for sample name.
+func family(name string, types map[string]string) (string, string) {
+	if kind, ok := types[name]; ok {
+		return name, kind
+	}
+
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+
+		if kind, ok := types[base]; ok {
+			return base, kind
+		}
+	}
+
+	return name, untypedFamily
+}
+
+func parseSample(text string) (sample, error) {
+	var s sample
+
+	end := strings.IndexAny(text, "{ \t")
+	if end <= 0 {
+		return s, errors.New("no value")
+	}
+
+	s.name = text[:end]
+	rest := text[end:]
+
+	if rest[0] == '{' {
+		labels, tail, err := parseLabels(rest[1:])
+		if err != nil {
+			return s, err
+		}
+
+		s.labels = labels
+		rest = tail
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, errors.New("expected value and optional timestamp")
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value %q", fields[0])
+	}
+
+	s.value = value
+
+	return s, nil
+}
+
+// parseLabels parses labels after opening brace and returns rest of line after closing brace.
+func parseLabels(text string) ([]label, string, error) {
+	var labels []label
+
+	for {
+		text = strings.TrimLeft(text, " \t")
+
+		if strings.HasPrefix(text, "}") {
+			return labels, text[1:], nil
+		}
+
+		eq := strings.IndexByte(text, '=')
+		if eq <= 0 {
+			return nil, "", errors.New("invalid label")
+		}
+
+		name := strings.TrimSpace(text[:eq])
+		text = strings.TrimLeft(text[eq+1:], " \t")
+
+		if !strings.HasPrefix(text, "\"") {
+			return nil, "", fmt.Errorf("label %s value is not quoted", name)
+		}
+
+		var value strings.Builder
+
+		i := 1
+		for ; i < len(text) && text[i] != '"'; i++ {
+			if text[i] != '\\' {
+				value.WriteByte(text[i])
+				continue
+			}
+
+			i++
+			if i == len(text) {
+				break
+			}
+
+			switch text[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case '\\', '"':
+				value.WriteByte(text[i])
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(text[i])
+			}
+		}
+
+		if i >= len(text) {
+			return nil, "", fmt.Errorf("label %s value is not terminated", name)
+		}
+
+		labels = append(labels, label{name: name, value: value.String()})
+
+		text = strings.TrimLeft(text[i+1:], " \t")
+		text = strings.TrimPrefix(text, ",")
+	}
+}
+
+// flatten builds metric name from sample name and labels.
+//
+// Labels are sorted by name and appended in format name;label1=value1;label2=value2.
+func flatten(name string, labels []label) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	sorted := make([]label, len(labels))
+	copy(sorted, labels)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+
+	var b strings.Builder
+
+	b.WriteString(name)
+
+	replacer := strings.NewReplacer(";", "_", "=", "_")
+
+	for _, l := range sorted {
+		b.WriteByte(';')
+		b.WriteString(replacer.Replace(l.name))
+		b.WriteByte('=')
+		b.WriteString(replacer.Replace(l.value))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,217 @@
+// Package scrape contains collector that scrapes Prometheus metrics endpoints.
+//
+// Endpoints must serve Prometheus text exposition format. Gauges and untyped metrics are saved
+// as gauges, counters are converted to deltas between scrapes and saved as counters.
+// Agent counters are integer, so counters with fractional first value are saved as gauges
+// with their cumulative value. Histograms and summaries contribute their _count series
+// as counters, _sum series and quantiles as gauges, histogram buckets are skipped. Labels are flattened into metric name
+// in format name;label1=value1;label2=value2 as metric server does not support labels.
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+// ErrInvalidConfig is returned for invalid scrape target configuration.
+var ErrInvalidConfig = errors.New("invalid scrape configuration")
+
+// Storage describes methods of agent storage used by collector.
+type Storage interface {
+	// UpdateGauge sets value of gauge metric.
+	UpdateGauge(name string, value float64)
+	// UpdateCounter adds value to counter metric.
+	UpdateCounter(name string, value int64)
+}
+
+// Target describes one scraped endpoint.
+type Target struct {
+	// Name - unique name of target, used for error counter.
+	Name string
+	// URL - address of Prometheus metrics endpoint.
+	URL string
+	// Prefix - prefix added to names of all scraped metrics.
+	Prefix string
+	// Interval - interval between scrapes.
+	Interval time.Duration
+	// Timeout - maximal duration of one scrape.
+	Timeout time.Duration
+}
+
+// Validate checks configuration of target.
+//
+// Returned error is config.KeyError naming invalid key.
+func (t *Target) Validate() error {
+	if t.Name == "" {
+		return invalid("name", fmt.Errorf("%w: empty name", ErrInvalidConfig))
+	}
+
+	if telemetry.Reserved(t.Name + ".errors") {
+		return invalid("name", fmt.Errorf("%w: name prefix %s is reserved", ErrInvalidConfig, telemetry.Prefix))
+	}
+
+	if telemetry.Reserved(t.Prefix) {
+		return invalid("prefix", fmt.Errorf("%w: prefix %s is reserved", ErrInvalidConfig, telemetry.Prefix))
+	}
+
+	if t.URL == "" {
+		return invalid("url", fmt.Errorf("%w: empty URL for %s", ErrInvalidConfig, t.Name))
+	}
+
+	if t.Interval <= 0 {
+		return invalid("interval", fmt.Errorf("%w: interval must be positive for %s", ErrInvalidConfig, t.Name))
+	}
+
+	if t.Timeout <= 0 {
+		return invalid("timeout", fmt.Errorf("%w: timeout must be positive for %s", ErrInvalidConfig, t.Name))
+	}
+
+	return nil
+}
+
+// invalid returns error of configuration key.
+func invalid(key string, err error) error {
+	return &config.KeyError{Key: key, Err: err}
+}
+
+// Scraper describes collector for one target.
+type Scraper struct {
+	target  *Target
+	storage Storage
+	client  *resty.Client
+	last    map[string]float64
+	// fractional contains cumulative series saved as gauges
+	fractional map[string]struct{}
+}
+
+// New creates new collector for given target.
+func New(target *Target, storage Storage) *Scraper {
+	client := resty.New()
+	client.SetTimeout(target.Timeout)
+
+	return &Scraper{
+		target:  target,
+		storage: storage,
+		client:  client,
+		last:    make(map[string]float64),
+
+		fractional: make(map[string]struct{}),
+	}
+}
+
+// Run scrapes target with interval until context is cancelled.
+func (s *Scraper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.target.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Scraper %s terminated", s.target.Name)
+			return
+		case <-ticker.C:
+			start := time.Now()
+
+			if err := s.scrape(ctx); err != nil {
+				log.Printf("scrape %s failed: %s", s.target.Name, err)
+				s.storage.UpdateCounter(s.target.Name+".errors", 1)
+			}
+
+			s.storage.UpdateGauge(telemetry.PollDuration("scrape."+s.target.Name), time.Since(start).Seconds())
+		}
+	}
+}
+
+func (s *Scraper) scrape(ctx context.Context) error {
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "text/plain;version=0.0.4").
+		Get(s.target.URL)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("received status code %d", resp.StatusCode())
+	}
+
+	samples, err := parse(bytes.NewReader(resp.Body()))
+	if err != nil {
+		return err
+	}
+
+	for _, sm := range samples {
+		s.apply(sm)
+	}
+
+	return nil
+}
+
+func (s *Scraper) apply(sm sample) {
+	if math.IsNaN(sm.value) || math.IsInf(sm.value, 0) {
+		return
+	}
+
+	name := s.target.Prefix + flatten(sm.name, sm.labels)
+	if telemetry.Reserved(name) {
+		return
+	}
+
+	switch sm.kind {
+	case counterFamily:
+		s.cumulative(name, sm.value)
+	case histogramFamily, summaryFamily:
+		switch {
+		case strings.HasSuffix(sm.name, "_count"):
+			s.cumulative(name, sm.value)
+		case strings.HasSuffix(sm.name, "_sum"), sm.kind == summaryFamily:
+			s.storage.UpdateGauge(name, sm.value)
+		}
+	default:
+		s.storage.UpdateGauge(name, sm.value)
+	}
+}
+
+// cumulative saves increment of cumulative value since previous scrape as counter.
+//
+// The first value of series is only remembered. Decreased value means counter reset.
+// Deltas are taken between integer parts of cumulative values, so fractional remainder
+// is carried to the next scrapes. Series with fractional first value is saved as gauge.
+func (s *Scraper) cumulative(name string, value float64) {
+	prev, ok := s.last[name]
+	if !ok && value != math.Trunc(value) {
+		s.fractional[name] = struct{}{}
+	}
+
+	if _, ok := s.fractional[name]; ok {
+		s.storage.UpdateGauge(name, value)
+		return
+	}
+
+	s.last[name] = value
+
+	if !ok {
+		return
+	}
+
+	delta := math.Floor(value) - math.Floor(prev)
+	if value < prev {
+		delta = math.Floor(value)
+	}
+
+	if delta != 0 {
+		s.storage.UpdateCounter(name, int64(delta))
+	}
+}
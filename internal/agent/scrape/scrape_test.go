@@ -0,0 +1,175 @@
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/agent/agenttest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []sample
+		wantErr bool
+	}{
+		{
+			name:  "typed counter with labels",
+			input: "# TYPE requests_total counter\nrequests_total{code=\"200\", method=\"get\",} 10 1395066363000\n",
+			want: []sample{
+				{
+					name:   "requests_total",
+					family: "requests_total",
+					kind:   counterFamily,
+					labels: []label{{"code", "200"}, {"method", "get"}},
+					value:  10,
+				},
+			},
+		},
+		{
+			name:  "escaped label value",
+			input: `path{value="a\\b\"c\nd"} 1`,
+			want: []sample{
+				{
+					name:   "path",
+					family: "path",
+					kind:   untypedFamily,
+					labels: []label{{"value", "a\\b\"c\nd"}},
+					value:  1,
+				},
+			},
+		},
+		{
+			name:  "histogram series",
+			input: "# TYPE latency histogram\nlatency_count 4\n",
+			want: []sample{
+				{
+					name:   "latency_count",
+					family: "latency",
+					kind:   histogramFamily,
+					value:  4,
+				},
+			},
+		},
+		{
+			name:    "no value",
+			input:   "requests_total\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			input:   "requests_total abc\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated label",
+			input:   `requests_total{code="200} 1`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples, err := parse(strings.NewReader(test.input))
+
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidExposition)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, samples)
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	assert.Equal(t, "requests", flatten("requests", nil))
+	assert.Equal(
+		t,
+		"requests;code=200;method=a_b",
+		flatten("requests", []label{{"method", "a;b"}, {"code", "200"}}),
+	)
+}
+
+func TestScraper(t *testing.T) {
+	fixture := "testdata/metrics_1.txt"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	s := agenttest.NewStorage()
+	scraper := New(&Target{
+		Name:     "app",
+		URL:      srv.URL,
+		Prefix:   "app.",
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}, s)
+
+	require.NoError(t, scraper.scrape(context.Background()))
+
+	assert.Equal(t, 2.097152e7, s.Gauges["app.process_resident_memory_bytes"])
+	assert.Equal(t, 12.47, s.Gauges["app.metric_without_timestamp_and_labels"])
+	assert.Equal(t, 4773.0, s.Gauges["app.rpc_duration_seconds;quantile=0.5"])
+	assert.Equal(t, 1.458255915e9, s.Gauges["app.msdos_file_access_time_seconds;error=Cannot find file:\n\"FILE.TXT\";path=C:\\DIR\\FILE.TXT"])
+	assert.NotContains(t, s.Gauges, "app.something_weird;problem=division by zero")
+	assert.NotContains(t, s.Gauges, "app.http_request_duration_seconds_bucket;le=0.05")
+	assert.Equal(t, 53423.0, s.Gauges["app.http_request_duration_seconds_sum"])
+	assert.Equal(t, 0.25, s.Gauges["app.process_cpu_seconds_total"])
+	assert.Empty(t, s.Counters)
+
+	fixture = "testdata/metrics_2.txt"
+
+	require.NoError(t, scraper.scrape(context.Background()))
+
+	assert.Equal(t, 2.5e7, s.Gauges["app.process_resident_memory_bytes"])
+	assert.Equal(t, int64(3), s.Counters["app.http_requests_total;code=200;method=post"])
+	assert.Equal(t, int64(1), s.Counters["app.http_requests_total;code=400;method=post"])
+	assert.Equal(t, int64(10), s.Counters["app.http_request_duration_seconds_count"])
+	assert.Equal(t, 53424.5, s.Gauges["app.http_request_duration_seconds_sum"])
+	assert.Equal(t, int64(7), s.Counters["app.rpc_duration_seconds_count"])
+	assert.Equal(t, 1.7560573e+07, s.Gauges["app.rpc_duration_seconds_sum"])
+	assert.Equal(t, 1.75, s.Gauges["app.process_cpu_seconds_total"])
+	assert.Equal(t, int64(2), s.Counters["app.jobs_total"])
+	assert.Equal(t, 4800.0, s.Gauges["app.rpc_duration_seconds;quantile=0.5"])
+	assert.NotContains(t, s.Counters, "app.http_request_duration_seconds_bucket;le=0.05")
+	assert.NotContains(t, s.Counters, "app.http_request_duration_seconds_sum")
+	assert.NotContains(t, s.Counters, "app.process_cpu_seconds_total")
+
+	fixture = "testdata/missing.txt"
+
+	assert.Error(t, scraper.scrape(context.Background()))
+}
+
+func TestTarget_Validate(t *testing.T) {
+	valid := Target{Name: "app", URL: "http://localhost:9100/metrics", Prefix: "app.", Interval: time.Second, Timeout: time.Second}
+
+	assert.NoError(t, valid.Validate())
+
+	reservedPrefix := valid
+	reservedPrefix.Prefix = "agent.app."
+	assert.ErrorIs(t, reservedPrefix.Validate(), ErrInvalidConfig)
+
+	reservedName := valid
+	reservedName.Name = "agent"
+	assert.ErrorIs(t, reservedName.Validate(), ErrInvalidConfig)
+}
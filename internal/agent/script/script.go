@@ -0,0 +1,261 @@
+// Package script contains collector that runs external commands and collects metrics from their output.
+//
+// Command output is parsed either as text lines "<name> <type> <value>" or as JSON array of metrics,
+// output with not finite gauge values is rejected.
+// Every failed, timed out or rejected run increments "<script name>.errors" counter.
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+// Format describes format of command output.
+type Format string
+
+// Supported output formats
+const (
+	TextFormat Format = "text" // lines in format "<name> <type> <value>"
+	JSONFormat Format = "json" // JSON array of metrics
+)
+
+const (
+	_waitDelay = time.Second
+)
+
+// Sentinel errors for script collector.
+var (
+	ErrInvalidConfig = errors.New("invalid script configuration")
+	ErrTimeout       = errors.New("script timed out")
+	ErrInvalidOutput = errors.New("invalid script output")
+)
+
+// Storage describes methods of agent storage used by collector.
+type Storage interface {
+	// UpdateGauge sets value of gauge metric.
+	UpdateGauge(name string, value float64)
+	// UpdateCounter adds value to counter metric.
+	UpdateCounter(name string, value int64)
+}
+
+// Config describes configuration of one script.
+type Config struct {
+	// Name - unique name of script, used for error counter.
+	Name string
+	// Command - path to executable.
+	Command string
+	// Args - arguments of command.
+	Args []string
+	// Interval - interval between runs.
+	Interval time.Duration
+	// Timeout - maximal duration of one run.
+	Timeout time.Duration
+	// Format - format of command output.
+	Format Format
+}
+
+// Validate checks configuration of script.
+//
+// Returned error is config.KeyError naming invalid key.
+func (c *Config) Validate() error {
+	if c.Name == "" {
+		return invalid("name", fmt.Errorf("%w: empty name", ErrInvalidConfig))
+	}
+
+	if telemetry.Reserved(c.Name + ".errors") {
+		return invalid("name", fmt.Errorf("%w: name prefix %s is reserved", ErrInvalidConfig, telemetry.Prefix))
+	}
+
+	if c.Command == "" {
+		return invalid("command", fmt.Errorf("%w: empty command for %s", ErrInvalidConfig, c.Name))
+	}
+
+	if c.Interval <= 0 {
+		return invalid("interval", fmt.Errorf("%w: interval must be positive for %s", ErrInvalidConfig, c.Name))
+	}
+
+	if c.Timeout <= 0 {
+		return invalid("timeout", fmt.Errorf("%w: timeout must be positive for %s", ErrInvalidConfig, c.Name))
+	}
+
+	if c.Format != TextFormat && c.Format != JSONFormat {
+		return invalid("format", fmt.Errorf("%w: unknown format %q for %s", ErrInvalidConfig, c.Format, c.Name))
+	}
+
+	return nil
+}
+
+// invalid returns error of configuration key.
+func invalid(key string, err error) error {
+	return &config.KeyError{Key: key, Err: err}
+}
+
+// Collector describes collector for one script.
+type Collector struct {
+	config  *Config
+	storage Storage
+}
+
+// New creates new collector for given script.
+func New(config *Config, storage Storage) *Collector {
+	return &Collector{
+		config:  config,
+		storage: storage,
+	}
+}
+
+// Run runs script with interval until context is cancelled.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Script %s terminated", c.config.Name)
+			return
+		case <-ticker.C:
+			start := time.Now()
+
+			c.collect(ctx)
+
+			c.storage.UpdateGauge(telemetry.PollDuration("script."+c.config.Name), time.Since(start).Seconds())
+		}
+	}
+}
+
+func (c *Collector) collect(ctx context.Context) {
+	metrics, err := c.execute(ctx)
+	if err != nil {
+		log.Printf("script %s failed: %s", c.config.Name, err)
+		c.storage.UpdateCounter(c.config.Name+".errors", 1)
+		return
+	}
+
+	for _, m := range metrics {
+		if telemetry.Reserved(m.ID) {
+			log.Printf("script %s: metric name prefix %s is reserved, %s is skipped", c.config.Name, telemetry.Prefix, m.ID)
+			continue
+		}
+
+		if m.MType == api.CounterType {
+			c.storage.UpdateCounter(m.ID, *m.Delta)
+		} else {
+			c.storage.UpdateGauge(m.ID, *m.Value)
+		}
+	}
+}
+
+func (c *Collector) execute(ctx context.Context) ([]api.Metrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.config.Command, c.config.Args...)
+	cmd.WaitDelay = _waitDelay
+
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, ErrTimeout
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.Format == JSONFormat {
+		return parseJSON(out)
+	}
+
+	return parseText(out)
+}
+
+func parseText(out []byte) ([]api.Metrics, error) {
+	var metrics []api.Metrics
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d: expected \"<name> <type> <value>\"", ErrInvalidOutput, line)
+		}
+
+		m := api.Metrics{
+			ID:    fields[0],
+			MType: api.MetricsType(fields[1]),
+		}
+
+		switch m.MType {
+		case api.GaugeType:
+			value, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidOutput, line, err)
+			}
+
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("%w: line %d: value %s is not finite", ErrInvalidOutput, line, fields[2])
+			}
+
+			m.Value = &value
+		case api.CounterType:
+			delta, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidOutput, line, err)
+			}
+
+			m.Delta = &delta
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown metric type %q", ErrInvalidOutput, line, fields[1])
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutput, err)
+	}
+
+	return metrics, nil
+}
+
+func parseJSON(out []byte) ([]api.Metrics, error) {
+	var metrics []api.Metrics
+
+	if err := json.Unmarshal(out, &metrics); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutput, err)
+	}
+
+	for _, m := range metrics {
+		if m.ID == "" {
+			return nil, fmt.Errorf("%w: empty metric name", ErrInvalidOutput)
+		}
+
+		switch {
+		case m.MType == api.GaugeType && m.Value != nil && !math.IsNaN(*m.Value) && !math.IsInf(*m.Value, 0):
+		case m.MType == api.CounterType && m.Delta != nil:
+		default:
+			return nil, fmt.Errorf("%w: invalid metric %s", ErrInvalidOutput, m.ID)
+		}
+	}
+
+	return metrics, nil
+}
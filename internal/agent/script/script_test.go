@@ -0,0 +1,146 @@
+package script
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kaa-it/go-devops/internal/agent/agenttest"
+)
+
+func TestCollector_collect(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		format       Format
+		timeout      time.Duration
+		wantGauges   map[string]float64
+		wantCounters map[string]int64
+	}{
+		{
+			name:         "text output",
+			script:       "echo '# disk check'; echo 'DiskFree gauge 12.5'; echo; echo 'Checks counter 2'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{"DiskFree": 12.5},
+			wantCounters: map[string]int64{"Checks": 2},
+		},
+		{
+			name:         "json output",
+			script:       `echo '[{"id":"DiskFree","type":"gauge","value":3},{"id":"Checks","type":"counter","delta":1}]'`,
+			format:       JSONFormat,
+			wantGauges:   map[string]float64{"DiskFree": 3},
+			wantCounters: map[string]int64{"Checks": 1},
+		},
+		{
+			name:         "invalid text line",
+			script:       "echo 'DiskFree gauge 12.5'; echo 'Checks counter'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "reserved metric name",
+			script:       "echo 'agent.reports_sent counter 5'; echo 'Checks counter 2'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"Checks": 2},
+		},
+		{
+			name:         "unknown metric type",
+			script:       "echo 'DiskFree histogram 12.5'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "not finite gauge",
+			script:       "echo 'DiskFree gauge NaN'; echo 'Checks counter 2'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "infinite gauge",
+			script:       "echo 'DiskFree gauge +Inf'",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "invalid json",
+			script:       `echo '[{"id":"DiskFree","type":"gauge"}]'`,
+			format:       JSONFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "failed command",
+			script:       "echo 'DiskFree gauge 12.5'; exit 2",
+			format:       TextFormat,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+		{
+			name:         "timeout",
+			script:       "sleep 5",
+			format:       TextFormat,
+			timeout:      50 * time.Millisecond,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{"check.errors": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeout := test.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+
+			s := agenttest.NewStorage()
+			c := New(&Config{
+				Name:     "check",
+				Command:  "sh",
+				Args:     []string{"-c", test.script},
+				Interval: time.Second,
+				Timeout:  timeout,
+				Format:   test.format,
+			}, s)
+
+			c.collect(context.Background())
+
+			assert.Equal(t, test.wantGauges, s.Gauges)
+			assert.Equal(t, test.wantCounters, s.Counters)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		Name:     "check",
+		Command:  "true",
+		Interval: time.Second,
+		Timeout:  time.Second,
+		Format:   TextFormat,
+	}
+
+	assert.NoError(t, valid.Validate())
+
+	noName := valid
+	noName.Name = ""
+	assert.ErrorIs(t, noName.Validate(), ErrInvalidConfig)
+
+	badFormat := valid
+	badFormat.Format = "xml"
+	assert.ErrorIs(t, badFormat.Validate(), ErrInvalidConfig)
+
+	noInterval := valid
+	noInterval.Interval = 0
+	assert.ErrorIs(t, noInterval.Validate(), ErrInvalidConfig)
+
+	reservedName := valid
+	reservedName.Name = "agent"
+	assert.ErrorIs(t, reservedName.Validate(), ErrInvalidConfig)
+}
@@ -0,0 +1,131 @@
+// Package spool contains file storage for batches of metrics that agent failed to send.
+//
+// Every batch is saved as separate JSON line, so new batches are appended without reading the file.
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+const (
+	_maxLineSize = 64 * 1024 * 1024
+)
+
+// Spool describes file storage for unsent batches.
+type Spool struct {
+	mu   sync.Mutex
+	path string
+	// depth - amount of batches known to be saved in file
+	depth    int
+	onResize func(delta int)
+}
+
+// New creates spool that saves batches to file with given path.
+//
+// onResize, if not nil, is called with change of amount of saved batches
+// after they are appended, loaded or cleared.
+func New(path string, onResize func(delta int)) *Spool {
+	return &Spool{path: path, onResize: onResize}
+}
+
+// Append saves batch to the end of spool.
+func (s *Spool) Append(metrics []api.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	s.resize(s.depth + 1)
+
+	return nil
+}
+
+// Load returns all saved batches in order they were appended.
+//
+// Returns no batches if spool file does not exist.
+func (s *Spool) Load() ([][]api.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.resize(0)
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	var batches [][]api.Metrics
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, _maxLineSize)
+
+	for scanner.Scan() {
+		var batch []api.Metrics
+
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			return nil, fmt.Errorf("failed to decode batch: %w", err)
+		}
+
+		batches = append(batches, batch)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	s.resize(len(batches))
+
+	return batches, nil
+}
+
+// Clear removes all saved batches.
+func (s *Spool) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	s.resize(0)
+
+	return nil
+}
+
+// resize sets amount of saved batches and reports its change.
+func (s *Spool) resize(depth int) {
+	delta := depth - s.depth
+	s.depth = depth
+
+	if s.onResize != nil && delta != 0 {
+		s.onResize(delta)
+	}
+}
@@ -0,0 +1,60 @@
+package spool
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+func TestSpool(t *testing.T) {
+	var depth int
+
+	s := New(filepath.Join(t.TempDir(), "spool.json"), func(delta int) { depth += delta })
+
+	batches, err := s.Load()
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+
+	value := 1.5
+	delta := int64(3)
+
+	first := []api.Metrics{{ID: "Gauge", MType: api.GaugeType, Value: &value}}
+	second := []api.Metrics{{ID: "Counter", MType: api.CounterType, Delta: &delta}}
+
+	require.NoError(t, s.Append(first))
+	require.NoError(t, s.Append(second))
+	assert.Equal(t, 2, depth)
+
+	batches, err = s.Load()
+	require.NoError(t, err)
+	assert.Equal(t, [][]api.Metrics{first, second}, batches)
+	assert.Equal(t, 2, depth)
+
+	require.NoError(t, s.Clear())
+	require.NoError(t, s.Clear())
+	assert.Equal(t, 0, depth)
+
+	batches, err = s.Load()
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestSpool_loadDepth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.json")
+	delta := int64(1)
+
+	require.NoError(t, New(path, nil).Append([]api.Metrics{{ID: "Counter", MType: api.CounterType, Delta: &delta}}))
+
+	// Batches saved by previous run are counted when loaded
+	var depth int
+
+	s := New(path, func(delta int) { depth += delta })
+
+	_, err := s.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 1, depth)
+}
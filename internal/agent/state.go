@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/kaa-it/go-devops/internal/agent/status"
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+const (
+	_redacted = "[REDACTED]"
+)
+
+// state keeps states of collectors for status endpoint.
+type state struct {
+	mu         sync.Mutex
+	collectors map[string]status.Collector
+}
+
+func newState() *state {
+	return &state{
+		collectors: make(map[string]status.Collector),
+	}
+}
+
+func (s *state) setCollector(name string, collectorState string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := status.Collector{
+		Name:  name,
+		State: collectorState,
+	}
+
+	if err != nil {
+		c.State = status.Failed
+		c.Error = err.Error()
+	}
+
+	s.collectors[name] = c
+}
+
+func (s *state) collectorList() []status.Collector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collectors := make([]status.Collector, 0, len(s.collectors))
+	for _, c := range s.collectors {
+		collectors = append(collectors, c)
+	}
+
+	slices.SortFunc(collectors, func(a, b status.Collector) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return collectors
+}
+
+// Status returns current state of agent for status endpoint.
+func (a *Agent) Status() status.Status {
+	destinations := make([]status.Destination, 0, len(a.destinations))
+	for _, d := range a.destinations {
+		destinations = append(destinations, d.status())
+	}
+
+	return status.Status{
+		Config:       newConfigSummary(a.config),
+		Destinations: destinations,
+		Collectors:   a.state.collectorList(),
+	}
+}
+
+// Metrics returns metrics that will be sent with the next report, sorted by type and name.
+func (a *Agent) Metrics() []api.Metrics {
+	var metrics []api.Metrics
+
+	a.storage.ForEachCounter(func(key string, value int64) {
+		metrics = a.applyCounter(key, value, metrics)
+	})
+
+	a.storage.ForEachGauge(func(key string, value float64) {
+		metrics = a.applyGauge(key, value, metrics)
+	})
+
+	slices.SortFunc(metrics, func(a, b api.Metrics) int {
+		return cmp.Or(cmp.Compare(a.MType, b.MType), cmp.Compare(a.ID, b.ID))
+	})
+
+	return metrics
+}
+
+// configSummary describes agent configuration shown by status endpoint.
+//
+// Secrets are redacted, script arguments are omitted as they may contain credentials.
+type configSummary struct {
+	Destinations    []destinationSummary `json:"destinations"`
+	PollInterval    string               `json:"poll_interval"`
+	ReportInterval  string               `json:"report_interval"`
+	RateLimit       int                  `json:"rate_limit"`
+	ShutdownTimeout string               `json:"shutdown_timeout"`
+	SpoolPath       string               `json:"spool_path,omitempty"`
+	StatsDAddress   string               `json:"statsd_address,omitempty"`
+	StatsDSocket    string               `json:"statsd_socket,omitempty"`
+	PushAddress     string               `json:"push_address,omitempty"`
+	StatusAddress   string               `json:"status_address,omitempty"`
+	Scripts         []string             `json:"scripts,omitempty"`
+	Scrape          []string             `json:"scrape,omitempty"`
+	Tail            []string             `json:"tail,omitempty"`
+}
+
+type destinationSummary struct {
+	Name             string `json:"name,omitempty"`
+	Address          string `json:"address"`
+	Transport        string `json:"transport"`
+	CACertPath       string `json:"ca_cert,omitempty"`
+	Key              string `json:"key,omitempty"`
+	PublicKeyPath    string `json:"crypto_key,omitempty"`
+	RetryCount       int    `json:"retry_count"`
+	RetryWait        string `json:"retry_wait"`
+	RetryMaxWait     string `json:"retry_max_wait"`
+	BreakerThreshold int    `json:"breaker_threshold"`
+	BreakerCooldown  string `json:"breaker_cooldown"`
+}
+
+func newConfigSummary(c *Config) configSummary {
+	summary := configSummary{
+		PollInterval:    c.Agent.PollInterval.String(),
+		ReportInterval:  c.Agent.ReportInterval.String(),
+		RateLimit:       c.Agent.RateLimit,
+		ShutdownTimeout: c.Agent.ShutdownTimeout.String(),
+		SpoolPath:       c.Agent.SpoolPath,
+		StatsDAddress:   c.StatsD.Address,
+		StatsDSocket:    c.StatsD.UnixSocketPath,
+		PushAddress:     c.Push.Address,
+		StatusAddress:   c.Status.Address,
+	}
+
+	for _, d := range c.Destinations {
+		ds := destinationSummary{
+			Name:             d.Name,
+			Address:          d.Address,
+			Transport:        d.Transport,
+			CACertPath:       d.CACertPath,
+			PublicKeyPath:    d.PublicKeyPath,
+			RetryCount:       d.Retry.MaxRetries,
+			RetryWait:        d.Retry.BaseDelay.String(),
+			RetryMaxWait:     d.Retry.MaxDelay.String(),
+			BreakerThreshold: d.BreakerThreshold,
+			BreakerCooldown:  d.BreakerCooldown.String(),
+		}
+
+		if d.Key != "" {
+			ds.Key = _redacted
+		}
+
+		summary.Destinations = append(summary.Destinations, ds)
+	}
+
+	for _, s := range c.Scripts {
+		summary.Scripts = append(summary.Scripts, s.Name+": "+s.Command)
+	}
+
+	for _, t := range c.Scrape {
+		summary.Scrape = append(summary.Scrape, t.Name+": "+redactURL(t.URL))
+	}
+
+	for _, f := range c.Tail.Files {
+		summary.Tail = append(summary.Tail, f.Path)
+	}
+
+	return summary
+}
+
+// redactURL hides password and query of URL as they may contain credentials.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return _redacted
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery = _redacted
+	}
+
+	return u.Redacted()
+}
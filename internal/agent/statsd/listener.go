@@ -0,0 +1,252 @@
+// Package statsd contains local StatsD listener for metric agent.
+//
+// Counters and gauges are applied to agent storage immediately.
+// Timers, histograms and sets are aggregated and flushed to storage with interval:
+// timers and histograms as gauges name.min, name.max, name.mean and counter name.count,
+// sets as gauge name.unique with amount of unique members, so they do not overwrite gauges with the same name.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	statsdLib "github.com/kaa-it/go-devops/internal/statsd"
+)
+
+const (
+	_maxPacketSize = 65535
+)
+
+// Storage describes methods of agent storage used by listener.
+type Storage interface {
+	// UpdateGauge sets value of gauge metric.
+	UpdateGauge(name string, value float64)
+	// AddGauge adds delta to value of gauge metric.
+	AddGauge(name string, delta float64)
+	// UpdateCounter adds value to counter metric.
+	UpdateCounter(name string, value int64)
+}
+
+// Config describes configuration of StatsD listener.
+type Config struct {
+	// Address - UDP address to listen, empty value disables UDP listener.
+	Address string
+	// UnixSocketPath - path to Unix datagram socket to listen, empty value disables Unix listener.
+	UnixSocketPath string
+	// FlushInterval - interval for flushing aggregated timers, histograms and sets to storage.
+	FlushInterval time.Duration
+}
+
+// Enabled returns true if at least one socket to listen is configured.
+func (c *Config) Enabled() bool {
+	return c.Address != "" || c.UnixSocketPath != ""
+}
+
+type timer struct {
+	count float64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// Listener describes StatsD listener.
+type Listener struct {
+	config  *Config
+	storage Storage
+
+	mu     sync.Mutex
+	timers map[string]*timer
+	sets   map[string]map[string]struct{}
+}
+
+// New creates new StatsD listener that saves received metrics to given storage.
+func New(config *Config, storage Storage) *Listener {
+	return &Listener{
+		config:  config,
+		storage: storage,
+		timers:  make(map[string]*timer),
+		sets:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Run listens configured sockets until context is cancelled.
+//
+// Aggregated metrics are flushed to storage before return.
+func (l *Listener) Run(ctx context.Context) error {
+	conns, err := l.listen()
+	if err != nil {
+		return err
+	}
+
+	wg := new(sync.WaitGroup)
+	wg.Add(len(conns))
+
+	for _, conn := range conns {
+		go l.serve(conn, wg)
+	}
+
+	flushTicker := time.NewTicker(l.config.FlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+
+			wg.Wait()
+
+			if l.config.UnixSocketPath != "" {
+				_ = os.Remove(l.config.UnixSocketPath)
+			}
+
+			l.flush()
+
+			log.Println("StatsD listener terminated")
+			return nil
+		case <-flushTicker.C:
+			l.flush()
+		}
+	}
+}
+
+func (l *Listener) listen() ([]net.PacketConn, error) {
+	var conns []net.PacketConn
+
+	if l.config.Address != "" {
+		conn, err := net.ListenPacket("udp", l.config.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		conns = append(conns, conn)
+	}
+
+	if l.config.UnixSocketPath != "" {
+		// Remove socket file left by previous run
+		_ = os.Remove(l.config.UnixSocketPath)
+
+		conn, err := net.ListenPacket("unixgram", l.config.UnixSocketPath)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+
+			return nil, err
+		}
+
+		conns = append(conns, conn)
+	}
+
+	return conns, nil
+}
+
+func (l *Listener) serve(conn net.PacketConn, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	buf := make([]byte, _maxPacketSize)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("failed to read StatsD packet: %s", err)
+			}
+
+			return
+		}
+
+		l.handle(buf[:n])
+	}
+}
+
+func (l *Listener) handle(packet []byte) {
+	samples, errs := statsdLib.ParsePacket(packet)
+
+	for _, err := range errs {
+		log.Printf("invalid StatsD line: %s", err)
+	}
+
+	for _, s := range samples {
+		if telemetry.Reserved(s.Name) {
+			log.Printf("invalid StatsD line: metric name prefix %s is reserved", telemetry.Prefix)
+			continue
+		}
+
+		l.apply(s)
+	}
+}
+
+func (l *Listener) apply(s statsdLib.Sample) {
+	switch s.Type {
+	case statsdLib.CounterType:
+		l.storage.UpdateCounter(s.Name, int64(math.Round(s.Value/s.SampleRate)))
+	case statsdLib.GaugeType:
+		if s.Relative {
+			l.storage.AddGauge(s.Name, s.Value)
+		} else {
+			l.storage.UpdateGauge(s.Name, s.Value)
+		}
+	case statsdLib.TimerType, statsdLib.HistogramType:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		weight := 1 / s.SampleRate
+
+		t, ok := l.timers[s.Name]
+		if !ok {
+			l.timers[s.Name] = &timer{
+				count: weight,
+				sum:   s.Value * weight,
+				min:   s.Value,
+				max:   s.Value,
+			}
+
+			return
+		}
+
+		t.count += weight
+		t.sum += s.Value * weight
+		t.min = math.Min(t.min, s.Value)
+		t.max = math.Max(t.max, s.Value)
+	case statsdLib.SetType:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		members, ok := l.sets[s.Name]
+		if !ok {
+			members = make(map[string]struct{})
+			l.sets[s.Name] = members
+		}
+
+		members[s.Member] = struct{}{}
+	}
+}
+
+func (l *Listener) flush() {
+	l.mu.Lock()
+	timers := l.timers
+	sets := l.sets
+	l.timers = make(map[string]*timer)
+	l.sets = make(map[string]map[string]struct{})
+	l.mu.Unlock()
+
+	for name, t := range timers {
+		l.storage.UpdateGauge(name+".min", t.min)
+		l.storage.UpdateGauge(name+".max", t.max)
+		l.storage.UpdateGauge(name+".mean", t.sum/t.count)
+		l.storage.UpdateCounter(name+".count", int64(math.Round(t.count)))
+	}
+
+	for name, members := range sets {
+		l.storage.UpdateGauge(name+".unique", float64(len(members)))
+	}
+}
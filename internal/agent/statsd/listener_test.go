@@ -0,0 +1,76 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/agent/agenttest"
+)
+
+func TestListener_handle(t *testing.T) {
+	s := agenttest.NewStorage()
+	l := New(&Config{FlushInterval: time.Second}, s)
+
+	l.handle([]byte("requests:1|c\nrequests:2|c|@0.5\ntemp:10|g\ntemp:-3|g\ninvalid\nagent.reports_sent:1|c\n"))
+	l.handle([]byte("latency:100|ms\nlatency:300|ms|@0.5\nusers:alice|s\nusers:bob|s\nusers:alice|s\nusers:5|g"))
+
+	assert.Equal(t, int64(5), s.Counters["requests"])
+	assert.Equal(t, 7.0, s.Gauges["temp"])
+	assert.NotContains(t, s.Counters, "agent.reports_sent")
+
+	_, ok := s.Gauges["latency.max"]
+	assert.False(t, ok)
+
+	l.flush()
+
+	assert.Equal(t, 100.0, s.Gauges["latency.min"])
+	assert.Equal(t, 300.0, s.Gauges["latency.max"])
+	assert.InDelta(t, 700.0/3, s.Gauges["latency.mean"], 1e-9)
+	assert.Equal(t, int64(3), s.Counters["latency.count"])
+	assert.Equal(t, 2.0, s.Gauges["users.unique"])
+	assert.Equal(t, 5.0, s.Gauges["users"])
+
+	l.flush()
+
+	assert.Equal(t, int64(3), s.Counters["latency.count"])
+}
+
+func TestListener_Run(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "statsd.sock")
+
+	s := agenttest.NewStorage()
+	l := New(&Config{UnixSocketPath: socketPath, FlushInterval: time.Hour}, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- l.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unixgram", socketPath)
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("requests:1|c"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return s.Counter("requests") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	assert.NoError(t, <-done)
+	assert.NoFileExists(t, socketPath)
+}
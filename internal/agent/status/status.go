@@ -0,0 +1,168 @@
+// Package status contains local HTTP endpoint that exposes state of agent.
+//
+// Endpoint serves liveness probe on /healthz, agent state on /status
+// and metrics waiting to be sent on /metrics.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+const (
+	_shutdownTimeout = 5 * time.Second
+)
+
+// Collector states
+const (
+	Running = "running"
+	Stopped = "stopped"
+	Failed  = "failed"
+)
+
+// Report describes result of the last report.
+type Report struct {
+	Time    time.Time `json:"time"`
+	Metrics int       `json:"metrics"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Collector describes state of collector.
+type Collector struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// Destination describes state of metric server that receives reports.
+type Destination struct {
+	// Name - name of destination, empty for main destination.
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Breaker - state of circuit breaker.
+	Breaker string `json:"breaker"`
+	// LastReport - result of the last report, absent before the first report.
+	LastReport *Report `json:"last_report,omitempty"`
+}
+
+// Status describes state of agent.
+type Status struct {
+	// Config - configuration summary without secrets.
+	Config any `json:"config"`
+	// Destinations - states of destinations, the first one is main destination.
+	Destinations []Destination `json:"destinations"`
+	// Collectors - states of collectors sorted by name.
+	Collectors []Collector `json:"collectors"`
+}
+
+// Source describes agent methods used by endpoint.
+type Source interface {
+	// Status returns current state of agent.
+	Status() Status
+	// Metrics returns metrics from agent storage without removing them.
+	Metrics() []api.Metrics
+}
+
+// Config describes configuration of status endpoint.
+type Config struct {
+	// Address - address to listen, empty value disables endpoint. Should be bound to localhost.
+	Address string
+}
+
+// Server describes status endpoint.
+type Server struct {
+	config *Config
+	source Source
+}
+
+// New creates new status endpoint that exposes state of given source.
+func New(config *Config, source Source) *Server {
+	return &Server{
+		config: config,
+		source: source,
+	}
+}
+
+// Route creates router for all routes of status endpoint.
+func (s *Server) Route() *chi.Mux {
+	mux := chi.NewRouter()
+
+	mux.Get("/healthz", s.healthz)
+	mux.Get("/status", s.status)
+	mux.Get("/metrics", s.metrics)
+
+	return mux
+}
+
+// Run serves status endpoint until context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    s.config.Address,
+		Handler: s.Route(),
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), _shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Println("Status endpoint terminated")
+
+	return nil
+}
+
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (s *Server) status(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.source.Status())
+}
+
+func (s *Server) metrics(w http.ResponseWriter, _ *http.Request) {
+	metrics := s.source.Metrics()
+	if metrics == nil {
+		metrics = []api.Metrics{}
+	}
+
+	writeJSON(w, metrics)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		log.Printf("failed to encode status response: %s", err)
+	}
+}
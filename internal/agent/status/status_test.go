@@ -0,0 +1,57 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+type source struct{}
+
+func (source) Status() Status {
+	return Status{
+		Config: map[string]string{"key": "[REDACTED]"},
+		Destinations: []Destination{
+			{Address: "localhost:8080", Breaker: "closed", LastReport: &Report{Metrics: 2, Success: true}},
+		},
+		Collectors: []Collector{{Name: "runtime", State: Running}},
+	}
+}
+
+func (source) Metrics() []api.Metrics {
+	value := 1.5
+
+	return []api.Metrics{{ID: "Alloc", MType: api.GaugeType, Value: &value}}
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(New(&Config{}, source{}).Route())
+	defer srv.Close()
+
+	resp, err := resty.New().R().Get(srv.URL + "/healthz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var status Status
+	resp, err = resty.New().R().SetResult(&status).Get(srv.URL + "/status")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, status.Destinations, 1)
+	assert.Equal(t, "closed", status.Destinations[0].Breaker)
+	assert.Equal(t, map[string]any{"key": "[REDACTED]"}, status.Config)
+	assert.Equal(t, []Collector{{Name: "runtime", State: Running}}, status.Collectors)
+	require.NotNil(t, status.Destinations[0].LastReport)
+	assert.True(t, status.Destinations[0].LastReport.Success)
+
+	var metrics []api.Metrics
+	resp, err = resty.New().R().SetResult(&metrics).Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, source{}.Metrics(), metrics)
+}
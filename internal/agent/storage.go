@@ -31,6 +31,17 @@ func (s *Storage) UpdateGauge(name string, value float64) {
 	s.gauges[name] = value
 }
 
+// AddGauge adds given delta to current value of gauge metric.
+//
+// name - name of metric to update.
+// delta - value that will be added to current value of gauge, missing gauge is considered zero.
+func (s *Storage) AddGauge(name string, delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gauges[name] += delta
+}
+
 // UpdateCounter updates given counter metric.
 //
 // name - name of metric to update.
@@ -31,6 +31,18 @@ func TestStorage_UpdateGauge(t *testing.T) {
 	})
 }
 
+func TestStorage_AddGauge(t *testing.T) {
+	s := NewStorage()
+
+	s.AddGauge("TestMetric", 2.5)
+	s.AddGauge("TestMetric", -1)
+
+	value, ok := s.gauges["TestMetric"]
+
+	assert.True(t, ok)
+	assert.Equal(t, value, 1.5)
+}
+
 func TestStorage_UpdateCounter(t *testing.T) {
 	t.Run("update once", func(t *testing.T) {
 		s := NewStorage()
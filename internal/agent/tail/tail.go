@@ -0,0 +1,535 @@
+// Package tail contains collector that follows log files and derives metrics from matched lines.
+//
+// Every line appended to a followed file is checked against regex rules of the file.
+// Matched rule either increments counter or sets gauge, capture groups can be used
+// in metric name and value. Rotation and truncation of files are detected on every poll.
+// Not finite gauge values and lines longer than 64 KiB are skipped.
+// Read positions are saved to state file, so lines are not processed twice after restart.
+package tail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/agent/telemetry"
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+)
+
+const (
+	_signatureSize = 256
+	_readChunkSize = 32 * 1024
+
+	// Lines longer than limit are skipped, so file without newlines does not exhaust memory
+	_maxLineSize = 64 * 1024
+)
+
+// ErrInvalidConfig is returned for invalid tail configuration.
+var ErrInvalidConfig = errors.New("invalid tail configuration")
+
+// Storage describes methods of agent storage used by collector.
+type Storage interface {
+	// UpdateGauge sets value of gauge metric.
+	UpdateGauge(name string, value float64)
+	// UpdateCounter adds value to counter metric.
+	UpdateCounter(name string, value int64)
+}
+
+// Rule describes how matched line is converted to metric.
+type Rule struct {
+	// Pattern - regular expression to match lines.
+	Pattern string
+	// Metric - metric name, may contain references to capture groups like $1 or ${name}.
+	Metric string
+	// Type - metric type.
+	Type api.MetricsType
+	// Value - metric value, may contain references to capture groups.
+	// Empty value means increment by one for counters and is not allowed for gauges.
+	Value string
+
+	regex *regexp.Regexp
+}
+
+// FileConfig describes one followed file.
+type FileConfig struct {
+	// Path - path to file.
+	Path string
+	// Rules - rules applied to every line of file.
+	Rules []Rule
+}
+
+// Config describes configuration of tail collector.
+type Config struct {
+	// Files - followed files.
+	Files []FileConfig
+	// PollInterval - interval for checking files for new lines.
+	PollInterval time.Duration
+	// StatePath - path to file for saving read positions, empty value disables saving.
+	StatePath string
+}
+
+// Validate checks configuration and compiles regex rules.
+//
+// Returned error is config.KeyError naming invalid key like [0].rules[1].pattern.
+func (c *Config) Validate() error {
+	for i := range c.Files {
+		if err := c.Files[i].validate(); err != nil {
+			return config.Field(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+
+	return nil
+}
+
+func (f *FileConfig) validate() error {
+	if f.Path == "" {
+		return invalid("path", fmt.Errorf("%w: empty file path", ErrInvalidConfig))
+	}
+
+	for j := range f.Rules {
+		r := &f.Rules[j]
+		key := fmt.Sprintf("rules[%d]", j)
+
+		regex, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return invalid(key+".pattern", fmt.Errorf("%w: %s", ErrInvalidConfig, err))
+		}
+
+		r.regex = regex
+
+		if r.Metric == "" {
+			return invalid(key+".metric", fmt.Errorf("%w: empty metric name", ErrInvalidConfig))
+		}
+
+		// Capture groups are expanded at runtime, so only constant beginning of name is checked
+		if static, _, _ := strings.Cut(r.Metric, "$"); telemetry.Reserved(static) {
+			return invalid(key+".metric", fmt.Errorf("%w: metric name prefix %s is reserved", ErrInvalidConfig, telemetry.Prefix))
+		}
+
+		switch r.Type {
+		case api.CounterType:
+		case api.GaugeType:
+			if r.Value == "" {
+				return invalid(key+".value", fmt.Errorf("%w: gauge requires value", ErrInvalidConfig))
+			}
+		default:
+			return invalid(key+".type", fmt.Errorf("%w: unknown type %q", ErrInvalidConfig, r.Type))
+		}
+	}
+
+	return nil
+}
+
+// invalid returns error of configuration key.
+func invalid(key string, err error) error {
+	return &config.KeyError{Key: key, Err: err}
+}
+
+type position struct {
+	Offset        int64  `json:"offset"`
+	Signature     string `json:"signature"`
+	SignatureSize int64  `json:"signature_size"`
+}
+
+type tailedFile struct {
+	config  *FileConfig
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	// skip means that the rest of too long line is skipped until newline
+	skip bool
+}
+
+// Tailer describes collector that follows all configured files.
+type Tailer struct {
+	config  *Config
+	storage Storage
+	files   []*tailedFile
+}
+
+// New creates new tail collector.
+//
+// Config must be validated before.
+func New(config *Config, storage Storage) *Tailer {
+	files := make([]*tailedFile, 0, len(config.Files))
+
+	for i := range config.Files {
+		files = append(files, &tailedFile{config: &config.Files[i]})
+	}
+
+	return &Tailer{
+		config:  config,
+		storage: storage,
+		files:   files,
+	}
+}
+
+// Run follows files until context is cancelled.
+func (t *Tailer) Run(ctx context.Context) {
+	t.start()
+
+	ticker := time.NewTicker(t.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.poll()
+			t.close()
+
+			log.Println("Tail collector terminated")
+			return
+		case <-ticker.C:
+			t.poll()
+		}
+	}
+}
+
+// start opens files at saved positions.
+//
+// Files without saved position are followed from the end, files changed since
+// position was saved are followed from the beginning.
+func (t *Tailer) start() {
+	state, err := t.loadState()
+	if err != nil {
+		log.Printf("failed to load tail state: %s", err)
+	}
+
+	for _, f := range t.files {
+		if err := f.open(); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("failed to open %s: %s", f.config.Path, err)
+			}
+
+			continue
+		}
+
+		pos, ok := state[f.config.Path]
+		switch {
+		case !ok:
+			f.offset = f.info.Size()
+		case f.matches(pos):
+			f.offset = pos.Offset
+		default:
+			f.offset = 0
+		}
+	}
+}
+
+func (t *Tailer) poll() {
+	start := time.Now()
+	defer func() {
+		t.storage.UpdateGauge(telemetry.PollDuration("tail"), time.Since(start).Seconds())
+	}()
+
+	for _, f := range t.files {
+		if err := f.poll(t.apply); err != nil {
+			log.Printf("failed to tail %s: %s", f.config.Path, err)
+		}
+	}
+
+	if err := t.saveState(); err != nil {
+		log.Printf("failed to save tail state: %s", err)
+	}
+}
+
+func (t *Tailer) close() {
+	for _, f := range t.files {
+		f.close()
+	}
+}
+
+func (t *Tailer) apply(rules []Rule, line []byte) {
+	for i := range rules {
+		r := &rules[i]
+
+		match := r.regex.FindSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		name := string(r.regex.Expand(nil, []byte(r.Metric), line, match))
+		value := string(r.regex.Expand(nil, []byte(r.Value), line, match))
+
+		if telemetry.Reserved(name) {
+			log.Printf("metric name prefix %s is reserved, %s is skipped", telemetry.Prefix, name)
+			continue
+		}
+
+		switch r.Type {
+		case api.CounterType:
+			delta := int64(1)
+
+			if r.Value != "" {
+				v, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					log.Printf("invalid counter value %q for %s", value, name)
+					continue
+				}
+
+				delta = v
+			}
+
+			t.storage.UpdateCounter(name, delta)
+		case api.GaugeType:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				log.Printf("invalid gauge value %q for %s", value, name)
+				continue
+			}
+
+			t.storage.UpdateGauge(name, v)
+		}
+	}
+}
+
+func (t *Tailer) loadState() (map[string]position, error) {
+	state := make(map[string]position)
+
+	if t.config.StatePath == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(t.config.StatePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return make(map[string]position), err
+	}
+
+	return state, nil
+}
+
+func (t *Tailer) saveState() error {
+	if t.config.StatePath == "" {
+		return nil
+	}
+
+	state := make(map[string]position, len(t.files))
+
+	for _, f := range t.files {
+		if f.file == nil {
+			continue
+		}
+
+		pos, err := f.position()
+		if err != nil {
+			return err
+		}
+
+		state[f.config.Path] = pos
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// Write to temporary file and rename it to avoid broken state after crash
+	tmp, err := os.CreateTemp(filepath.Dir(t.config.StatePath), filepath.Base(t.config.StatePath)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), t.config.StatePath)
+}
+
+func (f *tailedFile) open() error {
+	file, err := os.Open(f.config.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.info = info
+	f.offset = 0
+	f.partial = nil
+	f.skip = false
+
+	return nil
+}
+
+func (f *tailedFile) close() {
+	if f.file != nil {
+		_ = f.file.Close()
+		f.file = nil
+	}
+}
+
+func (f *tailedFile) poll(apply func(rules []Rule, line []byte)) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	info, err := os.Stat(f.config.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil && !os.SameFile(f.info, info) {
+		// File was rotated, read the rest of old file and switch to new one
+		if err := f.read(apply); err != nil {
+			return err
+		}
+
+		f.close()
+
+		if err := f.open(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if current.Size() < f.offset {
+		// File was truncated, start from the beginning
+		f.offset = 0
+		f.partial = nil
+		f.skip = false
+	}
+
+	return f.read(apply)
+}
+
+func (f *tailedFile) read(apply func(rules []Rule, line []byte)) error {
+	buf := make([]byte, _readChunkSize)
+
+	for {
+		n, err := f.file.ReadAt(buf, f.offset)
+
+		if n > 0 {
+			f.offset += int64(n)
+			data := append(f.partial, buf[:n]...)
+
+			for {
+				idx := bytes.IndexByte(data, '\n')
+				if idx == -1 {
+					break
+				}
+
+				if f.skip {
+					f.skip = false
+				} else {
+					apply(f.config.Rules, bytes.TrimRight(data[:idx], "\r"))
+				}
+
+				data = data[idx+1:]
+			}
+
+			if !f.skip && len(data) > _maxLineSize {
+				log.Printf("line longer than %d bytes in %s is skipped", _maxLineSize, f.config.Path)
+				f.skip = true
+			}
+
+			if f.skip {
+				data = nil
+			}
+
+			f.partial = append([]byte(nil), data...)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// position returns position of the last complete line read from file.
+func (f *tailedFile) position() (position, error) {
+	size := min(f.info.Size(), _signatureSize)
+	if current, err := f.file.Stat(); err == nil {
+		size = min(current.Size(), _signatureSize)
+	}
+
+	signature, err := f.signature(size)
+	if err != nil {
+		return position{}, err
+	}
+
+	return position{
+		Offset:        f.offset - int64(len(f.partial)),
+		Signature:     signature,
+		SignatureSize: size,
+	}, nil
+}
+
+// matches checks that opened file is the same file position was saved for.
+func (f *tailedFile) matches(pos position) bool {
+	if f.info.Size() < pos.Offset || f.info.Size() < pos.SignatureSize {
+		return false
+	}
+
+	signature, err := f.signature(pos.SignatureSize)
+	if err != nil {
+		return false
+	}
+
+	return signature == pos.Signature
+}
+
+func (f *tailedFile) signature(size int64) (string, error) {
+	buf := make([]byte, size)
+
+	if _, err := f.file.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf)
+
+	return hex.EncodeToString(sum[:]), nil
+}
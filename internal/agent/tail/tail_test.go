@@ -0,0 +1,226 @@
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/agent/agenttest"
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+func appendFile(t *testing.T, path string, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	require.NoError(t, err)
+
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func newConfig(dir string) *Config {
+	return &Config{
+		Files: []FileConfig{
+			{
+				Path: filepath.Join(dir, "access.log"),
+				Rules: []Rule{
+					{
+						Pattern: `status=5\d\d`,
+						Metric:  "Errors5xx",
+						Type:    api.CounterType,
+					},
+					{
+						Pattern: `method=(\w+) .*rt=(?P<rt>[0-9.]+)`,
+						Metric:  "Latency$1",
+						Type:    api.GaugeType,
+						Value:   "${rt}",
+					},
+				},
+			},
+		},
+		PollInterval: time.Second,
+		StatePath:    filepath.Join(dir, "state.json"),
+	}
+}
+
+func TestTailer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	appendFile(t, path, "method=GET status=500 rt=1.5\n")
+
+	config := newConfig(dir)
+	require.NoError(t, config.Validate())
+
+	s := agenttest.NewStorage()
+	tailer := New(config, s)
+
+	// Existing content is skipped when there is no saved state
+	tailer.start()
+	tailer.poll()
+
+	assert.Empty(t, s.Counters)
+
+	appendFile(t, path, "method=GET status=200 rt=0.5\nmethod=POST status=503 rt=2.5\nmethod=GET sta")
+	tailer.poll()
+
+	assert.Equal(t, int64(1), s.Counters["Errors5xx"])
+	assert.Equal(t, 0.5, s.Gauges["LatencyGET"])
+	assert.Equal(t, 2.5, s.Gauges["LatencyPOST"])
+
+	// Partial line is completed
+	appendFile(t, path, "tus=502 rt=0.7\n")
+	tailer.poll()
+
+	assert.Equal(t, int64(2), s.Counters["Errors5xx"])
+	assert.Equal(t, 0.7, s.Gauges["LatencyGET"])
+
+	// Rotation: the rest of old file is read, new file is read from the beginning
+	appendFile(t, path, "method=GET status=500 rt=0.1\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "method=GET status=500 rt=0.2\n")
+	tailer.poll()
+
+	assert.Equal(t, int64(4), s.Counters["Errors5xx"])
+	assert.Equal(t, 0.2, s.Gauges["LatencyGET"])
+
+	// Truncation
+	require.NoError(t, os.Truncate(path, 0))
+	appendFile(t, path, "status=500\n")
+	tailer.poll()
+
+	assert.Equal(t, int64(5), s.Counters["Errors5xx"])
+
+	tailer.close()
+
+	// Restart continues from saved position
+	appendFile(t, path, "status=500\n")
+
+	s = agenttest.NewStorage()
+	tailer = New(config, s)
+	tailer.start()
+	tailer.poll()
+
+	assert.Equal(t, int64(1), s.Counters["Errors5xx"])
+
+	tailer.close()
+}
+
+func TestTailer_rotatedWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	appendFile(t, path, "status=500\nstatus=500\n")
+
+	config := newConfig(dir)
+	require.NoError(t, config.Validate())
+
+	tailer := New(config, agenttest.NewStorage())
+	tailer.start()
+	tailer.poll()
+	tailer.close()
+
+	require.NoError(t, os.Remove(path))
+	appendFile(t, path, "other content\nstatus=500\nstatus=500\nstatus=500\n")
+
+	s := agenttest.NewStorage()
+	tailer = New(config, s)
+	tailer.start()
+	tailer.poll()
+	tailer.close()
+
+	assert.Equal(t, int64(3), s.Counters["Errors5xx"])
+}
+
+func TestTailer_longLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	appendFile(t, path, "")
+
+	config := newConfig(dir)
+	require.NoError(t, config.Validate())
+
+	s := agenttest.NewStorage()
+	tailer := New(config, s)
+	tailer.start()
+
+	appendFile(t, path, "status=500 "+strings.Repeat("a", 2*_maxLineSize))
+	tailer.poll()
+
+	assert.Empty(t, tailer.files[0].partial)
+
+	appendFile(t, path, strings.Repeat("a", _readChunkSize)+"\nstatus=500\n")
+	tailer.poll()
+
+	assert.Equal(t, int64(1), s.Counters["Errors5xx"])
+
+	tailer.close()
+}
+
+func TestTailer_notFiniteGauge(t *testing.T) {
+	config := &Config{
+		Files: []FileConfig{{
+			Path:  "app.log",
+			Rules: []Rule{{Pattern: `load=(\S+)`, Metric: "Load", Type: api.GaugeType, Value: "$1"}},
+		}},
+		PollInterval: time.Second,
+	}
+	require.NoError(t, config.Validate())
+
+	s := agenttest.NewStorage()
+	tailer := New(config, s)
+
+	for _, line := range []string{"load=NaN", "load=+Inf", "load=-Inf"} {
+		tailer.apply(config.Files[0].Rules, []byte(line))
+	}
+
+	assert.Empty(t, s.Gauges)
+
+	tailer.apply(config.Files[0].Rules, []byte("load=0.5"))
+
+	assert.Equal(t, 0.5, s.Gauges["Load"])
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{
+			name: "invalid pattern",
+			rule: Rule{Pattern: "(", Metric: "A", Type: api.CounterType},
+		},
+		{
+			name: "empty metric",
+			rule: Rule{Pattern: "a", Type: api.CounterType},
+		},
+		{
+			name: "reserved metric",
+			rule: Rule{Pattern: "(a)", Metric: "agent.$1", Type: api.CounterType},
+		},
+		{
+			name: "gauge without value",
+			rule: Rule{Pattern: "a", Metric: "A", Type: api.GaugeType},
+		},
+		{
+			name: "unknown type",
+			rule: Rule{Pattern: "a", Metric: "A", Type: "histogram"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Files: []FileConfig{{Path: "a.log", Rules: []Rule{test.rule}}}}
+
+			assert.ErrorIs(t, config.Validate(), ErrInvalidConfig)
+		})
+	}
+}
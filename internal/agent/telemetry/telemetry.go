@@ -0,0 +1,45 @@
+// Package telemetry contains names of metrics that agent reports about itself.
+//
+// All names start with reserved prefix, so metrics from external sources can not overwrite them:
+// configuration producing reserved names is rejected, reserved names received at runtime are skipped.
+package telemetry
+
+import "strings"
+
+// Prefix is reserved prefix of agent self metrics.
+const Prefix = "agent."
+
+// Names of agent self metrics
+const (
+	BreakerState        = Prefix + "breaker_state"         // gauge, state of circuit breaker
+	ReportsSent         = Prefix + "reports_sent"          // counter, successfully sent batches
+	ReportsFailed       = Prefix + "reports_failed"        // counter, batches failed after all retries
+	BatchSize           = Prefix + "batch_size"            // gauge, amount of metrics in the last batch
+	BatchBytes          = Prefix + "batch_bytes"           // gauge, size of the last batch before compression
+	BatchGzipBytes      = Prefix + "batch_gzip_bytes"      // gauge, size of the last batch after compression
+	EncryptionSeconds   = Prefix + "encryption_seconds"    // gauge, encryption time of the last batch
+	LastReportTimestamp = Prefix + "last_report_timestamp" // gauge, unix time of the last successful report
+	SpoolDepth          = Prefix + "spool_depth"           // gauge, amount of batches saved in spool files
+	DroppedCounters     = Prefix + "dropped_counters"      // counter, counters of removed destinations that could not be sent
+)
+
+// PollDuration returns name of gauge with duration of the last poll of collector in seconds.
+func PollDuration(collector string) string {
+	return Prefix + "poll_duration." + collector
+}
+
+// ForDestination returns name of metric for destination with given name.
+//
+// Metrics of main destination have no suffix.
+func ForDestination(metric string, destination string) string {
+	if destination == "" {
+		return metric
+	}
+
+	return metric + "." + destination
+}
+
+// Reserved reports whether metric name has reserved prefix.
+func Reserved(name string) bool {
+	return strings.HasPrefix(name, Prefix)
+}
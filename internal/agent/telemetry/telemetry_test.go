@@ -0,0 +1,19 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserved(t *testing.T) {
+	assert.True(t, Reserved(ReportsSent))
+	assert.True(t, Reserved(PollDuration("runtime")))
+	assert.False(t, Reserved("Alloc"))
+	assert.False(t, Reserved("agent"))
+}
+
+func TestForDestination(t *testing.T) {
+	assert.Equal(t, ReportsSent, ForDestination(ReportsSent, ""))
+	assert.Equal(t, "agent.reports_sent.backup", ForDestination(ReportsSent, "backup"))
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"sort"
+	"strings"
+)
+
+var _labelReplacer = strings.NewReplacer(";", "_", "=", "_")
+
+// LabeledName builds metric name with labels in format name;label1=value1;label2=value2.
+//
+// Labels are sorted by name, separators in label names and values are replaced with underscore.
+func LabeledName(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	b.WriteString(name)
+
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(_labelReplacer.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(_labelReplacer.Replace(labels[k]))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidMetadata is returned for metadata with unsupported expected type.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata describes metric for humans and tools.
+type Metadata struct {
+	// Unit - unit of metric value, for example bytes or seconds.
+	Unit string `json:"unit,omitempty"`
+	// Description - human readable description of metric.
+	Description string `json:"description,omitempty"`
+	// Owner - team or person responsible for metric.
+	Owner string `json:"owner,omitempty"`
+	// Type - expected metric type, empty if any type is allowed.
+	Type MetricsType `json:"type,omitempty"`
+}
+
+// Validate checks that expected type is supported.
+func (m Metadata) Validate() error {
+	if m.Type != "" && m.Type != GaugeType && m.Type != CounterType {
+		return fmt.Errorf("%w: unsupported type %q", ErrInvalidMetadata, m.Type)
+	}
+
+	return nil
+}
+
+// IsEmpty reports whether metadata has no fields set.
+func (m Metadata) IsEmpty() bool {
+	return m == Metadata{}
+}
+
+// Merge returns copy of metadata with not empty fields of other metadata set over it.
+func (m Metadata) Merge(other Metadata) Metadata {
+	if other.Unit != "" {
+		m.Unit = other.Unit
+	}
+
+	if other.Description != "" {
+		m.Description = other.Description
+	}
+
+	if other.Owner != "" {
+		m.Owner = other.Owner
+	}
+
+	if other.Type != "" {
+		m.Type = other.Type
+	}
+
+	return m
+}
@@ -20,4 +20,6 @@ type Metrics struct {
 	Delta *int64 `json:"delta,omitempty"`
 	// Value - new value for gauge metric, for counter metric is nil.
 	Value *float64 `json:"value,omitempty"`
+	// Metadata - optional description of metric, stored at server and merged with known one.
+	Metadata *Metadata `json:"metadata,omitempty"`
 }
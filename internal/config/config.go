@@ -0,0 +1,396 @@
+// Package config contains reading of configuration files and environment variables shared by agent and server.
+//
+// Configuration file may be written in JSON, YAML or TOML, format is chosen by file extension.
+// Keys of file are matched with json tags of destination struct, unknown keys are rejected.
+// Durations are written as Go duration strings like "500ms" or "2m", bare integers mean seconds.
+// Errors of decoding name the offending key like destinations[1].retry_wait.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported formats of configuration file
+const (
+	JSONFormat = "json"
+	YAMLFormat = "yaml"
+	TOMLFormat = "toml"
+)
+
+var (
+	// ErrUnknownFormat is returned for configuration file with unsupported extension.
+	ErrUnknownFormat = errors.New("unknown configuration format")
+	// ErrUnknownKey is returned for key that does not exist in configuration.
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+var _durationType = reflect.TypeOf(time.Duration(0))
+
+// KeyError describes invalid key of configuration file.
+type KeyError struct {
+	// Key - full path of key like destinations[1].retry_wait.
+	Key string
+	// Err - reason of error.
+	Err error
+}
+
+// Error returns text of error.
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Err)
+}
+
+// Unwrap returns reason of error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// Field returns error of configuration key.
+//
+// Validators report keys relative to validated value, so key of KeyError is nested into given key
+// like destinations[1] and retry_wait into destinations[1].retry_wait.
+func Field(key string, err error) error {
+	var keyErr *KeyError
+	if errors.As(err, &keyErr) {
+		return &KeyError{Key: join(key, keyErr.Key), Err: keyErr.Err}
+	}
+
+	return &KeyError{Key: key, Err: err}
+}
+
+// Format returns format of configuration file by its extension.
+func Format(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSONFormat, nil
+	case ".yaml", ".yml":
+		return YAMLFormat, nil
+	case ".toml":
+		return TOMLFormat, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+}
+
+// Read reads configuration file into struct pointed by config.
+//
+// Keys absent in file keep values of config, so defaults should be set before.
+func Read(path string, config any) error {
+	format, err := Format(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := Decode(data, format, config); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Decode decodes configuration in given format into struct pointed by config.
+func Decode(data []byte, format string, config any) error {
+	tree, err := parse(data, format)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("configuration must be decoded into pointer, got %T", config)
+	}
+
+	return decode("", tree, v.Elem())
+}
+
+// parse parses configuration into tree of maps, slices and scalar values.
+func parse(data []byte, format string) (map[string]any, error) {
+	tree := make(map[string]any)
+
+	switch format {
+	case JSONFormat:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+	case YAMLFormat:
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case TOMLFormat:
+		if _, err := toml.Decode(string(data), &tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	return tree, nil
+}
+
+func decode(key string, value any, out reflect.Value) error {
+	// Null value keeps default
+	if value == nil {
+		return nil
+	}
+
+	if out.Type() == _durationType {
+		d, err := parseDuration(value)
+		if err != nil {
+			return &KeyError{Key: key, Err: err}
+		}
+
+		out.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Pointer:
+		ptr := reflect.New(out.Type().Elem())
+		if err := decode(key, value, ptr.Elem()); err != nil {
+			return err
+		}
+
+		out.Set(ptr)
+	case reflect.Struct:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return typeError(key, "table", value)
+		}
+
+		return decodeStruct(key, m, out)
+	case reflect.Map:
+		m, ok := value.(map[string]any)
+		if !ok || out.Type().Key().Kind() != reflect.String {
+			return typeError(key, "table", value)
+		}
+
+		return decodeMap(key, m, out)
+	case reflect.Slice:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice {
+			return typeError(key, "list", value)
+		}
+
+		result := reflect.MakeSlice(out.Type(), items.Len(), items.Len())
+
+		for i := 0; i < items.Len(); i++ {
+			if err := decode(fmt.Sprintf("%s[%d]", key, i), items.Index(i).Interface(), result.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		out.Set(result)
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return typeError(key, "string", value)
+		}
+
+		out.SetString(s)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeError(key, "boolean", value)
+		}
+
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt(value)
+		if !ok || out.OverflowInt(n) {
+			return typeError(key, "integer", value)
+		}
+
+		out.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return typeError(key, "number", value)
+		}
+
+		out.SetFloat(f)
+	default:
+		return &KeyError{Key: key, Err: fmt.Errorf("unsupported type %s", out.Type())}
+	}
+
+	return nil
+}
+
+func decodeStruct(key string, m map[string]any, out reflect.Value) error {
+	fields := make(map[string]int, out.NumField())
+
+	for i := 0; i < out.NumField(); i++ {
+		f := out.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[name] = i
+	}
+
+	// Keys are sorted to report the same error for the same file
+	for _, k := range sortedKeys(m) {
+		i, ok := fields[k]
+		if !ok {
+			return &KeyError{Key: join(key, k), Err: ErrUnknownKey}
+		}
+
+		if err := decode(join(key, k), m[k], out.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeMap(key string, m map[string]any, out reflect.Value) error {
+	if out.IsNil() {
+		out.Set(reflect.MakeMapWithSize(out.Type(), len(m)))
+	}
+
+	for _, k := range sortedKeys(m) {
+		elem := reflect.New(out.Type().Elem()).Elem()
+		if err := decode(join(key, k), m[k], elem); err != nil {
+			return err
+		}
+
+		out.SetMapIndex(reflect.ValueOf(k).Convert(out.Type().Key()), elem)
+	}
+
+	return nil
+}
+
+// ParseDuration parses Go duration string like "500ms", bare integer means seconds.
+func ParseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected duration like \"500ms\" or integer seconds, got %q", s)
+	}
+
+	return d, nil
+}
+
+func parseDuration(value any) (time.Duration, error) {
+	if s, ok := value.(string); ok {
+		return ParseDuration(s)
+	}
+
+	secs, ok := toInt(value)
+	if !ok {
+		return 0, fmt.Errorf("expected duration like \"500ms\" or integer seconds, got %v", value)
+	}
+
+	return time.Duration(secs) * time.Second, nil
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case float64:
+		return int64(v), v == math.Trunc(v) && math.Abs(v) < math.MaxInt64
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func typeError(key string, expected string, value any) error {
+	return &KeyError{Key: key, Err: fmt.Errorf("expected %s, got %s", expected, describe(value))}
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case map[string]any:
+		return "table"
+	default:
+		if reflect.ValueOf(value).Kind() == reflect.Slice {
+			return "list"
+		}
+
+		return fmt.Sprint(v)
+	}
+}
+
+func join(key string, name string) string {
+	if key == "" {
+		return name
+	}
+
+	if strings.HasPrefix(name, "[") {
+		return key + name
+	}
+
+	return key + "." + name
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTarget struct {
+	Name     string         `json:"name"`
+	Interval time.Duration  `json:"interval"`
+	Timeout  *time.Duration `json:"timeout"`
+}
+
+type testConfig struct {
+	Address string            `json:"address"`
+	Enabled bool              `json:"enabled"`
+	Limit   int               `json:"limit"`
+	Factor  float64           `json:"factor"`
+	Wait    time.Duration     `json:"wait"`
+	Labels  map[string]string `json:"labels"`
+	Args    []string          `json:"args"`
+	Targets []testTarget      `json:"targets"`
+}
+
+func TestDecode(t *testing.T) {
+	timeout := 3 * time.Second
+
+	want := testConfig{
+		Address: "localhost:8080",
+		Enabled: true,
+		Limit:   10,
+		Factor:  0.5,
+		Wait:    500 * time.Millisecond,
+		Labels:  map[string]string{"env": "prod"},
+		Args:    []string{"-v"},
+		Targets: []testTarget{
+			{Name: "a", Interval: 2 * time.Minute},
+			{Name: "b", Interval: 5 * time.Second, Timeout: &timeout},
+		},
+	}
+
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{
+			format: JSONFormat,
+			data: `{
+				"address": "localhost:8080", "enabled": true, "limit": 10, "factor": 0.5, "wait": "500ms",
+				"labels": {"env": "prod"}, "args": ["-v"],
+				"targets": [{"name": "a", "interval": "2m"}, {"name": "b", "interval": 5, "timeout": "3s"}]
+			}`,
+		},
+		{
+			format: YAMLFormat,
+			data: `
+address: localhost:8080
+enabled: true
+limit: 10
+factor: 0.5
+wait: 500ms
+labels:
+  env: prod
+args: ["-v"]
+targets:
+  - name: a
+    interval: 2m
+  - name: b
+    interval: 5
+    timeout: 3s
+`,
+		},
+		{
+			format: TOMLFormat,
+			data: `
+address = "localhost:8080"
+enabled = true
+limit = 10
+factor = 0.5
+wait = "500ms"
+args = ["-v"]
+
+[labels]
+env = "prod"
+
+[[targets]]
+name = "a"
+interval = "2m"
+
+[[targets]]
+name = "b"
+interval = 5
+timeout = "3s"
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var got testConfig
+
+			require.NoError(t, Decode([]byte(test.data), test.format, &got))
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestDecode_keepsDefaults(t *testing.T) {
+	got := testConfig{Address: "default", Limit: 5}
+
+	require.NoError(t, Decode([]byte(`limit: 7`), YAMLFormat, &got))
+
+	assert.Equal(t, testConfig{Address: "default", Limit: 7}, got)
+}
+
+func TestDecode_errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		key    string
+	}{
+		{name: "unknown key", format: JSONFormat, data: `{"adress": "localhost"}`, key: "adress"},
+		{name: "unknown nested key", format: YAMLFormat, data: "targets:\n  - name: a\n  - nme: b", key: "targets[1].nme"},
+		{name: "invalid duration", format: TOMLFormat, data: "[[targets]]\ninterval = \"2 minutes\"", key: "targets[0].interval"},
+		{name: "invalid pointer duration", format: JSONFormat, data: `{"targets": [{"timeout": true}]}`, key: "targets[0].timeout"},
+		{name: "string instead of integer", format: JSONFormat, data: `{"limit": "10"}`, key: "limit"},
+		{name: "fractional integer", format: YAMLFormat, data: `limit: 1.5`, key: "limit"},
+		{name: "integer instead of string", format: TOMLFormat, data: `address = 8080`, key: "address"},
+		{name: "list instead of table", format: JSONFormat, data: `{"labels": ["a"]}`, key: "labels"},
+		{name: "invalid map value", format: YAMLFormat, data: "labels:\n  env: [prod]", key: "labels.env"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got testConfig
+
+			err := Decode([]byte(test.data), test.format, &got)
+
+			var keyErr *KeyError
+			require.True(t, errors.As(err, &keyErr), "got %v", err)
+			assert.Equal(t, test.key, keyErr.Key)
+			assert.Contains(t, err.Error(), test.key)
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("wait: 1m\nunknown: 1\n"), 0o600))
+
+	var got testConfig
+
+	err := Read(path, &got)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Contains(t, err.Error(), path)
+
+	path = filepath.Join(dir, "config.ini")
+	require.NoError(t, os.WriteFile(path, []byte("wait=1"), 0o600))
+
+	assert.ErrorIs(t, Read(path, &got), ErrUnknownFormat)
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	t.Setenv("TEST_SECONDS", "5")
+	t.Setenv("TEST_DURATION", "250ms")
+	t.Setenv("TEST_INVALID", "soon")
+
+	d, err := GetEnvDuration("TEST_SECONDS", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, err = GetEnvDuration("TEST_DURATION", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d)
+
+	d, err = GetEnvDuration("TEST_ABSENT", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+
+	_, err = GetEnvDuration("TEST_INVALID", time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidEnv)
+	assert.ErrorContains(t, err, "TEST_INVALID")
+}
+
+func TestGetEnvInt(t *testing.T) {
+	t.Setenv("TEST_SIZE", "8589934592")
+	t.Setenv("TEST_INVALID", "abc")
+
+	v, err := GetEnvInt("TEST_SIZE", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 8589934592, v)
+
+	v, err = GetEnvInt("TEST_ABSENT", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	_, err = GetEnvInt("TEST_INVALID", 1)
+	assert.ErrorIs(t, err, ErrInvalidEnv)
+	assert.ErrorContains(t, err, "TEST_INVALID")
+}
+
+func TestGetEnvBool(t *testing.T) {
+	t.Setenv("TEST_RESTORE", "false")
+	t.Setenv("TEST_INVALID", "maybe")
+
+	v, err := GetEnvBool("TEST_RESTORE", true)
+	require.NoError(t, err)
+	assert.False(t, v)
+
+	_, err = GetEnvBool("TEST_INVALID", true)
+	assert.ErrorIs(t, err, ErrInvalidEnv)
+	assert.ErrorContains(t, err, "TEST_INVALID")
+}
+
+func TestField(t *testing.T) {
+	cause := errors.New("empty name")
+
+	err := Field("destinations[1]", &KeyError{Key: "name", Err: cause})
+	assert.EqualError(t, err, "destinations[1].name: empty name")
+	assert.ErrorIs(t, err, cause)
+
+	assert.EqualError(t, Field("tail", &KeyError{Key: "[0].path", Err: cause}), "tail[0].path: empty name")
+	assert.EqualError(t, Field("metadata.Alloc", cause), "metadata.Alloc: empty name")
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidEnv is returned for environment variable with value of wrong type.
+var ErrInvalidEnv = errors.New("invalid environment variable")
+
+// GetEnv returns value of environment variable or default value if variable is not set.
+func GetEnv(key string, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+
+	return defaultValue
+}
+
+// GetEnvInt returns integer value of environment variable.
+//
+// Default value is returned if variable is not set, error naming variable is returned if it is not an integer.
+func GetEnvInt(key string, defaultValue int) (int, error) {
+	if value, exists := os.LookupEnv(key); exists {
+		val, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w %s: expected integer, got %q", ErrInvalidEnv, key, value)
+		}
+
+		return int(val), nil
+	}
+
+	return defaultValue, nil
+}
+
+// GetEnvBool returns boolean value of environment variable.
+//
+// Default value is returned if variable is not set, error naming variable is returned if it is not a boolean.
+func GetEnvBool(key string, defaultValue bool) (bool, error) {
+	if value, exists := os.LookupEnv(key); exists {
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("%w %s: expected boolean, got %q", ErrInvalidEnv, key, value)
+		}
+
+		return val, nil
+	}
+
+	return defaultValue, nil
+}
+
+// GetEnvDuration returns duration value of environment variable in format of ParseDuration.
+//
+// Default value is returned if variable is not set, error naming variable is returned if it is not a duration.
+func GetEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	if value, exists := os.LookupEnv(key); exists {
+		val, err := ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("%w %s: %v", ErrInvalidEnv, key, err)
+		}
+
+		return val, nil
+	}
+
+	return defaultValue, nil
+}
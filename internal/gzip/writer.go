@@ -49,7 +49,13 @@ func (gw *Writer) Write(b []byte) (int, error) {
 }
 
 // Close closes writer.
+//
+// Uncompressed response is left as is, so gzip footer is not appended to it.
 func (gw *Writer) Close() error {
+	if !gw.compress {
+		return nil
+	}
+
 	return gw.zw.Close()
 }
 
@@ -0,0 +1,347 @@
+// Package influx contains parser for InfluxDB line protocol.
+//
+// Every line has format <measurement>[,<tag>=<value>...] <field>=<value>[,<field>=<value>...] [<timestamp>].
+// Commas and spaces in measurement and commas, equal signs and spaces in tag keys, tag values
+// and field keys are escaped with backslash. String field values are double quoted,
+// double quotes and backslashes in them are escaped with backslash.
+// Lines starting with # are comments.
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sentinel errors for line protocol parser.
+var (
+	ErrInvalidFormat      = errors.New("invalid format")
+	ErrEmptyMeasurement   = errors.New("empty measurement")
+	ErrInvalidTag         = errors.New("invalid tag")
+	ErrNoFields           = errors.New("no fields")
+	ErrInvalidField       = errors.New("invalid field")
+	ErrInvalidValue       = errors.New("invalid field value")
+	ErrInvalidTimestamp   = errors.New("invalid timestamp")
+	ErrUnterminatedString = errors.New("unterminated string")
+)
+
+const (
+	_measurementEscapes = ", "
+	_keyEscapes         = ",= "
+)
+
+// Tag describes one tag of point.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field describes one field of point.
+type Field struct {
+	// Key - name of field.
+	Key string
+	// Value - value of field: float64, int64, uint64, string or bool.
+	Value any
+}
+
+// Point describes one parsed line.
+type Point struct {
+	// Measurement - name of measurement.
+	Measurement string
+	// Tags - tags in order of line.
+	Tags []Tag
+	// Fields - fields in order of line, there is at least one field.
+	Fields []Field
+	// Timestamp - timestamp in precision of request, nil if absent.
+	Timestamp *int64
+}
+
+// Parse parses one line of line protocol.
+func Parse(line string) (Point, error) {
+	var p Point
+
+	measurement, i := scanKey(line, 0, _measurementEscapes, ", ")
+	if measurement == "" {
+		return Point{}, ErrEmptyMeasurement
+	}
+
+	p.Measurement = measurement
+
+	for i < len(line) && line[i] == ',' {
+		var key, value string
+
+		key, i = scanKey(line, i+1, _keyEscapes, ",= ")
+		if key == "" || i >= len(line) || line[i] != '=' {
+			return Point{}, fmt.Errorf("%w: %q", ErrInvalidTag, line)
+		}
+
+		value, i = scanKey(line, i+1, _keyEscapes, ",= ")
+		if value == "" || (i < len(line) && line[i] == '=') {
+			return Point{}, fmt.Errorf("%w: %q", ErrInvalidTag, line)
+		}
+
+		p.Tags = append(p.Tags, Tag{Key: key, Value: value})
+	}
+
+	if i >= len(line) || line[i] != ' ' {
+		return Point{}, fmt.Errorf("%w: %q", ErrNoFields, line)
+	}
+
+	fields, i, err := parseFields(line, i+1)
+	if err != nil {
+		return Point{}, err
+	}
+
+	p.Fields = fields
+
+	if i == len(line) {
+		return p, nil
+	}
+
+	timestamp, err := strconv.ParseInt(line[i+1:], 10, 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, line[i+1:])
+	}
+
+	p.Timestamp = &timestamp
+
+	return p, nil
+}
+
+// ParseLines parses all lines of request body.
+//
+// Returns successfully parsed points and errors for invalid lines with their numbers starting from one.
+// Empty lines and comments are skipped.
+func ParseLines(data []byte) ([]Point, []error) {
+	var points []Point
+	var errs []error
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = strings.TrimLeft(line, " \t")
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		p, err := Parse(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n+1, err))
+			continue
+		}
+
+		points = append(points, p)
+	}
+
+	return points, errs
+}
+
+// parseFields parses fields starting from position i.
+//
+// Returns position of space before timestamp or length of line.
+func parseFields(line string, i int) ([]Field, int, error) {
+	var fields []Field
+
+	for {
+		key, next := scanKey(line, i, _keyEscapes, ",= ")
+		if key == "" || next >= len(line) || line[next] != '=' {
+			return nil, 0, fmt.Errorf("%w: %q", ErrInvalidField, line)
+		}
+
+		i = next + 1
+
+		var value any
+		var err error
+
+		if i < len(line) && line[i] == '"' {
+			value, i, err = scanString(line, i+1)
+		} else {
+			end := i
+			for end < len(line) && line[end] != ',' && line[end] != ' ' {
+				end++
+			}
+
+			value, err = parseValue(line[i:end])
+			i = end
+		}
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		fields = append(fields, Field{Key: key, Value: value})
+
+		if i == len(line) || line[i] == ' ' {
+			return fields, i, nil
+		}
+
+		if line[i] != ',' {
+			return nil, 0, fmt.Errorf("%w: %q", ErrInvalidField, line)
+		}
+
+		i++
+	}
+}
+
+// scanKey reads unescaped key starting from position i until one of stop characters.
+//
+// Backslash escapes characters from escapes, other backslashes are kept as is.
+// Returns key and position of stop character or length of line.
+func scanKey(line string, i int, escapes string, stops string) (string, int) {
+	var b strings.Builder
+
+	for ; i < len(line); i++ {
+		c := line[i]
+
+		if c == '\\' && i+1 < len(line) && strings.IndexByte(escapes, line[i+1]) != -1 {
+			b.WriteByte(line[i+1])
+			i++
+
+			continue
+		}
+
+		if strings.IndexByte(stops, c) != -1 {
+			break
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String(), i
+}
+
+// scanString reads string field value starting after opening quote at position i.
+//
+// Returns value and position after closing quote.
+func scanString(line string, i int) (string, int, error) {
+	var b strings.Builder
+
+	for ; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			b.WriteByte(line[i+1])
+			i++
+		case c == '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("%w: %q", ErrUnterminatedString, line)
+}
+
+// parseValue parses not quoted field value.
+func parseValue(s string) (any, error) {
+	if s == "" {
+		return nil, ErrInvalidValue
+	}
+
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch s[len(s)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		return v, nil
+	case 'u':
+		if s[0] == '-' || s[0] == '+' {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		return v, nil
+	}
+
+	// Only decimal notation is allowed, so hex floats, infinities and NaN are rejected
+	if strings.Trim(s, "0123456789.eE+-") != "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+	}
+
+	return v, nil
+}
+
+// String returns point in line protocol format.
+func (p *Point) String() string {
+	var b strings.Builder
+
+	b.WriteString(escape(p.Measurement, _measurementEscapes))
+
+	for _, t := range p.Tags {
+		b.WriteByte(',')
+		b.WriteString(escape(t.Key, _keyEscapes))
+		b.WriteByte('=')
+		b.WriteString(escape(t.Value, _keyEscapes))
+	}
+
+	for i, f := range p.Fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(escape(f.Key, _keyEscapes))
+		b.WriteByte('=')
+		b.WriteString(formatValue(f.Value))
+	}
+
+	if p.Timestamp != nil {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(*p.Timestamp, 10))
+	}
+
+	return b.String()
+}
+
+func escape(s string, escapes string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(escapes, s[i]) != -1 {
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	default:
+		return fmt.Sprint(v)
+	}
+}
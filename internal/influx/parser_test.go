@@ -0,0 +1,175 @@
+package influx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func timestamp(v int64) *int64 {
+	return &v
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Point
+		wantErr error
+	}{
+		{
+			name: "float field",
+			line: "cpu value=0.5",
+			want: Point{Measurement: "cpu", Fields: []Field{{Key: "value", Value: 0.5}}},
+		},
+		{
+			name: "tags, fields and timestamp",
+			line: "cpu,host=web1,region=eu usage_idle=98.5,requests=3i,errors=1u,up=t 1700000000000000000",
+			want: Point{
+				Measurement: "cpu",
+				Tags:        []Tag{{Key: "host", Value: "web1"}, {Key: "region", Value: "eu"}},
+				Fields: []Field{
+					{Key: "usage_idle", Value: 98.5},
+					{Key: "requests", Value: int64(3)},
+					{Key: "errors", Value: uint64(1)},
+					{Key: "up", Value: true},
+				},
+				Timestamp: timestamp(1700000000000000000),
+			},
+		},
+		{
+			name: "escaped measurement",
+			line: `disk\ usage\,total,path=/ used=1`,
+			want: Point{
+				Measurement: "disk usage,total",
+				Tags:        []Tag{{Key: "path", Value: "/"}},
+				Fields:      []Field{{Key: "used", Value: 1.0}},
+			},
+		},
+		{
+			name: "escaped tags and field keys",
+			line: `m,tag\ key=a\=b\,c field\=key=1`,
+			want: Point{
+				Measurement: "m",
+				Tags:        []Tag{{Key: "tag key", Value: "a=b,c"}},
+				Fields:      []Field{{Key: "field=key", Value: 1.0}},
+			},
+		},
+		{
+			name: "not escapable backslash is kept",
+			line: `m,path=C:\Windows value=1`,
+			want: Point{
+				Measurement: "m",
+				Tags:        []Tag{{Key: "path", Value: `C:\Windows`}},
+				Fields:      []Field{{Key: "value", Value: 1.0}},
+			},
+		},
+		{
+			name: "string field",
+			line: `m message="say \"hi\", then \\ go",code=-1i -5`,
+			want: Point{
+				Measurement: "m",
+				Fields: []Field{
+					{Key: "message", Value: `say "hi", then \ go`},
+					{Key: "code", Value: int64(-1)},
+				},
+				Timestamp: timestamp(-5),
+			},
+		},
+		{
+			name: "exponent float",
+			line: "m value=-1.5e3",
+			want: Point{Measurement: "m", Fields: []Field{{Key: "value", Value: -1500.0}}},
+		},
+		{name: "empty measurement", line: ",host=a value=1", wantErr: ErrEmptyMeasurement},
+		{name: "no fields", line: "cpu,host=a", wantErr: ErrNoFields},
+		{name: "empty fields", line: "cpu ", wantErr: ErrInvalidField},
+		{name: "tag without value", line: "cpu,host value=1", wantErr: ErrInvalidTag},
+		{name: "tag with empty value", line: "cpu,host= value=1", wantErr: ErrInvalidTag},
+		{name: "field without value", line: "cpu value= 1", wantErr: ErrInvalidValue},
+		{name: "field without key", line: "cpu =1", wantErr: ErrInvalidField},
+		{name: "invalid integer", line: "cpu value=1.5i", wantErr: ErrInvalidValue},
+		{name: "negative unsigned", line: "cpu value=-1u", wantErr: ErrInvalidValue},
+		{name: "NaN", line: "cpu value=NaN", wantErr: ErrInvalidValue},
+		{name: "hex float", line: "cpu value=0x1p-2", wantErr: ErrInvalidValue},
+		{name: "unterminated string", line: `cpu value="abc`, wantErr: ErrUnterminatedString},
+		{name: "invalid timestamp", line: "cpu value=1 now", wantErr: ErrInvalidTimestamp},
+		{name: "trailing garbage", line: "cpu value=1 1 2", wantErr: ErrInvalidTimestamp},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(test.line)
+
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, p)
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	points, errs := ParseLines([]byte("# comment\ncpu value=1\r\n\n  mem used=2i\nbad\n"))
+
+	require.Len(t, points, 2)
+	assert.Equal(t, "mem", points[1].Measurement)
+
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrNoFields)
+	assert.Contains(t, errs[0].Error(), "line 5")
+}
+
+func TestPoint_String(t *testing.T) {
+	line := `disk\ usage,path=C:\Windows,tag\=key=a\ b free=1.5,count=2i,name="a \"quoted\" \\ value",ok=false 42`
+
+	p, err := Parse(line)
+	require.NoError(t, err)
+
+	assert.Equal(t, line, p.String())
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"cpu value=0.5",
+		"cpu,host=web1,region=eu usage_idle=98.5,requests=3i,errors=1u,up=t 1700000000000000000",
+		`disk\ usage\,total,path=/ used=1`,
+		`m,tag\ key=a\=b\,c field\=key=1`,
+		`m message="say \"hi\", then \\ go",code=-1i -5`,
+		`m,a=\\ b=1`,
+		`m\ x="\`,
+		",= =",
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		p, err := Parse(line)
+		if err != nil {
+			return
+		}
+
+		if p.Measurement == "" || len(p.Fields) == 0 {
+			t.Fatalf("point without measurement or fields for line %q", line)
+		}
+
+		// Formatted point must be parsed to the same point
+		formatted := p.String()
+
+		again, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("formatted line %q of %q is not parsed: %v", formatted, line, err)
+		}
+
+		if !reflect.DeepEqual(p, again) {
+			t.Fatalf("line %q is parsed to %+v, formatted %q is parsed to %+v", line, p, formatted, again)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Names of aggregation temporalities in JSON encoding
+var _temporalities = map[string]Temporality{
+	"AGGREGATION_TEMPORALITY_UNSPECIFIED": UnspecifiedTemporality,
+	"AGGREGATION_TEMPORALITY_DELTA":       DeltaTemporality,
+	"AGGREGATION_TEMPORALITY_CUMULATIVE":  CumulativeTemporality,
+}
+
+// Messages in JSON encoding, field names are in lowerCamelCase as required by OTLP.
+type (
+	jsonRequest struct {
+		ResourceMetrics []jsonResourceMetrics `json:"resourceMetrics"`
+	}
+
+	jsonResourceMetrics struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Metrics []jsonMetric `json:"metrics"`
+		} `json:"scopeMetrics"`
+	}
+
+	jsonMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Unit        string         `json:"unit"`
+		Gauge       *jsonData      `json:"gauge"`
+		Sum         *jsonData      `json:"sum"`
+		Histogram   *jsonHistogram `json:"histogram"`
+	}
+
+	jsonData struct {
+		DataPoints             []jsonNumberDataPoint `json:"dataPoints"`
+		AggregationTemporality jsonTemporality       `json:"aggregationTemporality"`
+		IsMonotonic            bool                  `json:"isMonotonic"`
+	}
+
+	jsonHistogram struct {
+		DataPoints             []jsonHistogramDataPoint `json:"dataPoints"`
+		AggregationTemporality jsonTemporality          `json:"aggregationTemporality"`
+	}
+
+	jsonNumberDataPoint struct {
+		Attributes        []jsonKeyValue `json:"attributes"`
+		StartTimeUnixNano jsonUint       `json:"startTimeUnixNano"`
+		TimeUnixNano      jsonUint       `json:"timeUnixNano"`
+		AsDouble          *jsonFloat     `json:"asDouble"`
+		AsInt             *jsonInt       `json:"asInt"`
+	}
+
+	jsonHistogramDataPoint struct {
+		Attributes        []jsonKeyValue `json:"attributes"`
+		StartTimeUnixNano jsonUint       `json:"startTimeUnixNano"`
+		TimeUnixNano      jsonUint       `json:"timeUnixNano"`
+		Count             jsonUint       `json:"count"`
+		Sum               *jsonFloat     `json:"sum"`
+		BucketCounts      []jsonUint     `json:"bucketCounts"`
+		ExplicitBounds    []jsonFloat    `json:"explicitBounds"`
+		Min               *jsonFloat     `json:"min"`
+		Max               *jsonFloat     `json:"max"`
+	}
+
+	jsonKeyValue struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue *string    `json:"stringValue"`
+			BoolValue   *bool      `json:"boolValue"`
+			IntValue    *jsonInt   `json:"intValue"`
+			DoubleValue *jsonFloat `json:"doubleValue"`
+			BytesValue  *string    `json:"bytesValue"`
+		} `json:"value"`
+	}
+)
+
+// jsonInt is int64 encoded as string or number.
+type jsonInt int64
+
+func (v *jsonInt) UnmarshalJSON(data []byte) error {
+	i, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid integer %s", ErrInvalidMessage, data)
+	}
+
+	*v = jsonInt(i)
+
+	return nil
+}
+
+// jsonUint is uint64 encoded as string or number.
+type jsonUint uint64
+
+func (v *jsonUint) UnmarshalJSON(data []byte) error {
+	i, err := strconv.ParseUint(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid integer %s", ErrInvalidMessage, data)
+	}
+
+	*v = jsonUint(i)
+
+	return nil
+}
+
+// jsonFloat is float64 encoded as number or string, strings allow NaN and infinities.
+type jsonFloat float64
+
+func (v *jsonFloat) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+
+	switch s {
+	case "Infinity":
+		s = "+Inf"
+	case "-Infinity":
+		s = "-Inf"
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid number %s", ErrInvalidMessage, data)
+	}
+
+	*v = jsonFloat(f)
+
+	return nil
+}
+
+// jsonTemporality is aggregation temporality encoded as number or name.
+type jsonTemporality Temporality
+
+func (v *jsonTemporality) UnmarshalJSON(data []byte) error {
+	if t, ok := _temporalities[string(bytes.Trim(data, `"`))]; ok {
+		*v = jsonTemporality(t)
+		return nil
+	}
+
+	i, err := strconv.ParseInt(string(data), 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: invalid aggregation temporality %s", ErrInvalidMessage, data)
+	}
+
+	*v = jsonTemporality(i)
+
+	return nil
+}
+
+// Response messages in JSON encoding, 64-bit integers are strings as required by OTLP.
+type (
+	jsonResponse struct {
+		PartialSuccess *jsonPartialSuccess `json:"partialSuccess,omitempty"`
+	}
+
+	jsonPartialSuccess struct {
+		RejectedDataPoints int64  `json:"rejectedDataPoints,omitempty,string"`
+		ErrorMessage       string `json:"errorMessage,omitempty"`
+	}
+)
+
+// MarshalJSON encodes response to JSON encoding, full success is empty object.
+func MarshalJSON(resp *Response) ([]byte, error) {
+	var jr jsonResponse
+
+	if *resp != (Response{}) {
+		jr.PartialSuccess = &jsonPartialSuccess{
+			RejectedDataPoints: resp.RejectedDataPoints,
+			ErrorMessage:       resp.ErrorMessage,
+		}
+	}
+
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON decodes request from JSON encoding.
+func UnmarshalJSON(data []byte) (*Request, error) {
+	var jr jsonRequest
+
+	if err := json.Unmarshal(data, &jr); err != nil {
+		if errors.Is(err, ErrInvalidMessage) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMessage, err)
+	}
+
+	req := &Request{}
+
+	for _, jrm := range jr.ResourceMetrics {
+		rm := ResourceMetrics{Resource: jsonAttributes(jrm.Resource.Attributes)}
+
+		for _, sm := range jrm.ScopeMetrics {
+			for _, jm := range sm.Metrics {
+				rm.Metrics = append(rm.Metrics, jsonToMetric(jm))
+			}
+		}
+
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+	}
+
+	return req, nil
+}
+
+func jsonToMetric(jm jsonMetric) Metric {
+	m := Metric{Name: jm.Name, Description: jm.Description, Unit: jm.Unit}
+
+	var data *jsonData
+
+	switch {
+	case jm.Gauge != nil:
+		m.Type = GaugeType
+		data = jm.Gauge
+	case jm.Sum != nil:
+		m.Type = SumType
+		m.Temporality = Temporality(jm.Sum.AggregationTemporality)
+		m.Monotonic = jm.Sum.IsMonotonic
+		data = jm.Sum
+	case jm.Histogram != nil:
+		m.Type = HistogramType
+		m.Temporality = Temporality(jm.Histogram.AggregationTemporality)
+
+		for _, jp := range jm.Histogram.DataPoints {
+			m.HistogramPoints = append(m.HistogramPoints, jsonToHistogramDataPoint(jp))
+		}
+
+		return m
+	default:
+		return m
+	}
+
+	for _, jp := range data.DataPoints {
+		p := NumberDataPoint{
+			Attributes: jsonAttributes(jp.Attributes),
+			StartTime:  uint64(jp.StartTimeUnixNano),
+			Time:       uint64(jp.TimeUnixNano),
+		}
+
+		switch {
+		case jp.AsInt != nil:
+			p.IsInt = true
+			p.IntValue = int64(*jp.AsInt)
+		case jp.AsDouble != nil:
+			p.Value = float64(*jp.AsDouble)
+		}
+
+		m.NumberPoints = append(m.NumberPoints, p)
+	}
+
+	return m
+}
+
+func jsonToHistogramDataPoint(jp jsonHistogramDataPoint) HistogramDataPoint {
+	p := HistogramDataPoint{
+		Attributes: jsonAttributes(jp.Attributes),
+		StartTime:  uint64(jp.StartTimeUnixNano),
+		Time:       uint64(jp.TimeUnixNano),
+		Count:      uint64(jp.Count),
+		Sum:        (*float64)(jp.Sum),
+		Min:        (*float64)(jp.Min),
+		Max:        (*float64)(jp.Max),
+	}
+
+	for _, c := range jp.BucketCounts {
+		p.BucketCounts = append(p.BucketCounts, uint64(c))
+	}
+
+	for _, b := range jp.ExplicitBounds {
+		p.ExplicitBounds = append(p.ExplicitBounds, float64(b))
+	}
+
+	return p
+}
+
+// jsonAttributes converts attributes, attributes with unsupported or empty values are skipped.
+func jsonAttributes(kvs []jsonKeyValue) []Attribute {
+	var attributes []Attribute
+
+	for _, kv := range kvs {
+		var value string
+
+		switch v := kv.Value; {
+		case v.StringValue != nil:
+			value = *v.StringValue
+		case v.BoolValue != nil:
+			value = formatBool(*v.BoolValue)
+		case v.IntValue != nil:
+			value = formatInt(int64(*v.IntValue))
+		case v.DoubleValue != nil:
+			value = formatFloat(float64(*v.DoubleValue))
+		case v.BytesValue != nil:
+			// Bytes are base64 encoded in JSON and hex encoded in attribute value as for protobuf
+			b, err := base64.StdEncoding.DecodeString(*v.BytesValue)
+			if err != nil {
+				continue
+			}
+
+			value = formatBytes(b)
+		}
+
+		if value != "" {
+			attributes = append(attributes, Attribute{Key: kv.Key, Value: value})
+		}
+	}
+
+	return attributes
+}
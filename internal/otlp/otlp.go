@@ -0,0 +1,131 @@
+// Package otlp contains decoders for OpenTelemetry OTLP metrics export requests.
+//
+// Both protobuf and JSON encodings of ExportMetricsServiceRequest are supported,
+// ExportMetricsServiceResponse is encoded to report partial success.
+// Only data used by metric server is decoded: resource attributes, gauges, sums and
+// histograms with their data points. Exponential histograms, summaries and exemplars are skipped.
+package otlp
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+// ErrInvalidMessage is returned for message that is not valid ExportMetricsServiceRequest.
+var ErrInvalidMessage = errors.New("invalid OTLP message")
+
+// Temporality describes aggregation temporality of sums and histograms.
+type Temporality int32
+
+// Aggregation temporalities of OTLP
+const (
+	UnspecifiedTemporality Temporality = 0
+	DeltaTemporality       Temporality = 1
+	CumulativeTemporality  Temporality = 2
+)
+
+// MetricType describes type of metric data.
+type MetricType int
+
+// Supported types of metric data, other types are decoded as UnsupportedType
+const (
+	UnsupportedType MetricType = iota
+	GaugeType
+	SumType
+	HistogramType
+)
+
+// Attribute describes attribute with value formatted as string.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// NumberDataPoint describes data point of gauge or sum.
+type NumberDataPoint struct {
+	Attributes []Attribute
+	// StartTime - start of aggregation in nanoseconds since epoch.
+	StartTime uint64
+	// Time - time of data point in nanoseconds since epoch.
+	Time uint64
+	// IsInt - true if point has integer value.
+	IsInt    bool
+	IntValue int64
+	Value    float64
+}
+
+// Float returns value of data point as float.
+func (p *NumberDataPoint) Float() float64 {
+	if p.IsInt {
+		return float64(p.IntValue)
+	}
+
+	return p.Value
+}
+
+// HistogramDataPoint describes data point of histogram.
+type HistogramDataPoint struct {
+	Attributes []Attribute
+	// StartTime - start of aggregation in nanoseconds since epoch.
+	StartTime uint64
+	// Time - time of data point in nanoseconds since epoch.
+	Time  uint64
+	Count uint64
+	Sum   *float64
+	// BucketCounts - counts of buckets, there is one more bucket than bounds.
+	BucketCounts []uint64
+	// ExplicitBounds - upper bounds of buckets.
+	ExplicitBounds []float64
+	Min            *float64
+	Max            *float64
+}
+
+// Metric describes one metric with its data points.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Type        MetricType
+	// Temporality - aggregation temporality of sum or histogram.
+	Temporality Temporality
+	// Monotonic - true for monotonic sum.
+	Monotonic       bool
+	NumberPoints    []NumberDataPoint
+	HistogramPoints []HistogramDataPoint
+}
+
+// ResourceMetrics describes metrics of one resource, metrics of all scopes are joined.
+type ResourceMetrics struct {
+	Resource []Attribute
+	Metrics  []Metric
+}
+
+// Request describes metrics export request.
+type Request struct {
+	ResourceMetrics []ResourceMetrics
+}
+
+// Response describes metrics export response, zero value means full success.
+type Response struct {
+	// RejectedDataPoints - amount of data points rejected by server.
+	RejectedDataPoints int64
+	// ErrorMessage - reason of rejection.
+	ErrorMessage string
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatBool(v bool) string {
+	return strconv.FormatBool(v)
+}
+
+func formatBytes(v []byte) string {
+	return hex.EncodeToString(v)
+}
@@ -0,0 +1,257 @@
+package otlp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func float64p(v float64) *float64 {
+	return &v
+}
+
+// want is request encoded by testdata below in both encodings.
+var want = &Request{
+	ResourceMetrics: []ResourceMetrics{
+		{
+			Resource: []Attribute{{Key: "service.name", Value: "checkout"}, {Key: "service.instance", Value: "7"}},
+			Metrics: []Metric{
+				{
+					Name:        "http.requests",
+					Description: "Handled requests",
+					Unit:        "1",
+					Type:        SumType,
+					Temporality: CumulativeTemporality,
+					Monotonic:   true,
+					NumberPoints: []NumberDataPoint{
+						{
+							Attributes: []Attribute{{Key: "code", Value: "200"}, {Key: "ok", Value: "true"}},
+							StartTime:  1700000000000000000,
+							Time:       1700000010000000000,
+							IsInt:      true,
+							IntValue:   42,
+						},
+					},
+				},
+				{
+					Name:         "memory.usage",
+					Type:         GaugeType,
+					NumberPoints: []NumberDataPoint{{Time: 1700000010000000000, Value: 0.5}},
+				},
+				{
+					Name:        "http.duration",
+					Unit:        "s",
+					Type:        HistogramType,
+					Temporality: DeltaTemporality,
+					HistogramPoints: []HistogramDataPoint{
+						{
+							Time:           1700000010000000000,
+							Count:          3,
+							Sum:            float64p(1.5),
+							BucketCounts:   []uint64{1, 2, 0},
+							ExplicitBounds: []float64{0.1, 1},
+							Max:            float64p(1.2),
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+const wantJSON = `{
+  "resourceMetrics": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "service.instance", "value": {"intValue": "7"}},
+      {"key": "service.tags", "value": {"arrayValue": {"values": []}}}
+    ]},
+    "scopeMetrics": [{
+      "scope": {"name": "app"},
+      "metrics": [
+        {
+          "name": "http.requests", "description": "Handled requests", "unit": "1",
+          "sum": {
+            "dataPoints": [{
+              "attributes": [
+                {"key": "code", "value": {"stringValue": "200"}},
+                {"key": "ok", "value": {"boolValue": true}}
+              ],
+              "startTimeUnixNano": "1700000000000000000", "timeUnixNano": "1700000010000000000", "asInt": "42"
+            }],
+            "aggregationTemporality": 2, "isMonotonic": true
+          }
+        },
+        {"name": "memory.usage", "gauge": {"dataPoints": [{"timeUnixNano": 1700000010000000000, "asDouble": 0.5}]}}
+      ]
+    }, {
+      "metrics": [{
+        "name": "http.duration", "unit": "s",
+        "histogram": {
+          "dataPoints": [{
+            "timeUnixNano": "1700000010000000000", "count": "3", "sum": 1.5,
+            "bucketCounts": ["1", "2", "0"], "explicitBounds": [0.1, 1], "max": 1.2
+          }],
+          "aggregationTemporality": "AGGREGATION_TEMPORALITY_DELTA"
+        }
+      }]
+    }]
+  }]
+}`
+
+func message(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+
+	return b
+}
+
+func bytesField(num protowire.Number, data []byte) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, data)
+}
+
+func stringField(num protowire.Number, s string) []byte {
+	return bytesField(num, []byte(s))
+}
+
+func varintField(num protowire.Number, v uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func fixedField(num protowire.Number, v uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func doubleField(num protowire.Number, v float64) []byte {
+	return fixedField(num, math.Float64bits(v))
+}
+
+func attribute(key string, value []byte) []byte {
+	return message(stringField(_keyValueKey, key), bytesField(_keyValueValue, value))
+}
+
+func wantProto() []byte {
+	requests := message(
+		stringField(_metricName, "http.requests"),
+		stringField(_metricDescription, "Handled requests"),
+		stringField(_metricUnit, "1"),
+		bytesField(_metricSum, message(
+			bytesField(_dataPoints, message(
+				bytesField(_numberAttributes, attribute("code", stringField(_anyString, "200"))),
+				bytesField(_numberAttributes, attribute("ok", varintField(_anyBool, 1))),
+				fixedField(_numberStartTime, 1700000000000000000),
+				fixedField(_numberTime, 1700000010000000000),
+				fixedField(_numberAsInt, 42),
+			)),
+			varintField(_aggregationTemporality, uint64(CumulativeTemporality)),
+			varintField(_sumIsMonotonic, 1),
+		)),
+	)
+
+	usage := message(
+		stringField(_metricName, "memory.usage"),
+		bytesField(_metricGauge, bytesField(_dataPoints, message(
+			fixedField(_numberTime, 1700000010000000000),
+			doubleField(_numberAsDouble, 0.5),
+		))),
+	)
+
+	var counts, bounds []byte
+	for _, c := range []uint64{1, 2, 0} {
+		counts = protowire.AppendFixed64(counts, c)
+	}
+
+	for _, b := range []float64{0.1, 1} {
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(b))
+	}
+
+	duration := message(
+		stringField(_metricName, "http.duration"),
+		stringField(_metricUnit, "s"),
+		bytesField(_metricHistogram, message(
+			bytesField(_dataPoints, message(
+				fixedField(_histogramTime, 1700000010000000000),
+				fixedField(_histogramCount, 3),
+				doubleField(_histogramSum, 1.5),
+				bytesField(_histogramBucketCounts, counts),
+				bytesField(_histogramExplicitBounds, bounds),
+				doubleField(_histogramMax, 1.2),
+			)),
+			varintField(_aggregationTemporality, uint64(DeltaTemporality)),
+		)),
+	)
+
+	resource := message(
+		bytesField(_resourceAttributes, attribute("service.name", stringField(_anyString, "checkout"))),
+		bytesField(_resourceAttributes, attribute("service.instance", varintField(_anyInt, 7))),
+		bytesField(_resourceAttributes, attribute("service.tags", bytesField(5, nil))),
+	)
+
+	return bytesField(_requestResourceMetrics, message(
+		bytesField(_resourceMetricsResource, resource),
+		bytesField(_resourceMetricsScopeMetrics, message(
+			bytesField(1, stringField(1, "app")),
+			bytesField(_scopeMetricsMetrics, requests),
+			bytesField(_scopeMetricsMetrics, usage),
+		)),
+		bytesField(_resourceMetricsScopeMetrics, bytesField(_scopeMetricsMetrics, duration)),
+	))
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	got, err := UnmarshalProto(wantProto())
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	got, err := UnmarshalJSON([]byte(wantJSON))
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got)
+}
+
+func TestUnmarshal_invalid(t *testing.T) {
+	data := wantProto()
+
+	_, err := UnmarshalProto(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = UnmarshalJSON([]byte(`{"resourceMetrics": [`))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = UnmarshalJSON([]byte(`{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+		{"name": "a", "gauge": {"dataPoints": [{"asInt": "1.5"}]}}
+	]}]}]}`))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestMarshalProto(t *testing.T) {
+	assert.Empty(t, MarshalProto(&Response{}))
+
+	got := MarshalProto(&Response{RejectedDataPoints: 3, ErrorMessage: "type conflict"})
+
+	assert.Equal(t, bytesField(_responsePartialSuccess, message(
+		varintField(_partialSuccessRejectedDataPoints, 3),
+		stringField(_partialSuccessErrorMessage, "type conflict"),
+	)), got)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	got, err := MarshalJSON(&Response{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(got))
+
+	got, err = MarshalJSON(&Response{RejectedDataPoints: 3, ErrorMessage: "type conflict"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"partialSuccess": {"rejectedDataPoints": "3", "errorMessage": "type conflict"}}`, string(got))
+}
@@ -0,0 +1,393 @@
+package otlp
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of protobuf messages
+const (
+	_requestResourceMetrics = 1
+
+	_resourceMetricsResource     = 1
+	_resourceMetricsScopeMetrics = 2
+
+	_resourceAttributes = 1
+
+	_scopeMetricsMetrics = 2
+
+	_metricName        = 1
+	_metricDescription = 2
+	_metricUnit        = 3
+	_metricGauge       = 5
+	_metricSum         = 7
+	_metricHistogram   = 9
+
+	_dataPoints             = 1
+	_aggregationTemporality = 2
+	_sumIsMonotonic         = 3
+
+	_numberStartTime  = 2
+	_numberTime       = 3
+	_numberAsDouble   = 4
+	_numberAsInt      = 6
+	_numberAttributes = 7
+
+	_histogramStartTime      = 2
+	_histogramTime           = 3
+	_histogramCount          = 4
+	_histogramSum            = 5
+	_histogramBucketCounts   = 6
+	_histogramExplicitBounds = 7
+	_histogramAttributes     = 9
+	_histogramMin            = 11
+	_histogramMax            = 12
+
+	_keyValueKey   = 1
+	_keyValueValue = 2
+
+	_anyString = 1
+	_anyBool   = 2
+	_anyInt    = 3
+	_anyDouble = 4
+	_anyBytes  = 7
+
+	_responsePartialSuccess = 1
+
+	_partialSuccessRejectedDataPoints = 1
+	_partialSuccessErrorMessage       = 2
+)
+
+// field describes one decoded field of protobuf message.
+type field struct {
+	num protowire.Number
+	typ protowire.Type
+	// data - data of bytes field.
+	data []byte
+	// v - value of varint or fixed field.
+	v uint64
+}
+
+func (f *field) is(num protowire.Number, typ protowire.Type) bool {
+	return f.num == num && f.typ == typ
+}
+
+// UnmarshalProto decodes request from protobuf message.
+func UnmarshalProto(data []byte) (*Request, error) {
+	req := &Request{}
+
+	err := walk(data, func(f field) error {
+		if !f.is(_requestResourceMetrics, protowire.BytesType) {
+			return nil
+		}
+
+		rm, err := unmarshalResourceMetrics(f.data)
+		if err != nil {
+			return err
+		}
+
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// MarshalProto encodes response to protobuf message, full success is empty message.
+func MarshalProto(resp *Response) []byte {
+	if *resp == (Response{}) {
+		return nil
+	}
+
+	var ps []byte
+
+	if resp.RejectedDataPoints != 0 {
+		ps = protowire.AppendTag(ps, _partialSuccessRejectedDataPoints, protowire.VarintType)
+		ps = protowire.AppendVarint(ps, uint64(resp.RejectedDataPoints))
+	}
+
+	if resp.ErrorMessage != "" {
+		ps = protowire.AppendTag(ps, _partialSuccessErrorMessage, protowire.BytesType)
+		ps = protowire.AppendString(ps, resp.ErrorMessage)
+	}
+
+	data := protowire.AppendTag(nil, _responsePartialSuccess, protowire.BytesType)
+
+	return protowire.AppendBytes(data, ps)
+}
+
+func unmarshalResourceMetrics(data []byte) (ResourceMetrics, error) {
+	var rm ResourceMetrics
+
+	err := walk(data, func(f field) error {
+		switch {
+		case f.is(_resourceMetricsResource, protowire.BytesType):
+			return walk(f.data, func(f field) error {
+				if !f.is(_resourceAttributes, protowire.BytesType) {
+					return nil
+				}
+
+				return appendAttribute(&rm.Resource, f.data)
+			})
+		case f.is(_resourceMetricsScopeMetrics, protowire.BytesType):
+			return walk(f.data, func(f field) error {
+				if !f.is(_scopeMetricsMetrics, protowire.BytesType) {
+					return nil
+				}
+
+				m, err := unmarshalMetric(f.data)
+				if err != nil {
+					return err
+				}
+
+				rm.Metrics = append(rm.Metrics, m)
+
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return rm, err
+}
+
+func unmarshalMetric(data []byte) (Metric, error) {
+	var m Metric
+
+	err := walk(data, func(f field) error {
+		if f.typ != protowire.BytesType {
+			return nil
+		}
+
+		switch f.num {
+		case _metricName:
+			m.Name = string(f.data)
+		case _metricDescription:
+			m.Description = string(f.data)
+		case _metricUnit:
+			m.Unit = string(f.data)
+		case _metricGauge:
+			m.Type = GaugeType
+			return unmarshalData(&m, f.data)
+		case _metricSum:
+			m.Type = SumType
+			return unmarshalData(&m, f.data)
+		case _metricHistogram:
+			m.Type = HistogramType
+			return unmarshalData(&m, f.data)
+		}
+
+		return nil
+	})
+
+	return m, err
+}
+
+// unmarshalData decodes gauge, sum or histogram message according to type of metric.
+func unmarshalData(m *Metric, data []byte) error {
+	return walk(data, func(f field) error {
+		switch {
+		case f.is(_dataPoints, protowire.BytesType) && m.Type == HistogramType:
+			p, err := unmarshalHistogramDataPoint(f.data)
+			if err != nil {
+				return err
+			}
+
+			m.HistogramPoints = append(m.HistogramPoints, p)
+		case f.is(_dataPoints, protowire.BytesType):
+			p, err := unmarshalNumberDataPoint(f.data)
+			if err != nil {
+				return err
+			}
+
+			m.NumberPoints = append(m.NumberPoints, p)
+		case f.is(_aggregationTemporality, protowire.VarintType) && m.Type != GaugeType:
+			m.Temporality = Temporality(f.v)
+		case f.is(_sumIsMonotonic, protowire.VarintType) && m.Type == SumType:
+			m.Monotonic = f.v != 0
+		}
+
+		return nil
+	})
+}
+
+func unmarshalNumberDataPoint(data []byte) (NumberDataPoint, error) {
+	var p NumberDataPoint
+
+	err := walk(data, func(f field) error {
+		switch {
+		case f.is(_numberAttributes, protowire.BytesType):
+			return appendAttribute(&p.Attributes, f.data)
+		case f.is(_numberStartTime, protowire.Fixed64Type):
+			p.StartTime = f.v
+		case f.is(_numberTime, protowire.Fixed64Type):
+			p.Time = f.v
+		case f.is(_numberAsDouble, protowire.Fixed64Type):
+			p.IsInt = false
+			p.Value = math.Float64frombits(f.v)
+		case f.is(_numberAsInt, protowire.Fixed64Type):
+			p.IsInt = true
+			p.IntValue = int64(f.v)
+		}
+
+		return nil
+	})
+
+	return p, err
+}
+
+func unmarshalHistogramDataPoint(data []byte) (HistogramDataPoint, error) {
+	var p HistogramDataPoint
+
+	err := walk(data, func(f field) error {
+		switch {
+		case f.is(_histogramAttributes, protowire.BytesType):
+			return appendAttribute(&p.Attributes, f.data)
+		case f.is(_histogramStartTime, protowire.Fixed64Type):
+			p.StartTime = f.v
+		case f.is(_histogramTime, protowire.Fixed64Type):
+			p.Time = f.v
+		case f.is(_histogramCount, protowire.Fixed64Type):
+			p.Count = f.v
+		case f.is(_histogramSum, protowire.Fixed64Type):
+			p.Sum = float(f.v)
+		case f.is(_histogramMin, protowire.Fixed64Type):
+			p.Min = float(f.v)
+		case f.is(_histogramMax, protowire.Fixed64Type):
+			p.Max = float(f.v)
+		case f.num == _histogramBucketCounts:
+			return appendFixed64(&p.BucketCounts, f)
+		case f.num == _histogramExplicitBounds:
+			var bounds []uint64
+			if err := appendFixed64(&bounds, f); err != nil {
+				return err
+			}
+
+			for _, b := range bounds {
+				p.ExplicitBounds = append(p.ExplicitBounds, math.Float64frombits(b))
+			}
+		}
+
+		return nil
+	})
+
+	return p, err
+}
+
+func float(v uint64) *float64 {
+	f := math.Float64frombits(v)
+	return &f
+}
+
+// appendFixed64 appends values of repeated fixed64 field in packed or not packed encoding.
+func appendFixed64(values *[]uint64, f field) error {
+	switch f.typ {
+	case protowire.Fixed64Type:
+		*values = append(*values, f.v)
+	case protowire.BytesType:
+		data := f.data
+
+		for len(data) > 0 {
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return fmt.Errorf("%w: %s", ErrInvalidMessage, protowire.ParseError(n))
+			}
+
+			*values = append(*values, v)
+			data = data[n:]
+		}
+	}
+
+	return nil
+}
+
+// appendAttribute decodes KeyValue message and appends it to attributes.
+//
+// Attributes with array, key-value list or empty values are skipped.
+func appendAttribute(attributes *[]Attribute, data []byte) error {
+	var a Attribute
+	var ok bool
+
+	err := walk(data, func(f field) error {
+		switch {
+		case f.is(_keyValueKey, protowire.BytesType):
+			a.Key = string(f.data)
+		case f.is(_keyValueValue, protowire.BytesType):
+			return walk(f.data, func(f field) error {
+				switch {
+				case f.is(_anyString, protowire.BytesType):
+					a.Value, ok = string(f.data), true
+				case f.is(_anyBool, protowire.VarintType):
+					a.Value, ok = formatBool(f.v != 0), true
+				case f.is(_anyInt, protowire.VarintType):
+					a.Value, ok = formatInt(int64(f.v)), true
+				case f.is(_anyDouble, protowire.Fixed64Type):
+					a.Value, ok = formatFloat(math.Float64frombits(f.v)), true
+				case f.is(_anyBytes, protowire.BytesType):
+					a.Value, ok = formatBytes(f.data), true
+				}
+
+				return nil
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ok && a.Value != "" {
+		*attributes = append(*attributes, a)
+	}
+
+	return nil
+}
+
+// walk calls fn for every field of message, groups are not supported.
+func walk(data []byte, fn func(f field) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, protowire.ParseError(n))
+		}
+
+		data = data[n:]
+
+		f := field{num: num, typ: typ}
+
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.v, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(data)
+			f.v = uint64(v)
+		case protowire.BytesType:
+			f.data, n = protowire.ConsumeBytes(data)
+		default:
+			return fmt.Errorf("%w: unsupported wire type %d", ErrInvalidMessage, typ)
+		}
+
+		if n < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, protowire.ParseError(n))
+		}
+
+		data = data[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
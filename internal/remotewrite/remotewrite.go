@@ -0,0 +1,310 @@
+// Package remotewrite contains codec for Prometheus remote write protocol.
+//
+// Only fields of WriteRequest used by metric server are supported: time series with labels
+// and float samples and metric metadata with type hints. Exemplars and native histograms are skipped.
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// NameLabel is label with metric name.
+const NameLabel = "__name__"
+
+// ErrInvalidMessage is returned for message that is not valid WriteRequest.
+var ErrInvalidMessage = errors.New("invalid remote write message")
+
+// MetricType describes type hint of metric family.
+type MetricType int32
+
+// Metric types of remote write protocol
+const (
+	UnknownType        MetricType = 0
+	CounterType        MetricType = 1
+	GaugeType          MetricType = 2
+	HistogramType      MetricType = 3
+	GaugeHistogramType MetricType = 4
+	SummaryType        MetricType = 5
+	InfoType           MetricType = 6
+	StateSetType       MetricType = 7
+)
+
+// Field numbers of protobuf messages
+const (
+	_requestTimeSeries = 1
+	_requestMetadata   = 3
+
+	_seriesLabels  = 1
+	_seriesSamples = 2
+
+	_labelName  = 1
+	_labelValue = 2
+
+	_sampleValue     = 1
+	_sampleTimestamp = 2
+
+	_metadataType       = 1
+	_metadataFamilyName = 2
+	_metadataHelp       = 4
+	_metadataUnit       = 5
+)
+
+// Label describes label of time series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample describes one sample of time series.
+type Sample struct {
+	Value float64
+	// Timestamp - timestamp in milliseconds.
+	Timestamp int64
+}
+
+// TimeSeries describes time series with its samples.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Name returns value of name label.
+func (ts *TimeSeries) Name() string {
+	for _, l := range ts.Labels {
+		if l.Name == NameLabel {
+			return l.Value
+		}
+	}
+
+	return ""
+}
+
+// Metadata describes metadata of metric family.
+type Metadata struct {
+	Type       MetricType
+	FamilyName string
+	Help       string
+	Unit       string
+}
+
+// WriteRequest describes remote write request.
+type WriteRequest struct {
+	TimeSeries []TimeSeries
+	Metadata   []Metadata
+}
+
+// Unmarshal decodes WriteRequest from protobuf message.
+func Unmarshal(data []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case _requestTimeSeries:
+			ts, err := unmarshalTimeSeries(value)
+			if err != nil {
+				return err
+			}
+
+			req.TimeSeries = append(req.TimeSeries, ts)
+		case _requestMetadata:
+			m, err := unmarshalMetadata(value)
+			if err != nil {
+				return err
+			}
+
+			req.Metadata = append(req.Metadata, m)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func unmarshalTimeSeries(data []byte) (TimeSeries, error) {
+	var ts TimeSeries
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case _seriesLabels:
+			var l Label
+
+			err := walk(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				switch {
+				case num == _labelName && typ == protowire.BytesType:
+					l.Name = string(value)
+				case num == _labelValue && typ == protowire.BytesType:
+					l.Value = string(value)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			ts.Labels = append(ts.Labels, l)
+		case _seriesSamples:
+			var s Sample
+
+			err := walk(value, func(num protowire.Number, typ protowire.Type, _ []byte, v uint64) error {
+				switch {
+				case num == _sampleValue && typ == protowire.Fixed64Type:
+					s.Value = math.Float64frombits(v)
+				case num == _sampleTimestamp && typ == protowire.VarintType:
+					s.Timestamp = int64(v)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			ts.Samples = append(ts.Samples, s)
+		}
+
+		return nil
+	})
+
+	return ts, err
+}
+
+func unmarshalMetadata(data []byte) (Metadata, error) {
+	var m Metadata
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte, v uint64) error {
+		switch {
+		case num == _metadataType && typ == protowire.VarintType:
+			m.Type = MetricType(v)
+		case num == _metadataFamilyName && typ == protowire.BytesType:
+			m.FamilyName = string(value)
+		case num == _metadataHelp && typ == protowire.BytesType:
+			m.Help = string(value)
+		case num == _metadataUnit && typ == protowire.BytesType:
+			m.Unit = string(value)
+		}
+
+		return nil
+	})
+
+	return m, err
+}
+
+// walk calls fn for every field of message.
+//
+// For bytes fields value contains field data, for varint and fixed fields v contains field value.
+// Groups are not supported.
+func walk(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, v uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, protowire.ParseError(n))
+		}
+
+		data = data[n:]
+
+		var value []byte
+		var v uint64
+
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(data)
+			v = uint64(v32)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			return fmt.Errorf("%w: unsupported wire type %d", ErrInvalidMessage, typ)
+		}
+
+		if n < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, protowire.ParseError(n))
+		}
+
+		data = data[n:]
+
+		if err := fn(num, typ, value, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Marshal encodes WriteRequest to protobuf message.
+func Marshal(req *WriteRequest) []byte {
+	var b []byte
+
+	for _, ts := range req.TimeSeries {
+		var series []byte
+
+		for _, l := range ts.Labels {
+			var label []byte
+			label = appendString(label, _labelName, l.Name)
+			label = appendString(label, _labelValue, l.Value)
+
+			series = appendMessage(series, _seriesLabels, label)
+		}
+
+		for _, s := range ts.Samples {
+			var sample []byte
+			sample = protowire.AppendTag(sample, _sampleValue, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+			sample = protowire.AppendTag(sample, _sampleTimestamp, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+
+			series = appendMessage(series, _seriesSamples, sample)
+		}
+
+		b = appendMessage(b, _requestTimeSeries, series)
+	}
+
+	for _, m := range req.Metadata {
+		var metadata []byte
+		metadata = protowire.AppendTag(metadata, _metadataType, protowire.VarintType)
+		metadata = protowire.AppendVarint(metadata, uint64(m.Type))
+		metadata = appendString(metadata, _metadataFamilyName, m.FamilyName)
+		metadata = appendString(metadata, _metadataHelp, m.Help)
+		metadata = appendString(metadata, _metadataUnit, m.Unit)
+
+		b = appendMessage(b, _requestMetadata, metadata)
+	}
+
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+
+	return protowire.AppendBytes(b, message)
+}
@@ -0,0 +1,68 @@
+package remotewrite
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	req := &WriteRequest{
+		TimeSeries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: NameLabel, Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+				Samples: []Sample{{Value: 10, Timestamp: 1700000000000}, {Value: 12, Timestamp: 1700000015000}},
+			},
+			{
+				Labels:  []Label{{Name: NameLabel, Value: "temperature"}},
+				Samples: []Sample{{Value: -3.5, Timestamp: -1}},
+			},
+		},
+		Metadata: []Metadata{
+			{Type: CounterType, FamilyName: "http_requests_total", Help: "Total requests.", Unit: "requests"},
+		},
+	}
+
+	got, err := Unmarshal(Marshal(req))
+	require.NoError(t, err)
+
+	assert.Equal(t, req, got)
+	assert.Equal(t, "http_requests_total", got.TimeSeries[0].Name())
+}
+
+func TestUnmarshal_unknownFields(t *testing.T) {
+	var series []byte
+	series = appendMessage(series, _seriesLabels, appendString(nil, _labelName, NameLabel))
+	// Exemplar is skipped
+	series = appendMessage(series, 3, appendString(nil, 1, "ignored"))
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, _sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(1))
+	series = appendMessage(series, _seriesSamples, sample)
+
+	var data []byte
+	data = appendMessage(data, _requestTimeSeries, series)
+	data = protowire.AppendTag(data, 10, protowire.VarintType)
+	data = protowire.AppendVarint(data, 42)
+
+	got, err := Unmarshal(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, &WriteRequest{
+		TimeSeries: []TimeSeries{{Labels: []Label{{Name: NameLabel}}, Samples: []Sample{{Value: 1}}}},
+	}, got)
+}
+
+func TestUnmarshal_invalid(t *testing.T) {
+	data := Marshal(&WriteRequest{TimeSeries: []TimeSeries{{Labels: []Label{{Name: NameLabel, Value: "cpu"}}}}})
+
+	_, err := Unmarshal(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = Unmarshal([]byte("not a protobuf message"))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
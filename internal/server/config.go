@@ -1,33 +1,51 @@
 package server
 
 import (
-	"encoding/json"
 	"flag"
-	"os"
-	"strconv"
+	"fmt"
 	"time"
 
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/config"
+	"github.com/kaa-it/go-devops/internal/server/graphite"
+	"github.com/kaa-it/go-devops/internal/server/statsd"
 	"github.com/kaa-it/go-devops/internal/server/storage/db"
 	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+	"github.com/kaa-it/go-devops/internal/server/updating"
 )
 
 const (
-	_serverAddress       = ":8080"
-	_logLevel            = "info"
-	_storeIntervalInSecs = 300
-	_storeFilePath       = "/tmp/metrics-db.json"
-	_restore             = true
+	_serverAddress = ":8080"
+	_logLevel      = "info"
+	_storeInterval = 300 * time.Second
+	_storeFilePath = "/tmp/metrics-db.json"
+	_restore       = true
+	_statsdFlush   = 10 * time.Second
+	_maxRequest    = 10 << 20
 )
 
+// configFile describes configuration file, keys are read by config package from json tags.
 type configFile struct {
-	Address        string `json:"address"`
-	Restore        bool   `json:"restore"`
-	StoreInterval  int    `json:"store_interval"`
-	StoreFilePath  string `json:"store_file"`
-	DatabaseDSN    string `json:"database_dsn"`
-	Key            string `json:"key"`
-	PrivateKeyPath string `json:"crypto_key"`
-	LogLevel       string `json:"log_level"`
+	Address         string         `json:"address"`
+	Restore         bool           `json:"restore"`
+	StoreInterval   time.Duration  `json:"store_interval"`
+	StoreFilePath   string         `json:"store_file"`
+	DatabaseDSN     string         `json:"database_dsn"`
+	Key             string         `json:"key"`
+	PrivateKeyPath  string         `json:"crypto_key"`
+	LogLevel        string         `json:"log_level"`
+	MaxRequestSize  int64          `json:"max_request_size"`
+	BatchMode       string         `json:"batch_mode"`
+	GraphiteAddress string         `json:"graphite_address"`
+	GraphiteRules   []graphiteRule `json:"graphite_rules"`
+	StatsDAddress   string         `json:"statsd_address"`
+	StatsDFlush     time.Duration  `json:"statsd_flush_interval"`
+}
+
+// graphiteRule describes rule for Graphite metric types in configuration file.
+type graphiteRule struct {
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
 }
 
 // SelfConfig contains configuration for the server itself.
@@ -40,6 +58,10 @@ type SelfConfig struct {
 	Key string
 	// PrivateKeyPath - path to file with private RSA key to dencrypt requests
 	PrivateKeyPath string
+	// MaxRequestSize - maximal size of update request body in bytes, zero disables limit.
+	MaxRequestSize int64
+	// BatchMode - how batches with invalid metrics are applied: atomic or partial.
+	BatchMode updating.BatchMode
 }
 
 // Config contains total configuration for server.
@@ -50,6 +72,10 @@ type Config struct {
 	Storage memory.StorageConfig
 	// DBStorage - configuration for database storage.
 	DBStorage db.StorageConfig
+	// Graphite - configuration for Graphite listener.
+	Graphite graphite.Config
+	// StatsD - configuration for StatsD listener.
+	StatsD statsd.Config
 }
 
 // NewConfig creates total server configuration.
@@ -102,6 +128,30 @@ func NewConfig() (*Config, error) {
 		"path to file with RSA private crypto key",
 	)
 
+	maxRequestSize := flag.Int64(
+		"max-request-size",
+		-1,
+		"maximal size of update request body in bytes, 0 disables limit",
+	)
+
+	batchMode := flag.String(
+		"batch-mode",
+		"",
+		"how batches with invalid metrics are applied: atomic or partial",
+	)
+
+	graphiteAddress := flag.String(
+		"graphite-address",
+		"",
+		"address to listen for Graphite plaintext protocol as \"host:port\"",
+	)
+
+	statsdAddress := flag.String(
+		"statsd-address",
+		"",
+		"UDP address to listen for StatsD metrics as \"host:port\"",
+	)
+
 	configPath := flag.String(
 		"c",
 		"",
@@ -110,125 +160,153 @@ func NewConfig() (*Config, error) {
 
 	flag.Parse()
 
-	configFilePath := getEnv("CONFIG", *configPath)
-
-	config := configFile{
-		Address:        _serverAddress,
-		Restore:        _restore,
-		StoreInterval:  _storeIntervalInSecs,
-		StoreFilePath:  _storeFilePath,
-		DatabaseDSN:    "",
-		Key:            "",
-		PrivateKeyPath: "",
-		LogLevel:       _logLevel,
+	configFilePath := config.GetEnv("CONFIG", *configPath)
+
+	file := configFile{
+		Address:         _serverAddress,
+		Restore:         _restore,
+		StoreInterval:   _storeInterval,
+		StoreFilePath:   _storeFilePath,
+		DatabaseDSN:     "",
+		Key:             "",
+		PrivateKeyPath:  "",
+		LogLevel:        _logLevel,
+		MaxRequestSize:  _maxRequest,
+		BatchMode:       string(updating.AtomicMode),
+		GraphiteAddress: "",
+		StatsDAddress:   "",
+		StatsDFlush:     _statsdFlush,
 	}
 
 	if configFilePath != "" {
-		if err := readConfig(configFilePath, &config); err != nil {
+		if err := config.Read(configFilePath, &file); err != nil {
 			return nil, err
 		}
 	}
 
 	if *address != "" {
-		config.Address = *address
+		file.Address = *address
 	}
 
-	if *restore != config.Restore {
-		config.Restore = *restore
+	if *restore != file.Restore {
+		file.Restore = *restore
 	}
 
 	if *storeInterval != -1 {
-		config.StoreInterval = *storeInterval
+		file.StoreInterval = time.Duration(*storeInterval) * time.Second
 	}
 
 	if *storeFilePath != "" {
-		config.StoreFilePath = *storeFilePath
+		file.StoreFilePath = *storeFilePath
 	}
 
 	if *dsn != "" {
-		config.DatabaseDSN = *dsn
+		file.DatabaseDSN = *dsn
 	}
 
 	if *key != "" {
-		config.Key = *key
+		file.Key = *key
 	}
 
 	if *privateKeyPath != "" {
-		config.PrivateKeyPath = *privateKeyPath
+		file.PrivateKeyPath = *privateKeyPath
 	}
 
 	if *logLevel != "" {
-		config.LogLevel = *logLevel
+		file.LogLevel = *logLevel
+	}
+
+	if *maxRequestSize != -1 {
+		file.MaxRequestSize = *maxRequestSize
+	}
+
+	if *batchMode != "" {
+		file.BatchMode = *batchMode
+	}
+
+	if *graphiteAddress != "" {
+		file.GraphiteAddress = *graphiteAddress
+	}
+
+	if *statsdAddress != "" {
+		file.StatsDAddress = *statsdAddress
+	}
+
+	if err := readEnv(&file); err != nil {
+		return nil, err
 	}
 
-	storeDuration := time.Duration(getEnvInt("STORE_INTERVAL", config.StoreInterval)) * time.Second
+	rules := make([]graphite.Rule, 0, len(file.GraphiteRules))
+	for _, r := range file.GraphiteRules {
+		rules = append(rules, graphite.Rule{Pattern: r.Pattern, Type: api.MetricsType(r.Type)})
+	}
 
-	return &Config{
+	c := &Config{
 		Server: SelfConfig{
-			Address:        getEnv("ADDRESS", config.Address),
-			LogLevel:       getEnv("LOG_LEVEL", config.LogLevel),
-			Key:            getEnv("KEY", config.Key),
-			PrivateKeyPath: getEnv("CRYPTO_KEY", config.PrivateKeyPath),
+			Address:        config.GetEnv("ADDRESS", file.Address),
+			LogLevel:       config.GetEnv("LOG_LEVEL", file.LogLevel),
+			Key:            config.GetEnv("KEY", file.Key),
+			PrivateKeyPath: config.GetEnv("CRYPTO_KEY", file.PrivateKeyPath),
+			MaxRequestSize: file.MaxRequestSize,
+			BatchMode:      updating.BatchMode(config.GetEnv("BATCH_MODE", file.BatchMode)),
 		},
 		Storage: memory.StorageConfig{
-			StoreInterval: storeDuration,
-			StoreFilePath: getEnv("FILE_STORAGE_PATH", config.StoreFilePath),
-			Restore:       getEnvBool("RESTORE", config.Restore),
+			StoreInterval: file.StoreInterval,
+			StoreFilePath: config.GetEnv("FILE_STORAGE_PATH", file.StoreFilePath),
+			Restore:       file.Restore,
 		},
 		DBStorage: db.StorageConfig{
-			DSN: getEnv("DATABASE_DSN", config.DatabaseDSN),
+			DSN: config.GetEnv("DATABASE_DSN", file.DatabaseDSN),
+		},
+		Graphite: graphite.Config{
+			Address: config.GetEnv("GRAPHITE_ADDRESS", file.GraphiteAddress),
+			Rules:   rules,
+		},
+		StatsD: statsd.Config{
+			Address:       config.GetEnv("STATSD_ADDRESS", file.StatsDAddress),
+			FlushInterval: file.StatsDFlush,
 		},
-	}, nil
-}
-
-func readConfig(configPath string, config *configFile) error {
-	file, err := os.Open(configPath)
-	if err != nil {
-		return err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	dec := json.NewDecoder(file)
+	if err := c.Graphite.Validate(); err != nil {
+		return nil, err
+	}
 
-	if err := dec.Decode(config); err != nil {
-		return err
+	if c.Server.MaxRequestSize < 0 {
+		return nil, fmt.Errorf("max_request_size: must not be negative, got %d", c.Server.MaxRequestSize)
 	}
 
-	return nil
-}
+	if c.Server.BatchMode != updating.AtomicMode && c.Server.BatchMode != updating.PartialMode {
+		return nil, fmt.Errorf("batch_mode: must be %s or %s, got %q", updating.AtomicMode, updating.PartialMode, c.Server.BatchMode)
+	}
 
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+	if c.StatsD.FlushInterval <= 0 {
+		return nil, fmt.Errorf("statsd_flush_interval: must be positive, got %s", c.StatsD.FlushInterval)
 	}
 
-	return defaultVal
+	return c, nil
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		val, err := strconv.ParseInt(value, 10, 32)
-		if err != nil {
-			return defaultValue
-		}
-
-		return int(val)
+// readEnv sets numeric and boolean settings from environment variables over values of file and flags.
+func readEnv(file *configFile) error {
+	size, err := config.GetEnvInt("MAX_REQUEST_SIZE", int(file.MaxRequestSize))
+	if err != nil {
+		return err
 	}
 
-	return defaultValue
-}
+	file.MaxRequestSize = int64(size)
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value, exists := os.LookupEnv(key); exists {
-		val, err := strconv.ParseBool(value)
-		if err != nil {
-			return defaultValue
-		}
+	if file.StoreInterval, err = config.GetEnvDuration("STORE_INTERVAL", file.StoreInterval); err != nil {
+		return err
+	}
+
+	if file.Restore, err = config.GetEnvBool("RESTORE", file.Restore); err != nil {
+		return err
+	}
 
-		return val
+	if file.StatsDFlush, err = config.GetEnvDuration("STATSD_FLUSH_INTERVAL", file.StatsDFlush); err != nil {
+		return err
 	}
 
-	return defaultValue
+	return nil
 }
@@ -0,0 +1,138 @@
+// Package cumulative contains tracker converting cumulative counter values to deltas.
+//
+// Prometheus and OpenTelemetry report counters as total values since start of process,
+// while counters of metric server are increased by deltas.
+package cumulative
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Amount of tracked series is limited, series idle for _seriesTTL are forgotten when limit is reached
+	_maxSeries = 100_000
+	_seriesTTL = time.Hour
+
+	// Idle series are looked for not more often than once per _purgeInterval
+	_purgeInterval = time.Minute
+)
+
+// Tracker remembers the last cumulative value of every series.
+//
+// Increase of series forgotten or not remembered because of limit is counted from value
+// stored at server again, as after restart of server.
+type Tracker struct {
+	mu        sync.Mutex
+	last      map[string]series
+	maxSeries int
+	purged    time.Time
+	now       func() time.Time
+}
+
+// series describes the last committed value of series.
+type series struct {
+	value    int64
+	lastSeen time.Time
+}
+
+// NewTracker creates new tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		last:      make(map[string]series),
+		maxSeries: _maxSeries,
+		now:       time.Now,
+	}
+}
+
+// Pending describes values of series received in one request.
+//
+// Increases are counted from values committed to tracker and values are remembered only by Commit,
+// so increases of request that is failed or rejected by storage are counted again by the next request.
+// Values not committed are discarded together with Pending.
+type Pending struct {
+	t      *Tracker
+	values map[string]int64
+}
+
+// Pending starts counting increases of series received in one request.
+func (t *Tracker) Pending() *Pending {
+	return &Pending{t: t, values: make(map[string]int64)}
+}
+
+// Delta returns increase of series since the previous value without committing new value.
+//
+// Previous value is the last value of series in this request or value committed to tracker.
+// For series not seen before initial is called to get the previous value, for example
+// value stored at server before restart. Decrease of value is treated as reset of counter,
+// so the whole new value is increase.
+func (p *Pending) Delta(name string, value int64, initial func() int64) int64 {
+	last, ok := p.values[name]
+	if !ok {
+		last, ok = p.t.value(name)
+	}
+
+	// Initial value may be read from storage, so it is not called under lock
+	if !ok {
+		last = initial()
+	}
+
+	p.values[name] = value
+
+	if value < last {
+		return value
+	}
+
+	return value - last
+}
+
+// Commit remembers the last value of series in tracker after its increase is applied.
+func (p *Pending) Commit(name string) {
+	value, ok := p.values[name]
+	if !ok {
+		return
+	}
+
+	p.t.commit(name, value)
+}
+
+func (t *Tracker) value(name string) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.last[name]
+
+	return s.value, ok
+}
+
+func (t *Tracker) commit(name string, value int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	if _, ok := t.last[name]; !ok && len(t.last) >= t.maxSeries {
+		t.purge(now)
+
+		if len(t.last) >= t.maxSeries {
+			return
+		}
+	}
+
+	t.last[name] = series{value: value, lastSeen: now}
+}
+
+// purge forgets series idle for _seriesTTL.
+func (t *Tracker) purge(now time.Time) {
+	if now.Sub(t.purged) < _purgeInterval {
+		return
+	}
+
+	t.purged = now
+
+	for name, s := range t.last {
+		if now.Sub(s.lastSeen) > _seriesTTL {
+			delete(t.last, name)
+		}
+	}
+}
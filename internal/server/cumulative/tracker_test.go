@@ -0,0 +1,82 @@
+package cumulative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPending_Delta(t *testing.T) {
+	tr := NewTracker()
+
+	stored := func() int64 { return 10 }
+	unused := func() int64 {
+		t.Fatal("initial value is requested for known series")
+		return 0
+	}
+
+	p := tr.Pending()
+	assert.Equal(t, int64(5), p.Delta("requests", 15, stored))
+	p.Commit("requests")
+
+	p = tr.Pending()
+	assert.Equal(t, int64(0), p.Delta("requests", 15, unused))
+	assert.Equal(t, int64(3), p.Delta("requests", 18, unused))
+
+	// Counter is reset
+	assert.Equal(t, int64(2), p.Delta("requests", 2, unused))
+	assert.Equal(t, int64(1), p.Delta("requests", 3, unused))
+	p.Commit("requests")
+
+	// Series are tracked separately
+	p = tr.Pending()
+	assert.Equal(t, int64(7), p.Delta("errors", 7, func() int64 { return 0 }))
+}
+
+func TestPending_notCommitted(t *testing.T) {
+	tr := NewTracker()
+	zero := func() int64 { return 0 }
+
+	p := tr.Pending()
+	assert.Equal(t, int64(5), p.Delta("requests", 5, zero))
+	p.Commit("requests")
+
+	// Increase of failed request is counted again on retry
+	p = tr.Pending()
+	assert.Equal(t, int64(3), p.Delta("requests", 8, zero))
+	assert.Equal(t, int64(4), p.Delta("errors", 4, zero))
+
+	p = tr.Pending()
+	assert.Equal(t, int64(3), p.Delta("requests", 8, zero))
+	assert.Equal(t, int64(4), p.Delta("errors", 4, zero))
+}
+
+func TestTracker_limit(t *testing.T) {
+	now := time.Now()
+	stored := func() int64 { return 5 }
+
+	tr := NewTracker()
+	tr.maxSeries = 1
+	tr.now = func() time.Time { return now }
+
+	p := tr.Pending()
+	p.Delta("requests", 10, stored)
+	p.Delta("errors", 10, stored)
+	p.Commit("requests")
+	p.Commit("errors")
+
+	// Series above limit is not remembered
+	assert.Equal(t, int64(5), tr.Pending().Delta("requests", 15, stored))
+	assert.Equal(t, int64(10), tr.Pending().Delta("errors", 15, stored))
+
+	// Idle series is forgotten
+	now = now.Add(_seriesTTL + time.Second)
+
+	p = tr.Pending()
+	p.Delta("errors", 120, stored)
+	p.Commit("errors")
+
+	assert.Equal(t, int64(5), tr.Pending().Delta("errors", 125, stored))
+	assert.Equal(t, int64(10), tr.Pending().Delta("requests", 15, stored))
+}
@@ -0,0 +1,341 @@
+// Package graphite contains TCP listener for Graphite plaintext protocol.
+//
+// Every line has format <path> <value> [<timestamp>]. Path may carry tags in format
+// path;tag1=value1;tag2=value2, they become labels of metric. Metrics are written as gauges
+// unless path matches rule that maps it to counter. Timestamps are accepted and ignored.
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+const (
+	_maxLineSize  = 64 * 1024
+	_maxBatchSize = 1000
+)
+
+// Sentinel errors for Graphite listener.
+var (
+	ErrInvalidConfig = errors.New("invalid graphite configuration")
+	ErrInvalidFormat = errors.New("invalid format")
+	ErrInvalidValue  = errors.New("invalid metric value")
+)
+
+// Updater describes service that applies received metrics.
+type Updater interface {
+	// Updates updates some metrics simultaneously.
+	Updates(ctx context.Context, metrics []api.Metrics) error
+}
+
+// Logger describes logger used by listener.
+type Logger interface {
+	Info(args ...interface{})
+	Error(args ...interface{})
+}
+
+// Rule describes mapping of metric paths to metric type.
+type Rule struct {
+	// Pattern - regular expression to match the whole path without tags.
+	Pattern string
+	// Type - type of matched metrics, counter if empty.
+	Type api.MetricsType
+
+	regex *regexp.Regexp
+}
+
+// Config describes configuration of Graphite listener.
+type Config struct {
+	// Address - TCP address to listen, empty value disables listener.
+	Address string
+	// Rules - rules checked in order, the first matched rule sets metric type. Not matched metrics are gauges.
+	Rules []Rule
+}
+
+// Validate checks configuration and compiles rules.
+func (c *Config) Validate() error {
+	for i := range c.Rules {
+		r := &c.Rules[i]
+
+		regex, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("%w: rule %d: %s", ErrInvalidConfig, i, err)
+		}
+
+		r.regex = regex
+
+		if r.Type == "" {
+			r.Type = api.CounterType
+		}
+
+		if r.Type != api.CounterType && r.Type != api.GaugeType {
+			return fmt.Errorf("%w: rule %d: unknown type %q", ErrInvalidConfig, i, r.Type)
+		}
+	}
+
+	return nil
+}
+
+// Listener describes Graphite listener.
+type Listener struct {
+	config  *Config
+	updater Updater
+	log     Logger
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// New creates new Graphite listener that applies received metrics with given updater.
+//
+// Config must be validated before.
+func New(config *Config, updater Updater, log Logger) *Listener {
+	return &Listener{
+		config:  config,
+		updater: updater,
+		log:     log,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Run accepts connections until context is cancelled.
+//
+// On cancellation listener stops accepting connections, closes active ones
+// and returns after metrics received from them are applied.
+func (l *Listener) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", l.config.Address)
+	if err != nil {
+		return err
+	}
+
+	l.log.Info(fmt.Sprintf("Graphite listener started on %s", ln.Addr()))
+
+	return l.serve(ctx, ln)
+}
+
+func (l *Listener) serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = ln.Close()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for conn := range l.conns {
+			_ = conn.Close()
+		}
+	})
+	defer stop()
+
+	wg := new(sync.WaitGroup)
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				l.log.Info("Graphite listener terminated")
+				return nil
+			}
+
+			return err
+		}
+
+		if !l.track(ctx, conn) {
+			_ = conn.Close()
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer l.untrack(conn)
+
+			l.handle(conn)
+		}()
+	}
+}
+
+// track registers connection to close it on cancellation and reports whether listener is still running.
+func (l *Listener) track(ctx context.Context, conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	l.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (l *Listener) untrack(conn net.Conn) {
+	l.mu.Lock()
+	delete(l.conns, conn)
+	l.mu.Unlock()
+
+	_ = conn.Close()
+}
+
+// handle reads lines from connection and applies them in batches.
+//
+// Batch is applied when there are no more buffered lines, so metrics are not delayed
+// waiting for the next lines of slow client.
+func (l *Listener) handle(conn net.Conn) {
+	r := bufio.NewReaderSize(conn, _maxLineSize)
+
+	var batch []api.Metrics
+
+	for {
+		line, err := r.ReadSlice('\n')
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			l.log.Error(fmt.Sprintf("invalid Graphite line from %s: line is too long", conn.RemoteAddr()))
+			err = skipLine(r)
+		case err != nil && !errors.Is(err, io.EOF):
+			// Line may be incomplete if connection is broken or closed on shutdown
+		case len(line) > 0:
+			m, parseErr := l.parse(string(line))
+			if parseErr != nil {
+				l.log.Error(fmt.Sprintf("invalid Graphite line from %s: %s", conn.RemoteAddr(), parseErr))
+				break
+			}
+
+			if m != nil {
+				batch = append(batch, *m)
+			}
+		}
+
+		if err != nil || r.Buffered() == 0 || len(batch) >= _maxBatchSize {
+			l.apply(batch)
+			batch = nil
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (l *Listener) apply(batch []api.Metrics) {
+	if len(batch) == 0 {
+		return
+	}
+
+	// Received metrics are applied even if listener is stopping
+	if err := l.updater.Updates(context.Background(), batch); err != nil {
+		l.log.Error(fmt.Sprintf("failed to apply Graphite metrics: %s", err))
+	}
+}
+
+// parse converts line to metric, returns nil for empty line.
+func (l *Listener) parse(line string) (*api.Metrics, error) {
+	s, err := Parse(line)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	name := api.LabeledName(s.Path, s.Tags)
+
+	if l.metricType(s.Path) == api.GaugeType {
+		return &api.Metrics{ID: name, MType: api.GaugeType, Value: &s.Value}, nil
+	}
+
+	if s.Value != math.Trunc(s.Value) || math.Abs(s.Value) >= math.MaxInt64 {
+		return nil, fmt.Errorf("%w: counter %s is not an integer", ErrInvalidValue, s.Path)
+	}
+
+	delta := int64(s.Value)
+
+	return &api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}, nil
+}
+
+func (l *Listener) metricType(path string) api.MetricsType {
+	for _, r := range l.config.Rules {
+		if r.regex.MatchString(path) {
+			return r.Type
+		}
+	}
+
+	return api.GaugeType
+}
+
+func skipLine(r *bufio.Reader) error {
+	for {
+		_, err := r.ReadSlice('\n')
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+}
+
+// Sample describes one parsed line.
+type Sample struct {
+	// Path - metric path without tags.
+	Path string
+	// Tags - tags of metric, nil if absent.
+	Tags map[string]string
+	// Value - value of metric.
+	Value float64
+}
+
+// Parse parses one line of plaintext protocol, returns nil for empty line.
+func Parse(line string) (*Sample, error) {
+	fields := strings.Fields(line)
+
+	switch len(fields) {
+	case 0:
+		return nil, nil
+	case 2, 3:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, strings.TrimSpace(line))
+	}
+
+	parts := strings.Split(fields[0], ";")
+
+	s := &Sample{Path: parts[0]}
+	if s.Path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidFormat)
+	}
+
+	for _, tag := range parts[1:] {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%w: invalid tag %q", ErrInvalidFormat, tag)
+		}
+
+		if s.Tags == nil {
+			s.Tags = make(map[string]string, len(parts)-1)
+		}
+
+		s.Tags[k] = v
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidValue, fields[1])
+	}
+
+	s.Value = value
+
+	if len(fields) == 3 {
+		if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidFormat, fields[2])
+		}
+	}
+
+	return s, nil
+}
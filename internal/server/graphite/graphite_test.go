@@ -0,0 +1,179 @@
+package graphite
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+type updater struct {
+	mu      sync.Mutex
+	batches [][]api.Metrics
+}
+
+func (u *updater) Updates(_ context.Context, metrics []api.Metrics) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.batches = append(u.batches, metrics)
+
+	return nil
+}
+
+func (u *updater) metrics() []api.Metrics {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var metrics []api.Metrics
+	for _, b := range u.batches {
+		metrics = append(metrics, b...)
+	}
+
+	return metrics
+}
+
+type logger struct{}
+
+func (logger) Info(_ ...interface{})  {}
+func (logger) Error(_ ...interface{}) {}
+
+func gauge(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
+
+func counter(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *Sample
+		wantErr error
+	}{
+		{
+			name: "with timestamp",
+			line: "servers.web1.cpu 12.5 1700000000\n",
+			want: &Sample{Path: "servers.web1.cpu", Value: 12.5},
+		},
+		{
+			name: "without timestamp",
+			line: "jobs.backup.duration -3",
+			want: &Sample{Path: "jobs.backup.duration", Value: -3},
+		},
+		{
+			name: "tags",
+			line: "disk.used;host=web1;mount=/ 42 1700000000",
+			want: &Sample{Path: "disk.used", Tags: map[string]string{"host": "web1", "mount": "/"}, Value: 42},
+		},
+		{name: "empty line", line: "  \r\n"},
+		{name: "missing value", line: "cpu", wantErr: ErrInvalidFormat},
+		{name: "too many fields", line: "cpu 1 2 3", wantErr: ErrInvalidFormat},
+		{name: "invalid tag", line: "cpu;host 1", wantErr: ErrInvalidFormat},
+		{name: "empty path", line: ";host=a 1", wantErr: ErrInvalidFormat},
+		{name: "invalid value", line: "cpu abc", wantErr: ErrInvalidValue},
+		{name: "NaN", line: "cpu NaN", wantErr: ErrInvalidValue},
+		{name: "invalid timestamp", line: "cpu 1 now", wantErr: ErrInvalidFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse(test.line)
+
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, s)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := Config{Rules: []Rule{{Pattern: `jobs\..*\.runs`}, {Pattern: "jobs", Type: api.GaugeType}}}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, api.CounterType, c.Rules[0].Type)
+
+	c = Config{Rules: []Rule{{Pattern: "(", Type: api.CounterType}}}
+	assert.ErrorIs(t, c.Validate(), ErrInvalidConfig)
+
+	c = Config{Rules: []Rule{{Pattern: "jobs", Type: "histogram"}}}
+	assert.ErrorIs(t, c.Validate(), ErrInvalidConfig)
+}
+
+func TestListener_serve(t *testing.T) {
+	config := &Config{
+		Address: "127.0.0.1:0",
+		Rules: []Rule{
+			{Pattern: `jobs\.backup\.duration`, Type: api.GaugeType},
+			{Pattern: `jobs\..*`},
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	u := &updater{}
+	l := New(config, u, logger{})
+
+	ln, err := net.Listen("tcp", config.Address)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- l.serve(ctx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+
+	lines := []string{
+		"servers.web1.cpu 12.5 1700000000",
+		"jobs.backup.runs;host=db1 3 1700000000",
+		"jobs.backup.duration 17.5 1700000000",
+		"jobs.cleanup.runs 1.5 1700000000",
+		"invalid line",
+		strings.Repeat("x", _maxLineSize+1) + " 1",
+		"jobs.cleanup.runs 2",
+	}
+
+	_, err = conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	require.NoError(t, err)
+
+	want := []api.Metrics{
+		gauge("servers.web1.cpu", 12.5),
+		counter("jobs.backup.runs;host=db1", 3),
+		gauge("jobs.backup.duration", 17.5),
+		counter("jobs.cleanup.runs", 2),
+	}
+
+	// Metrics of active connection are applied without waiting for its closing
+	assert.Eventually(t, func() bool {
+		return len(u.metrics()) == len(want)
+	}, time.Second, 10*time.Millisecond)
+
+	// Active connection is closed on shutdown
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("listener is not stopped")
+	}
+
+	assert.Equal(t, want, u.metrics())
+
+	_ = conn.Close()
+}
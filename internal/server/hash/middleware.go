@@ -49,3 +49,39 @@ func Middleware(key string, h http.HandlerFunc) http.HandlerFunc {
 		h.ServeHTTP(w, r)
 	}
 }
+
+// RequestMiddleware wraps handler of request without body, for example delete, to check hash of its method and path.
+//
+// If key is set, Hash header is required and must be HMAC-SHA256 of RequestHash, so hash of request
+// can not be used for request to another resource.
+func RequestMiddleware(key string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		decodedHash, err := base64.StdEncoding.DecodeString(r.Header.Get("Hash"))
+		if err != nil || len(decodedHash) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		calculatedHash, _ := base64.StdEncoding.DecodeString(RequestHash(key, r.Method, r.URL.Path))
+
+		if !hmac.Equal(decodedHash, calculatedHash) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+// RequestHash returns base64 encoded HMAC-SHA256 of "<method> <path>" with key for Hash header.
+func RequestHash(key string, method string, path string) string {
+	hm := hmac.New(sha256.New, []byte(key))
+	hm.Write([]byte(method + " " + path))
+
+	return base64.StdEncoding.EncodeToString(hm.Sum(nil))
+}
@@ -18,3 +18,6 @@ package rest
 
 // @Tag.name Update
 // @Tag.description "Request group for updating metrics
+
+// @Tag.name Metrics
+// @Tag.description "Request group for versioned metrics API"
@@ -0,0 +1,336 @@
+// Package grafana describes handlers for Grafana JSON datasource at server
+//
+// Server does not keep history of metrics, so time series contain one data point
+// with current value of metric at the end of requested range.
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+// Types of query targets
+const (
+	TimeSeriesTarget = "timeserie"
+	TableTarget      = "table"
+)
+
+type Logger interface {
+	RequestLogger(h http.HandlerFunc) http.HandlerFunc
+	Error(args ...interface{})
+}
+
+// Handler describes common state for all handlers in package
+type Handler struct {
+	a viewing.Service
+	l Logger
+}
+
+// NewHandler creates new instance of Handler
+func NewHandler(a viewing.Service, l Logger) *Handler {
+	return &Handler{a, l}
+}
+
+// Route creates router for all routes controlled by the package
+func (h *Handler) Route() *chi.Mux {
+	mux := chi.NewRouter()
+
+	mux.Get("/", h.l.RequestLogger(h.test))
+	mux.Post("/search", h.l.RequestLogger(gzip.Middleware(h.search)))
+	mux.Post("/query", h.l.RequestLogger(gzip.Middleware(h.query)))
+	mux.Post("/annotations", h.l.RequestLogger(gzip.Middleware(h.annotations)))
+
+	return mux
+}
+
+// SearchRequest describes body of search request.
+type SearchRequest struct {
+	// Target - part of metric name to search, empty value matches all metrics.
+	Target string `json:"target"`
+}
+
+// Range describes time range of query.
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Target describes one target of query.
+type Target struct {
+	// Target - metric name for time series, part of metric name for table.
+	Target string `json:"target"`
+	// RefID - identifier of target in panel.
+	RefID string `json:"refId"`
+	// Type - type of response, timeserie or table.
+	Type string `json:"type"`
+}
+
+// QueryRequest describes body of query request.
+type QueryRequest struct {
+	Range   Range    `json:"range"`
+	Targets []Target `json:"targets"`
+}
+
+// TimeSeries describes time series in query response.
+type TimeSeries struct {
+	Target string `json:"target"`
+	// DataPoints - pairs of value and timestamp in milliseconds.
+	DataPoints [][2]float64 `json:"datapoints"`
+}
+
+// Column describes column of table in query response.
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// Table describes table in query response.
+type Table struct {
+	Type    string   `json:"type"`
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// AnnotationRequest describes body of annotations request.
+type AnnotationRequest struct {
+	Range      Range `json:"range"`
+	Annotation struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+// Annotation describes annotation in annotations response.
+type Annotation struct {
+	Time  int64    `json:"time"`
+	Title string   `json:"title"`
+	Text  string   `json:"text"`
+	Tags  []string `json:"tags"`
+}
+
+// metric describes current value of metric.
+type metric struct {
+	name     string
+	mType    api.MetricsType
+	value    float64
+	metadata api.Metadata
+}
+
+// @Tags	Grafana
+// @Summary Request to test Grafana datasource connection
+// @Success	200
+// @Router	    /grafana/	[get]
+func (h *Handler) test(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+//			@Tags	Grafana
+//			@Summary Request to search metric names for Grafana datasource
+//		    @Accept     json
+//			@Produce    json
+//			@Param	    request    body       SearchRequest  true "Search request"
+//			@Success	200        {array}    string
+//	        @Failure    400        {object}   problem.Problem "invalid_request"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /grafana/search	[post]
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	var req SearchRequest
+
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	metrics, ok := h.metrics(w, r)
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		if matches(m.name, req.Target) && (len(names) == 0 || names[len(names)-1] != m.name) {
+			names = append(names, m.name)
+		}
+	}
+
+	h.encode(w, names)
+}
+
+//			@Tags	Grafana
+//			@Summary Request to query metrics for Grafana datasource
+//			@Description Time series target is metric name, time series contains current value at the end of range.
+//			@Description Table target is part of metric name, table contains name, type, value, unit and description of matched metrics.
+//		    @Accept     json
+//			@Produce    json
+//			@Param	    request    body       QueryRequest  true "Query request"
+//			@Success	200        {array}    TimeSeries
+//	        @Failure    400        {object}   problem.Problem "invalid_request"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /grafana/query	[post]
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	var req QueryRequest
+
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	metrics, ok := h.metrics(w, r)
+	if !ok {
+		return
+	}
+
+	to := req.Range.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	res := make([]any, 0, len(req.Targets))
+
+	for _, t := range req.Targets {
+		if t.Type == TableTarget {
+			res = append(res, table(metrics, t.Target))
+			continue
+		}
+
+		for _, m := range metrics {
+			if m.name == t.Target {
+				res = append(res, TimeSeries{
+					Target:     m.name,
+					DataPoints: [][2]float64{{m.value, float64(to.UnixMilli())}},
+				})
+			}
+		}
+	}
+
+	h.encode(w, res)
+}
+
+//			@Tags	Grafana
+//			@Summary Request to get annotations for Grafana datasource
+//			@Description Server does not keep events, so list of annotations is always empty.
+//		    @Accept     json
+//			@Produce    json
+//			@Param	    request    body       AnnotationRequest  true "Annotations request"
+//			@Success	200        {array}    Annotation
+//	        @Failure    400        {object}   problem.Problem "invalid_request"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//			@Router	    /grafana/annotations	[post]
+func (h *Handler) annotations(w http.ResponseWriter, r *http.Request) {
+	var req AnnotationRequest
+
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	h.encode(w, []Annotation{})
+}
+
+func table(metrics []metric, target string) Table {
+	t := Table{
+		Type: TableTarget,
+		Columns: []Column{
+			{Text: "Name", Type: "string"},
+			{Text: "Type", Type: "string"},
+			{Text: "Value", Type: "number"},
+			{Text: "Unit", Type: "string"},
+			{Text: "Description", Type: "string"},
+		},
+		Rows: [][]any{},
+	}
+
+	for _, m := range metrics {
+		if matches(m.name, target) {
+			t.Rows = append(t.Rows, []any{m.name, m.mType, m.value, m.metadata.Unit, m.metadata.Description})
+		}
+	}
+
+	return t
+}
+
+func matches(name string, target string) bool {
+	return target == "" || target == "*" || strings.Contains(name, target)
+}
+
+// metrics returns all metrics sorted by name and type, writes error response on failure.
+func (h *Handler) metrics(w http.ResponseWriter, r *http.Request) ([]metric, bool) {
+	ctx := r.Context()
+
+	gauges, err := h.a.Gauges(ctx)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get gauges: %v", err))
+		problem.Error(w, r, err)
+		return nil, false
+	}
+
+	counters, err := h.a.Counters(ctx)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get counters: %v", err))
+		problem.Error(w, r, err)
+		return nil, false
+	}
+
+	metrics := make([]metric, 0, len(gauges)+len(counters))
+
+	for _, g := range gauges {
+		metrics = append(metrics, metric{name: g.Name, mType: api.GaugeType, value: g.Value, metadata: g.Metadata})
+	}
+
+	for _, c := range counters {
+		metrics = append(metrics, metric{name: c.Name, mType: api.CounterType, value: float64(c.Value), metadata: c.Metadata})
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].name != metrics[j].name {
+			return metrics[i].name < metrics[j].name
+		}
+
+		return metrics[i].mType < metrics[j].mType
+	})
+
+	return metrics, true
+}
+
+// decode decodes request body, writes error response on failure.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, req any) bool {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for %s: %v", r.URL.Path, err))
+
+		if limit.TooLarge(err) {
+			problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge, err.Error()).Write(w, r)
+			return false
+		}
+
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) encode(w http.ResponseWriter, res any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error(fmt.Sprintf("failed encoding response: %v", err))
+	}
+}
@@ -0,0 +1,162 @@
+package grafana
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+func newServer(t *testing.T, s viewing.Service) *httptest.Server {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return().Maybe()
+
+	r := chi.NewRouter()
+	r.Mount("/grafana", NewHandler(s, l).Route())
+
+	return httptest.NewServer(r)
+}
+
+// TestHandler replays request bodies recorded from Grafana JSON datasource.
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		serviceErr error
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:     "search",
+			path:     "/search",
+			body:     "search.json",
+			wantCode: http.StatusOK,
+			wantBody: `["Alloc","TotalAlloc"]`,
+		},
+		{
+			name:     "query time series",
+			path:     "/query",
+			body:     "query_timeserie.json",
+			wantCode: http.StatusOK,
+			wantBody: `[{"target":"Alloc","datapoints":[[1024.5,1715529089063]]},` +
+				`{"target":"PollCount","datapoints":[[7,1715529089063]]}]`,
+		},
+		{
+			name:     "query table",
+			path:     "/query",
+			body:     "query_table.json",
+			wantCode: http.StatusOK,
+			wantBody: `[{"type":"table",` +
+				`"columns":[{"text":"Name","type":"string"},{"text":"Type","type":"string"},{"text":"Value","type":"number"},` +
+				`{"text":"Unit","type":"string"},{"text":"Description","type":"string"}],` +
+				`"rows":[["Alloc","gauge",1024.5,"",""],["TotalAlloc","gauge",4096,"bytes",""]]}]`,
+		},
+		{
+			name:     "annotations",
+			path:     "/annotations",
+			body:     "annotations.json",
+			wantCode: http.StatusOK,
+			wantBody: `[]`,
+		},
+		{
+			name:       "service error",
+			path:       "/search",
+			body:       "search.json",
+			serviceErr: errors.New("storage is unavailable"),
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := viewing.NewMockService(t)
+
+			if test.path != "/annotations" {
+				s.On("Gauges", mock.Anything).Return([]viewing.Gauge{
+					{Name: "TotalAlloc", Value: 4096, Metadata: api.Metadata{Unit: "bytes"}},
+					{Name: "Alloc", Value: 1024.5},
+				}, test.serviceErr)
+
+				if test.serviceErr == nil {
+					s.On("Counters", mock.Anything).Return([]viewing.Counter{{Name: "PollCount", Value: 7}}, nil)
+				}
+			}
+
+			body, err := os.ReadFile(filepath.Join("testdata", test.body))
+			require.NoError(t, err)
+
+			srv := newServer(t, s)
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+"/grafana"+test.path, "application/json", strings.NewReader(string(body)))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+
+			if test.wantBody != "" {
+				got, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, test.wantBody, string(got))
+			}
+		})
+	}
+}
+
+func TestHandler_invalidBody(t *testing.T) {
+	srv := newServer(t, viewing.NewMockService(t))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/grafana/query", "application/json", strings.NewReader(`{"targets": {}}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandler_tooLarge(t *testing.T) {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return()
+
+	r := chi.NewRouter()
+	r.Mount("/grafana", limit.Middleware(16, NewHandler(viewing.NewMockService(t), l).Route().ServeHTTP))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// Body without length is cut off while decoding
+	body := io.MultiReader(strings.NewReader(`{"target": "` + strings.Repeat("a", 32) + `"}`))
+
+	resp, err := http.Post(srv.URL+"/grafana/search", "application/json", body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestHandler_test(t *testing.T) {
+	srv := newServer(t, viewing.NewMockService(t))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/grafana/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,199 @@
+// Package influx describes handler for writing metrics in InfluxDB line protocol at server
+package influx
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/influx"
+	"github.com/kaa-it/go-devops/internal/server/decrypt"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+// Fields with these suffixes are written as counters even if their values are floats.
+var _counterSuffixes = []string{"_total", "_count"}
+
+// ErrInvalidCounter is returned for counter field with value that is not an integer.
+var ErrInvalidCounter = errors.New("counter value is not an integer")
+
+type Logger interface {
+	RequestLogger(h http.HandlerFunc) http.HandlerFunc
+	Error(args ...interface{})
+}
+
+// Handler describes common state for all handlers in package
+type Handler struct {
+	a updating.Service
+	l Logger
+}
+
+// NewHandler creates new instance of Handler
+func NewHandler(a updating.Service, l Logger) *Handler {
+	return &Handler{a, l}
+}
+
+// Write returns handler for /write route.
+func (h *Handler) Write(key string, privateKey *rsa.PrivateKey) http.HandlerFunc {
+	return h.l.RequestLogger(
+		hash.Middleware(
+			key,
+			decrypt.Middleware(
+				privateKey,
+				gzip.Middleware(h.write),
+			),
+		),
+	)
+}
+
+//			@Tags	 Update
+//			@Summary Request to update metrics in InfluxDB line protocol
+//			@Description Integer fields and fields with _total or _count suffix are counters, other numeric fields are gauges.
+//			@Description Metric name is measurement and field key joined with dot, tags become labels.
+//		    @Accept     plain
+//			@Param	    request    body       string  true "Lines of line protocol"
+//			@Success	204
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value with rejected metrics"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /write	[post]
+func (h *Handler) write(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to read body for write: %v", err))
+
+		if limit.TooLarge(err) {
+			problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge, err.Error()).Write(w, r)
+			return
+		}
+
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	points, errs := influx.ParseLines(body)
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		h.l.Error(fmt.Sprintf("failed parsing body for write: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	metrics, err := Metrics(points)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed converting points for write: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	if len(metrics) > 0 {
+		if err := h.a.Updates(r.Context(), metrics); err != nil {
+			h.l.Error(fmt.Sprintf("batch update failed: %v", err.Error()))
+
+			// Partially applied batch is reported as failed with rejected metrics as InfluxDB does
+			problem.Error(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Metrics converts points to metrics.
+//
+// Metric name is measurement and field key joined with dot, field "value" uses measurement only.
+// Tags become labels of metric. Integer fields and fields with counter suffixes are counters,
+// float and boolean fields are gauges, string fields are skipped.
+func Metrics(points []influx.Point) ([]api.Metrics, error) {
+	var metrics []api.Metrics
+
+	for _, p := range points {
+		labels := make(map[string]string, len(p.Tags))
+		for _, t := range p.Tags {
+			labels[t.Key] = t.Value
+		}
+
+		for _, f := range p.Fields {
+			name := p.Measurement
+			if f.Key != "value" {
+				name += "." + f.Key
+			}
+
+			m, ok, err := metric(api.LabeledName(name, labels), f)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %w", f.Key, p.Measurement, err)
+			}
+
+			if ok {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// metric converts field to metric and reports whether field has numeric value.
+func metric(name string, f influx.Field) (api.Metrics, bool, error) {
+	switch v := f.Value.(type) {
+	case int64:
+		return counter(name, v), true, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return api.Metrics{}, false, ErrInvalidCounter
+		}
+
+		return counter(name, int64(v)), true, nil
+	case float64:
+		if !isCounter(f.Key) {
+			return gauge(name, v), true, nil
+		}
+
+		if v != math.Trunc(v) || math.Abs(v) >= math.MaxInt64 {
+			return api.Metrics{}, false, ErrInvalidCounter
+		}
+
+		return counter(name, int64(v)), true, nil
+	case bool:
+		if v {
+			return gauge(name, 1), true, nil
+		}
+
+		return gauge(name, 0), true, nil
+	default:
+		return api.Metrics{}, false, nil
+	}
+}
+
+func isCounter(key string) bool {
+	for _, suffix := range _counterSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func counter(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func gauge(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
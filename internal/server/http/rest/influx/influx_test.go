@@ -0,0 +1,188 @@
+package influx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/influx"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+func TestWriteHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       []api.Metrics
+		serviceErr error
+		wantCode   int
+		// wantRejected - amount of rejected metrics in response
+		wantRejected int
+	}{
+		{
+			name: "success case",
+			body: "cpu,host=web1 usage_idle=98.5,requests=3i\nhttp,code=200 requests_total=7 1700000000000000000\n",
+			want: []api.Metrics{
+				gauge("cpu.usage_idle;host=web1", 98.5),
+				counter("cpu.requests;host=web1", 3),
+				counter("http.requests_total;code=200", 7),
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "only string fields",
+			body:     `events message="started"`,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "invalid line",
+			body:     "cpu value=1\ncpu,host value=1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "fractional counter",
+			body:     "http requests_total=1.5",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:       "service error",
+			body:       "cpu value=1",
+			want:       []api.Metrics{gauge("cpu", 1)},
+			serviceErr: errors.New("storage is unavailable"),
+			wantCode:   http.StatusInternalServerError,
+		},
+		{
+			name: "partially applied batch",
+			body: "cpu value=1\nmem value=2",
+			want: []api.Metrics{gauge("cpu", 1), gauge("mem", 2)},
+			serviceErr: &updating.BatchError{
+				Items:   []updating.ItemError{{Index: 1, ID: "mem", Err: updating.ErrTypeConflict}},
+				Applied: 1,
+			},
+			wantCode:     http.StatusBadRequest,
+			wantRejected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := updating.NewMockService(t)
+
+			if test.want != nil {
+				s.On("Updates", mock.Anything, test.want).Return(test.serviceErr)
+			}
+
+			var h *Handler
+
+			l := NewMockLogger(t)
+			l.On("RequestLogger", mock.Anything).Return(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.write(w, r)
+			}))
+
+			if test.wantCode != http.StatusNoContent {
+				l.On("Error", mock.Anything).Return()
+			}
+
+			h = NewHandler(s, l)
+
+			r := chi.NewRouter()
+			r.Mount("/write", h.Write("", nil))
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+"/write?db=telegraf", "text/plain", strings.NewReader(test.body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+
+			if test.wantCode != http.StatusNoContent {
+				var p problem.Problem
+
+				assert.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+				assert.Equal(t, test.wantCode, p.Status)
+				assert.NotContains(t, p.Detail, "storage is unavailable")
+				assert.Len(t, p.Errors, test.wantRejected)
+			}
+		})
+	}
+}
+
+func TestWriteHandler_tooLarge(t *testing.T) {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return()
+
+	r := chi.NewRouter()
+	r.Mount("/write", limit.Middleware(16, NewHandler(updating.NewMockService(t), l).Write("", nil)))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// Body without length is cut off while reading
+	body := io.MultiReader(strings.NewReader(strings.Repeat("cpu value=1\n", 4)))
+
+	resp, err := http.Post(srv.URL+"/write", "text/plain", body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestWriteHandler_encrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+
+	s := updating.NewMockService(t)
+	s.On("Updates", mock.Anything, []api.Metrics{gauge("cpu", 1)}).Return(nil)
+
+	r := chi.NewRouter()
+	r.Mount("/write", NewHandler(s, l).Write("", privateKey))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	body, err := rsa.EncryptPKCS1v15(rand.Reader, &privateKey.PublicKey, []byte("cpu value=1\n"))
+	require.NoError(t, err)
+
+	resp, err := http.Post(srv.URL+"/write", "text/plain", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestMetrics(t *testing.T) {
+	points, errs := influx.ParseLines([]byte("mem,host=a value=1.5,up=true,free=10u,name=\"a\",items_count=2\n"))
+	require.Empty(t, errs)
+
+	metrics, err := Metrics(points)
+	require.NoError(t, err)
+
+	assert.Equal(t, []api.Metrics{
+		gauge("mem;host=a", 1.5),
+		gauge("mem.up;host=a", 1),
+		counter("mem.free;host=a", 10),
+		counter("mem.items_count;host=a", 2),
+	}, metrics)
+}
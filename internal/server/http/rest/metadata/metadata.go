@@ -0,0 +1,161 @@
+// Package metadata describes handlers for managing metric metadata at server
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/metadata"
+)
+
+type Logger interface {
+	RequestLogger(h http.HandlerFunc) http.HandlerFunc
+	Error(args ...interface{})
+}
+
+// Handler describes common state for all handlers in package
+type Handler struct {
+	a metadata.Service
+	l Logger
+}
+
+// NewHandler creates new instance of Handler
+func NewHandler(a metadata.Service, l Logger) *Handler {
+	return &Handler{a, l}
+}
+
+// Route creates router for all routes controlled by the package.
+//
+// Metric name is the rest of path, so names with labels may contain slashes.
+func (h *Handler) Route(key string) *chi.Mux {
+	mux := chi.NewRouter()
+
+	mux.Get("/", h.l.RequestLogger(gzip.Middleware(h.list)))
+	mux.Get("/*", h.l.RequestLogger(gzip.Middleware(h.get)))
+	mux.Put("/*", h.l.RequestLogger(hash.Middleware(key, gzip.Middleware(h.set))))
+	mux.Delete("/*", h.l.RequestLogger(hash.RequestMiddleware(key, h.delete)))
+
+	return mux
+}
+
+// @Tags	Metadata
+// @Summary Request to get metadata of all metrics
+// @Produce    json
+// @Success	200        {array}    metadata.Entry
+// @Failure    500        {object}   problem.Problem "storage_unavailable"
+// @Router	    /metadata/	[get]
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.a.Entries(r.Context())
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get metadata: %v", err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	if entries == nil {
+		entries = []metadata.Entry{}
+	}
+
+	h.encode(w, entries)
+}
+
+//			@Tags	Metadata
+//			@Summary Request to get metadata of metric
+//			@Produce    json
+//	        @Param      name       path       string  true "Metric name"
+//			@Success	200        {object}   api.Metadata
+//			@Failure    404        {object}   problem.Problem "metadata_not_found"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /metadata/{name}	[get]
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "*")
+
+	m, err := h.a.Metadata(r.Context(), name)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get metadata of %s: %v", name, err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	h.encode(w, m)
+}
+
+//			@Tags	Metadata
+//			@Summary Request to set metadata of metric
+//			@Description Metadata replaces previous one, type is expected metric type: gauge, counter or empty.
+//		    @Accept     json
+//	        @Param      name       path       string  true "Metric name"
+//			@Param	    request    body       api.Metadata  true "Metric metadata"
+//			@Success	200
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /metadata/{name}	[put]
+func (h *Handler) set(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "*")
+
+	var m api.Metadata
+
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if name == "" {
+		h.l.Error("failed to set metadata: metric name is empty")
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "metric name is empty").Write(w, r)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for metadata: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	if err := h.a.SetMetadata(r.Context(), name, m); err != nil {
+		h.l.Error(fmt.Sprintf("failed to set metadata of %s: %v", name, err))
+
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+//			@Tags	Metadata
+//			@Summary Request to delete metadata of metric
+//	        @Param      name       path       string  true "Metric name"
+//	        @Param      Hash       header     string  false "HMAC-SHA256 of method and path, required if server key is set"
+//			@Success	200
+//			@Failure    400
+//			@Failure    404        {object}   problem.Problem "metadata_not_found"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /metadata/{name}	[delete]
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "*")
+
+	if err := h.a.DeleteMetadata(r.Context(), name); err != nil {
+		h.l.Error(fmt.Sprintf("failed to delete metadata of %s: %v", name, err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) encode(w http.ResponseWriter, res any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error(fmt.Sprintf("failed encoding response: %v", err))
+	}
+}
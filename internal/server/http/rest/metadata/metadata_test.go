@@ -0,0 +1,184 @@
+package metadata
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/metadata"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+)
+
+func newServer(t *testing.T, s metadata.Service) *httptest.Server {
+	return newKeyServer(t, s, "")
+}
+
+func newKeyServer(t *testing.T, s metadata.Service, key string) *httptest.Server {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return().Maybe()
+
+	r := chi.NewRouter()
+	r.Mount("/metadata", NewHandler(s, l).Route(key))
+
+	return httptest.NewServer(r)
+}
+
+func TestHandler(t *testing.T) {
+	allocMetadata := api.Metadata{Unit: "bytes", Description: "Allocated heap objects", Owner: "platform"}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		setup    func(s *metadata.MockService)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:   "list",
+			method: http.MethodGet,
+			path:   "/metadata/",
+			setup: func(s *metadata.MockService) {
+				s.On("Entries", mock.Anything).Return([]metadata.Entry{{Name: "Alloc", Metadata: allocMetadata}}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `[{"name": "Alloc", "unit": "bytes", "description": "Allocated heap objects", "owner": "platform"}]`,
+		},
+		{
+			name:   "empty list",
+			method: http.MethodGet,
+			path:   "/metadata/",
+			setup: func(s *metadata.MockService) {
+				s.On("Entries", mock.Anything).Return(nil, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `[]`,
+		},
+		{
+			name:   "get labeled metric",
+			method: http.MethodGet,
+			path:   "/metadata/http.duration;path=/api",
+			setup: func(s *metadata.MockService) {
+				s.On("Metadata", mock.Anything, "http.duration;path=/api").Return(api.Metadata{Unit: "seconds"}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"unit": "seconds"}`,
+		},
+		{
+			name:   "get not found",
+			method: http.MethodGet,
+			path:   "/metadata/Unknown",
+			setup: func(s *metadata.MockService) {
+				s.On("Metadata", mock.Anything, "Unknown").Return(api.Metadata{}, memory.ErrMetadataNotFound)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:   "set",
+			method: http.MethodPut,
+			path:   "/metadata/Alloc",
+			body:   `{"unit": "bytes", "description": "Allocated heap objects", "owner": "platform"}`,
+			setup: func(s *metadata.MockService) {
+				s.On("SetMetadata", mock.Anything, "Alloc", allocMetadata).Return(nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "set unsupported type",
+			method: http.MethodPut,
+			path:   "/metadata/Alloc",
+			body:   `{"type": "histogram"}`,
+			setup: func(s *metadata.MockService) {
+				s.On("SetMetadata", mock.Anything, "Alloc", api.Metadata{Type: "histogram"}).
+					Return(fmt.Errorf("%w: unsupported type", api.ErrInvalidMetadata))
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "set empty name",
+			method:   http.MethodPut,
+			path:     "/metadata/",
+			body:     `{"unit": "bytes"}`,
+			setup:    func(s *metadata.MockService) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "set invalid body",
+			method:   http.MethodPut,
+			path:     "/metadata/Alloc",
+			body:     `{"unit": 1}`,
+			setup:    func(s *metadata.MockService) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   "/metadata/Alloc",
+			setup: func(s *metadata.MockService) {
+				s.On("DeleteMetadata", mock.Anything, "Alloc").Return(nil)
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := metadata.NewMockService(t)
+			test.setup(s)
+
+			srv := newServer(t, s)
+			defer srv.Close()
+
+			req, err := http.NewRequest(test.method, srv.URL+test.path, strings.NewReader(test.body))
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+
+			if test.wantBody != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, test.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestHandler_deleteHash(t *testing.T) {
+	s := metadata.NewMockService(t)
+	s.On("DeleteMetadata", mock.Anything, "Alloc").Return(nil).Once()
+
+	srv := newKeyServer(t, s, "secret")
+	defer srv.Close()
+
+	del := func(h string) int {
+		req, err := http.NewRequest(http.MethodDelete, srv.URL+"/metadata/Alloc", nil)
+		require.NoError(t, err)
+		req.Header.Set("Hash", h)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusBadRequest, del(""))
+	assert.Equal(t, http.StatusBadRequest, del(hash.RequestHash("secret", http.MethodDelete, "/metadata/Other")))
+	assert.Equal(t, http.StatusOK, del(hash.RequestHash("secret", http.MethodDelete, "/metadata/Alloc")))
+}
@@ -0,0 +1,378 @@
+// Package otlp describes handler for OpenTelemetry OTLP/HTTP metrics receiver at server
+package otlp
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/otlp"
+	"github.com/kaa-it/go-devops/internal/server/cumulative"
+	"github.com/kaa-it/go-devops/internal/server/decrypt"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+// Content types of OTLP/HTTP encodings
+const (
+	_protobufContentType = "application/x-protobuf"
+	_jsonContentType     = "application/json"
+)
+
+// ErrUnsupportedContentType is returned for request that is neither protobuf nor JSON.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+type Logger interface {
+	RequestLogger(h http.HandlerFunc) http.HandlerFunc
+	Error(args ...interface{})
+}
+
+// Handler describes common state for all handlers in package
+type Handler struct {
+	a updating.Service
+	l Logger
+
+	counters *cumulative.Tracker
+}
+
+// NewHandler creates new instance of Handler
+func NewHandler(a updating.Service, l Logger) *Handler {
+	return &Handler{
+		a:        a,
+		l:        l,
+		counters: cumulative.NewTracker(),
+	}
+}
+
+// Export returns handler for /v1/metrics route.
+func (h *Handler) Export(key string, privateKey *rsa.PrivateKey) http.HandlerFunc {
+	return h.l.RequestLogger(
+		hash.Middleware(
+			key,
+			decrypt.Middleware(
+				privateKey,
+				gzip.Middleware(h.export),
+			),
+		),
+	)
+}
+
+//			@Tags	 Update
+//			@Summary Request to export metrics with OpenTelemetry OTLP/HTTP protocol
+//			@Description Body is ExportMetricsServiceRequest in protobuf or JSON encoding. Monotonic sums are counters,
+//			@Description cumulative values are converted to increase since previous request. Other sums and gauges are gauges.
+//			@Description Histograms become counters name.count and name.bucket with label le and gauges name.sum, name.min and name.max.
+//			@Description Resource and data point attributes become labels.
+//		    @Accept     application/x-protobuf
+//		    @Accept     json
+//			@Param	    request    body       string  true "ExportMetricsServiceRequest"
+//			@Success	200        {string}   string "ExportMetricsServiceResponse, partial_success has amount of rejected data points"
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value with rejected metrics"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//	        @Failure    415        {object}   problem.Problem "invalid_request"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /v1/metrics	[post]
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != _protobufContentType && contentType != _jsonContentType {
+		h.l.Error(fmt.Sprintf("failed to export metrics: %v %q", ErrUnsupportedContentType, contentType))
+		problem.New(http.StatusUnsupportedMediaType, problem.CodeInvalidRequest, ErrUnsupportedContentType.Error()).Write(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to read body for export: %v", err))
+
+		if limit.TooLarge(err) {
+			problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge, err.Error()).Write(w, r)
+			return
+		}
+
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	var req *otlp.Request
+
+	if contentType == _jsonContentType {
+		req, err = otlp.UnmarshalJSON(body)
+	} else {
+		req, err = otlp.UnmarshalProto(body)
+	}
+
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for export: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	c := h.convert(r.Context(), req)
+
+	// Empty response means full success
+	var resp otlp.Response
+
+	if len(c.metrics) > 0 {
+		err := h.a.Updates(r.Context(), c.metrics)
+		c.commit(err)
+
+		if err != nil {
+			h.l.Error(fmt.Sprintf("batch update failed: %v", err.Error()))
+
+			var batchErr *updating.BatchError
+			if !errors.As(err, &batchErr) || batchErr.Applied == 0 {
+				problem.Error(w, r, err)
+				return
+			}
+
+			resp = c.rejected(batchErr)
+		}
+	}
+
+	var data []byte
+
+	if contentType == _jsonContentType {
+		data, err = otlp.MarshalJSON(&resp)
+		if err != nil {
+			h.l.Error(fmt.Sprintf("failed encoding response for export: %v", err))
+			problem.Error(w, r, err)
+			return
+		}
+	} else {
+		data = otlp.MarshalProto(&resp)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(data); err != nil {
+		h.l.Error(fmt.Sprintf("failed writing response for export: %v", err))
+	}
+}
+
+// converter describes state of conversion of one request.
+type converter struct {
+	h   *Handler
+	ctx context.Context
+
+	metrics []api.Metrics
+	// points - index of source data point in request for every metric
+	points []int
+	// point - index of current data point in request
+	point int
+	// gauges - values of gauges changed by delta sums in request
+	gauges map[string]float64
+	// pending - values of cumulative counters, they are remembered only after update
+	pending *cumulative.Pending
+}
+
+// Metrics converts request to metrics.
+//
+// Metric name is name of OTLP metric, resource and data point attributes become labels,
+// data point attributes override resource ones. Not finite values are skipped.
+// Values of cumulative counters are not remembered, so the next request counts increases from the same values.
+func (h *Handler) Metrics(ctx context.Context, req *otlp.Request) []api.Metrics {
+	return h.convert(ctx, req).metrics
+}
+
+// convert converts request to metrics and remembers source data point of every metric.
+func (h *Handler) convert(ctx context.Context, req *otlp.Request) *converter {
+	c := &converter{h: h, ctx: ctx, gauges: make(map[string]float64), pending: h.counters.Pending()}
+
+	for _, rm := range req.ResourceMetrics {
+		for _, m := range rm.Metrics {
+			switch m.Type {
+			case otlp.GaugeType:
+				for _, p := range m.NumberPoints {
+					c.gauge(api.LabeledName(m.Name, labels(rm.Resource, p.Attributes)), p.Float())
+					c.point++
+				}
+			case otlp.SumType:
+				for _, p := range m.NumberPoints {
+					c.sum(&m, api.LabeledName(m.Name, labels(rm.Resource, p.Attributes)), &p)
+					c.point++
+				}
+			case otlp.HistogramType:
+				for _, p := range m.HistogramPoints {
+					c.histogram(&m, labels(rm.Resource, p.Attributes), &p)
+					c.point++
+				}
+			}
+		}
+	}
+
+	return c
+}
+
+// commit remembers values of applied cumulative counters after update with given error.
+//
+// Increases of counters in failed or rejected batch are counted again by the next request.
+func (c *converter) commit(err error) {
+	rejected := make(map[int]struct{})
+
+	if err != nil {
+		var batchErr *updating.BatchError
+		if !errors.As(err, &batchErr) || batchErr.Applied == 0 {
+			return
+		}
+
+		for _, item := range batchErr.Items {
+			rejected[item.Index] = struct{}{}
+		}
+	}
+
+	for i, m := range c.metrics {
+		if _, ok := rejected[i]; !ok && m.MType == api.CounterType {
+			c.pending.Commit(m.ID)
+		}
+	}
+}
+
+// rejected returns partial success response with amount of data points that have rejected metrics.
+func (c *converter) rejected(batchErr *updating.BatchError) otlp.Response {
+	points := make(map[int]struct{}, len(batchErr.Items))
+	for _, item := range batchErr.Items {
+		points[c.points[item.Index]] = struct{}{}
+	}
+
+	return otlp.Response{RejectedDataPoints: int64(len(points)), ErrorMessage: batchErr.Error()}
+}
+
+func labels(resource []otlp.Attribute, attributes []otlp.Attribute) map[string]string {
+	labels := make(map[string]string, len(resource)+len(attributes))
+
+	for _, a := range resource {
+		labels[a.Key] = a.Value
+	}
+
+	for _, a := range attributes {
+		labels[a.Key] = a.Value
+	}
+
+	return labels
+}
+
+func (c *converter) gauge(id string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	c.metrics = append(c.metrics, api.Metrics{ID: id, MType: api.GaugeType, Value: &value})
+	c.points = append(c.points, c.point)
+}
+
+// counter adds counter with increase of value, cumulative values are converted to increase since previous value.
+func (c *converter) counter(id string, value int64, temporality otlp.Temporality) {
+	if value < 0 {
+		return
+	}
+
+	delta := value
+
+	if temporality != otlp.DeltaTemporality {
+		delta = c.pending.Delta(id, value, func() int64 {
+			stored, err := c.h.a.Counter(c.ctx, id)
+			if err != nil {
+				return 0
+			}
+
+			return stored
+		})
+	}
+
+	c.metrics = append(c.metrics, api.Metrics{ID: id, MType: api.CounterType, Delta: &delta})
+	c.points = append(c.points, c.point)
+}
+
+// sum adds monotonic sum as counter and not monotonic sum as gauge.
+//
+// Not monotonic delta sum is added to value of gauge stored at server.
+func (c *converter) sum(m *otlp.Metric, id string, p *otlp.NumberDataPoint) {
+	if m.Monotonic {
+		if p.IsInt {
+			c.counter(id, p.IntValue, m.Temporality)
+			return
+		}
+
+		if v := p.Value; v >= 0 && v < math.MaxInt64 {
+			c.counter(id, int64(math.Round(v)), m.Temporality)
+		}
+
+		return
+	}
+
+	value := p.Float()
+
+	if m.Temporality == otlp.DeltaTemporality {
+		base, ok := c.gauges[id]
+		if !ok {
+			stored, err := c.h.a.Gauge(c.ctx, id)
+			if err == nil {
+				base = stored
+			}
+		}
+
+		value += base
+		c.gauges[id] = value
+	}
+
+	c.gauge(id, value)
+}
+
+// histogram adds counters name.count and name.bucket with upper bound in label le
+// and gauges name.sum, name.min and name.max. Buckets are cumulative as in Prometheus.
+func (c *converter) histogram(m *otlp.Metric, labels map[string]string, p *otlp.HistogramDataPoint) {
+	if p.Count <= math.MaxInt64 {
+		c.counter(api.LabeledName(m.Name+".count", labels), int64(p.Count), m.Temporality)
+	}
+
+	var total uint64
+
+	for i, count := range p.BucketCounts {
+		total += count
+
+		bucketLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			bucketLabels[k] = v
+		}
+
+		bucketLabels["le"] = "+Inf"
+		if i < len(p.ExplicitBounds) {
+			bucketLabels["le"] = fmt.Sprint(p.ExplicitBounds[i])
+		}
+
+		if total <= math.MaxInt64 {
+			c.counter(api.LabeledName(m.Name+".bucket", bucketLabels), int64(total), m.Temporality)
+		}
+	}
+
+	gauges := []struct {
+		suffix string
+		value  *float64
+	}{
+		{".sum", p.Sum},
+		{".min", p.Min},
+		{".max", p.Max},
+	}
+
+	for _, g := range gauges {
+		if g.value != nil {
+			c.gauge(api.LabeledName(m.Name+g.suffix, labels), *g.value)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package otlp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+var errNotFound = errors.New("not found")
+
+const exportJSON = `{"resourceMetrics": [{
+  "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+  "scopeMetrics": [{"metrics": [
+    {
+      "name": "http.requests",
+      "sum": {
+        "dataPoints": [{"attributes": [{"key": "code", "value": {"intValue": "200"}}], "asInt": "42"}],
+        "aggregationTemporality": 2, "isMonotonic": true
+      }
+    },
+    {
+      "name": "jobs.active",
+      "sum": {"dataPoints": [{"asInt": "-2"}], "aggregationTemporality": 1}
+    },
+    {"name": "memory.usage", "gauge": {"dataPoints": [{"asDouble": 0.5}, {"asDouble": "NaN"}]}},
+    {
+      "name": "http.duration",
+      "histogram": {
+        "dataPoints": [{"count": "3", "sum": 1.5, "bucketCounts": ["1", "2"], "explicitBounds": [0.5], "max": 1.2}],
+        "aggregationTemporality": 1
+      }
+    }
+  ]}]
+}]}`
+
+func gauge(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
+
+func counter(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func newServer(t *testing.T, s updating.Service) *httptest.Server {
+	var h *Handler
+
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.export(w, r)
+	}))
+	l.On("Error", mock.Anything).Return().Maybe()
+
+	h = NewHandler(s, l)
+
+	r := chi.NewRouter()
+	r.Mount("/v1/metrics", h.Export("", nil))
+
+	return httptest.NewServer(r)
+}
+
+func TestExportHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []api.Metrics
+		serviceErr  error
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "success case",
+			contentType: "application/json",
+			body:        exportJSON,
+			want: []api.Metrics{
+				counter("http.requests;code=200;service.name=checkout", 42),
+				gauge("jobs.active;service.name=checkout", 8),
+				gauge("memory.usage;service.name=checkout", 0.5),
+				counter("http.duration.count;service.name=checkout", 3),
+				counter("http.duration.bucket;le=0.5;service.name=checkout", 1),
+				counter("http.duration.bucket;le=+Inf;service.name=checkout", 3),
+				gauge("http.duration.sum;service.name=checkout", 1.5),
+				gauge("http.duration.max;service.name=checkout", 1.2),
+			},
+			wantCode: http.StatusOK,
+			wantBody: "{}",
+		},
+		{
+			name:        "empty protobuf request",
+			contentType: "application/x-protobuf",
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "invalid protobuf",
+			contentType: "application/x-protobuf",
+			body:        "\x0a\x05",
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "invalid JSON",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"resourceMetrics": {}}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        "cpu 1",
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "service error",
+			contentType: "application/json",
+			body:        `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{"name": "a", "gauge": {"dataPoints": [{"asInt": "1"}]}}]}]}]}`,
+			want:        []api.Metrics{gauge("a", 1)},
+			serviceErr:  errors.New("storage is unavailable"),
+			wantCode:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := updating.NewMockService(t)
+
+			s.On("Counter", mock.Anything, mock.Anything).Return(int64(0), errNotFound).Maybe()
+			s.On("Gauge", mock.Anything, "jobs.active;service.name=checkout").Return(10.0, nil).Maybe()
+
+			if test.want != nil {
+				s.On("Updates", mock.Anything, test.want).Return(test.serviceErr)
+			}
+
+			srv := newServer(t, s)
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+"/v1/metrics", test.contentType, strings.NewReader(test.body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+
+			if test.wantBody != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestExportHandler_partialSuccess(t *testing.T) {
+	s := updating.NewMockService(t)
+
+	s.On("Counter", mock.Anything, mock.Anything).Return(int64(0), errNotFound).Maybe()
+	s.On("Gauge", mock.Anything, mock.Anything).Return(10.0, nil).Maybe()
+
+	// Counter of the first data point and three metrics of histogram data point are rejected
+	s.On("Updates", mock.Anything, mock.Anything).Return(&updating.BatchError{
+		Items: []updating.ItemError{
+			{Index: 0, ID: "http.requests;code=200;service.name=checkout", Err: updating.ErrTypeConflict},
+			{Index: 3, ID: "http.duration.count;service.name=checkout", Err: updating.ErrTypeConflict},
+			{Index: 4, ID: "http.duration.bucket;le=0.5;service.name=checkout", Err: updating.ErrTypeConflict},
+			{Index: 6, ID: "http.duration.sum;service.name=checkout", Err: updating.ErrTypeConflict},
+		},
+		Applied: 4,
+	})
+
+	srv := newServer(t, s)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/metrics", "application/json", strings.NewReader(exportJSON))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		PartialSuccess struct {
+			RejectedDataPoints string `json:"rejectedDataPoints"`
+			ErrorMessage       string `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "2", body.PartialSuccess.RejectedDataPoints)
+	assert.Contains(t, body.PartialSuccess.ErrorMessage, "http.duration.sum;service.name=checkout")
+}
+
+func TestExportHandler_cumulativeSums(t *testing.T) {
+	s := updating.NewMockService(t)
+
+	s.On("Counter", mock.Anything, "jobs.done").Return(int64(0), errNotFound).Once()
+
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 10)}).Return(nil).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 4)}).Return(nil).Once()
+	// Process is restarted
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 1)}).Return(nil).Once()
+
+	srv := newServer(t, s)
+	defer srv.Close()
+
+	for _, value := range []string{"10", "14", "1"} {
+		body := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{"name": "jobs.done", "sum": {` +
+			`"dataPoints": [{"asInt": "` + value + `"}], "aggregationTemporality": 2, "isMonotonic": true}}]}]}]}`
+
+		resp, err := http.Post(srv.URL+"/v1/metrics", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestExportHandler_cumulativeSumsNotApplied(t *testing.T) {
+	s := updating.NewMockService(t)
+
+	s.On("Counter", mock.Anything, "jobs.done").Return(int64(0), errNotFound)
+
+	// Failed and rejected increases are counted again by the next request
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 10)}).Return(errors.New("storage is unavailable")).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 12)}).Return(&updating.BatchError{
+		Items: []updating.ItemError{{Index: 0, ID: "jobs.done", Err: updating.ErrTypeConflict}},
+	}).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 15)}).Return(nil).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs.done", 1)}).Return(nil).Once()
+
+	srv := newServer(t, s)
+	defer srv.Close()
+
+	for _, value := range []string{"10", "12", "15", "16"} {
+		body := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{"name": "jobs.done", "sum": {` +
+			`"dataPoints": [{"asInt": "` + value + `"}], "aggregationTemporality": 2, "isMonotonic": true}}]}]}]}`
+
+		resp, err := http.Post(srv.URL+"/v1/metrics", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,169 @@
+// Package problem describes RFC 7807 error responses of REST API
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/storage/db"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+// ContentType is media type of problem responses.
+const ContentType = "application/problem+json"
+
+// Stable error codes, clients may rely on them instead of detail text.
+const (
+	CodeMetricNotFound     = "metric_not_found"
+	CodeMetadataNotFound   = "metadata_not_found"
+	CodeTypeUnsupported    = "type_unsupported"
+	CodeTypeConflict       = "type_conflict"
+	CodeInvalidValue       = "invalid_value"
+	CodeInvalidRequest     = "invalid_request"
+	CodeRequestTooLarge    = "request_too_large"
+	CodeStorageUnavailable = "storage_unavailable"
+)
+
+// Prefix of problem type URIs, type is prefix followed by code.
+const _typePrefix = "https://github.com/kaa-it/go-devops/problems/"
+
+// Problem describes error response.
+type Problem struct {
+	// Type - URI identifying error code.
+	Type string `json:"type"`
+	// Title - short summary of error code.
+	Title string `json:"title"`
+	// Status - HTTP status code.
+	Status int `json:"status"`
+	// Detail - explanation of this occurrence of error, internal errors are never exposed.
+	Detail string `json:"detail,omitempty"`
+	// Instance - path of request.
+	Instance string `json:"instance,omitempty"`
+	// Code - stable error code.
+	Code string `json:"code"`
+	// Errors - rejected items of batch request.
+	Errors []Item `json:"errors,omitempty"`
+}
+
+// Item describes rejected item of batch request.
+type Item struct {
+	// Index - position of item in batch.
+	Index int `json:"index"`
+	// ID - metric name.
+	ID string `json:"id"`
+	// Code - stable error code.
+	Code string `json:"code"`
+	// Detail - reason of rejection.
+	Detail string `json:"detail"`
+}
+
+var _titles = map[string]string{
+	CodeMetricNotFound:     "Metric not found",
+	CodeMetadataNotFound:   "Metadata not found",
+	CodeTypeUnsupported:    "Metric type is not supported",
+	CodeTypeConflict:       "Metric exists with another type",
+	CodeInvalidValue:       "Metric value is invalid",
+	CodeInvalidRequest:     "Request is malformed",
+	CodeRequestTooLarge:    "Request is too large",
+	CodeStorageUnavailable: "Storage is unavailable",
+}
+
+// New creates problem with given status, code and detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   _typePrefix + code,
+		Title:  _titles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// FromError creates problem for error by sentinel errors it wraps.
+//
+// Unknown errors become storage_unavailable without detail, so internal errors do not leak.
+func FromError(err error) *Problem {
+	var batchErr *updating.BatchError
+	if errors.As(err, &batchErr) {
+		p := New(http.StatusBadRequest, CodeInvalidValue, "Some metrics of batch are rejected")
+		if batchErr.Applied > 0 {
+			p.Detail = fmt.Sprintf("Some metrics of batch are rejected, %d metrics are applied", batchErr.Applied)
+		}
+
+		for _, item := range batchErr.Items {
+			p.Errors = append(p.Errors, Item{
+				Index:  item.Index,
+				ID:     item.ID,
+				Code:   Code(item.Err),
+				Detail: item.Err.Error(),
+			})
+		}
+
+		return p
+	}
+
+	code := Code(err)
+
+	switch code {
+	case CodeMetricNotFound, CodeMetadataNotFound:
+		return New(http.StatusNotFound, code, err.Error())
+	case CodeTypeUnsupported:
+		return New(http.StatusNotImplemented, code, err.Error())
+	case CodeTypeConflict:
+		return New(http.StatusConflict, code, err.Error())
+	case CodeInvalidValue, CodeInvalidRequest:
+		return New(http.StatusBadRequest, code, err.Error())
+	default:
+		return New(http.StatusInternalServerError, code, "")
+	}
+}
+
+// Code returns stable error code for error.
+func Code(err error) string {
+	switch {
+	case errors.Is(err, memory.ErrGaugeNotFound),
+		errors.Is(err, memory.ErrCounterNotFound),
+		errors.Is(err, db.ErrGaugeNotFound),
+		errors.Is(err, db.ErrCounterNotFound):
+		return CodeMetricNotFound
+	case errors.Is(err, memory.ErrMetadataNotFound),
+		errors.Is(err, db.ErrMetadataNotFound):
+		return CodeMetadataNotFound
+	case errors.Is(err, updating.ErrUnsupportedType),
+		errors.Is(err, viewing.ErrUnsupportedType):
+		return CodeTypeUnsupported
+	case errors.Is(err, updating.ErrTypeConflict):
+		return CodeTypeConflict
+	case errors.Is(err, updating.ErrMissingValue),
+		errors.Is(err, updating.ErrInvalidValue),
+		errors.Is(err, api.ErrInvalidMetadata):
+		return CodeInvalidValue
+	case errors.Is(err, viewing.ErrInvalidQuery):
+		return CodeInvalidRequest
+	default:
+		return CodeStorageUnavailable
+	}
+}
+
+// Write writes problem as response to request.
+//
+// Encoding error means that client has gone, so it is ignored, cause of problem is logged by handler.
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) {
+	p.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Error writes problem for error as response to request.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	FromError(err).Write(w, r)
+}
@@ -0,0 +1,132 @@
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/storage/db"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name:       "memory gauge not found",
+			err:        fmt.Errorf("failed to get Alloc gauge: %w", memory.ErrGaugeNotFound),
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeMetricNotFound,
+			wantDetail: "failed to get Alloc gauge: gauge not found",
+		},
+		{
+			name:       "database counter not found",
+			err:        db.ErrCounterNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeMetricNotFound,
+			wantDetail: "counter not found",
+		},
+		{
+			name:       "metadata not found",
+			err:        memory.ErrMetadataNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeMetadataNotFound,
+			wantDetail: "metadata not found",
+		},
+		{
+			name:       "unsupported type",
+			err:        updating.ErrUnsupportedType,
+			wantStatus: http.StatusNotImplemented,
+			wantCode:   CodeTypeUnsupported,
+			wantDetail: "unsupported metric type",
+		},
+		{
+			name:       "type conflict",
+			err:        updating.ErrTypeConflict,
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeTypeConflict,
+			wantDetail: "metric type conflict",
+		},
+		{
+			name:       "invalid metadata",
+			err:        api.ErrInvalidMetadata,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidValue,
+			wantDetail: "invalid metadata",
+		},
+		{
+			name:       "invalid query",
+			err:        fmt.Errorf("%w: malformed cursor", viewing.ErrInvalidQuery),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidRequest,
+			wantDetail: "invalid query: malformed cursor",
+		},
+		{
+			name:       "internal error is not exposed",
+			err:        errors.New("pgx: failed to connect to host=localhost"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   CodeStorageUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := FromError(test.err)
+
+			assert.Equal(t, test.wantStatus, p.Status)
+			assert.Equal(t, test.wantCode, p.Code)
+			assert.Equal(t, test.wantDetail, p.Detail)
+			assert.Equal(t, "https://github.com/kaa-it/go-devops/problems/"+test.wantCode, p.Type)
+			assert.NotEmpty(t, p.Title)
+		})
+	}
+}
+
+func TestFromError_batch(t *testing.T) {
+	p := FromError(&updating.BatchError{Items: []updating.ItemError{
+		{Index: 0, ID: "Alloc", Err: updating.ErrMissingValue},
+		{Index: 2, ID: "PollCount", Err: updating.ErrTypeConflict},
+	}})
+
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, CodeInvalidValue, p.Code)
+	assert.Equal(t, []Item{
+		{Index: 0, ID: "Alloc", Code: CodeInvalidValue, Detail: "missing metric value"},
+		{Index: 2, ID: "PollCount", Code: CodeTypeConflict, Detail: "metric type conflict"},
+	}, p.Errors)
+}
+
+func TestFromError_partialBatch(t *testing.T) {
+	p := FromError(&updating.BatchError{
+		Items:   []updating.ItemError{{Index: 1, ID: "PollCount", Err: updating.ErrTypeConflict}},
+		Applied: 2,
+	})
+
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, "Some metrics of batch are rejected, 2 metrics are applied", p.Detail)
+	assert.Len(t, p.Errors, 1)
+}
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+
+	Error(w, r, memory.ErrGaugeNotFound)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"https://github.com/kaa-it/go-devops/problems/metric_not_found","title":"Metric not found",`+
+		`"status":404,"detail":"gauge not found","instance":"/value/gauge/Alloc","code":"metric_not_found"}`, w.Body.String())
+}
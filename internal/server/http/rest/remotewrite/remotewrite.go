@@ -0,0 +1,347 @@
+// Package remotewrite describes handler for Prometheus remote write receiver at server
+package remotewrite
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/remotewrite"
+	"github.com/kaa-it/go-devops/internal/server/cumulative"
+	"github.com/kaa-it/go-devops/internal/server/decrypt"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+const (
+	// Maximal size of decompressed request, compressed request is limited by server limit middleware.
+	_maxDecodedSize = 32 << 20
+
+	// Types of families above limit are not remembered, their series are typed by suffixes
+	_maxFamilies = 10_000
+)
+
+// Metrics without type hint and with these suffixes are counters.
+var _counterSuffixes = []string{"_total", "_bucket", "_count"}
+
+// Sentinel errors for remote write handler.
+var (
+	ErrTooLarge = errors.New("decompressed request is too large")
+	ErrNoName   = errors.New("time series without metric name")
+)
+
+type Logger interface {
+	RequestLogger(h http.HandlerFunc) http.HandlerFunc
+	Error(args ...interface{})
+}
+
+// Handler describes common state for all handlers in package
+type Handler struct {
+	a updating.Service
+	l Logger
+
+	counters *cumulative.Tracker
+
+	mu          sync.RWMutex
+	types       map[string]remotewrite.MetricType
+	maxFamilies int
+}
+
+// NewHandler creates new instance of Handler
+func NewHandler(a updating.Service, l Logger) *Handler {
+	return &Handler{
+		a:           a,
+		l:           l,
+		counters:    cumulative.NewTracker(),
+		types:       make(map[string]remotewrite.MetricType),
+		maxFamilies: _maxFamilies,
+	}
+}
+
+// Write returns handler for /api/v1/write route.
+func (h *Handler) Write(key string, privateKey *rsa.PrivateKey) http.HandlerFunc {
+	return h.l.RequestLogger(
+		hash.Middleware(
+			key,
+			decrypt.Middleware(
+				privateKey,
+				h.write,
+			),
+		),
+	)
+}
+
+//			@Tags	 Update
+//			@Summary Request to write metrics with Prometheus remote write protocol
+//			@Description Body is snappy compressed protobuf WriteRequest. Counter families from metadata
+//			@Description and series with _total, _bucket or _count suffixes are counters, other series are gauges.
+//			@Description Counter values are cumulative, server adds their increase since previous request.
+//		    @Accept     application/x-protobuf
+//			@Param	    request    body       string  true "Snappy compressed WriteRequest"
+//			@Success	204
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value with rejected metrics"
+//	        @Failure    413        {object}   problem.Problem "request_too_large"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/write	[post]
+func (h *Handler) write(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to read body for remote write: %v", err))
+
+		if limit.TooLarge(err) {
+			problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge, err.Error()).Write(w, r)
+			return
+		}
+
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	req, err := decode(body)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for remote write: %v", err))
+
+		if errors.Is(err, ErrTooLarge) {
+			problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge, err.Error()).Write(w, r)
+			return
+		}
+
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	metrics, pending, err := h.Metrics(r.Context(), req)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed converting series for remote write: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	if len(metrics) > 0 {
+		err := h.a.Updates(r.Context(), metrics)
+		commit(pending, metrics, err)
+
+		if err != nil {
+			h.l.Error(fmt.Sprintf("batch update failed: %v", err.Error()))
+
+			// Client error is not retried by Prometheus, so partially applied batch is not sent again
+			problem.Error(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decode(body []byte) (*remotewrite.WriteRequest, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > _maxDecodedSize {
+		return nil, ErrTooLarge
+	}
+
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return remotewrite.Unmarshal(data)
+}
+
+// Metrics converts time series to metrics.
+//
+// Metric name is value of __name__ label, other labels become labels of metric.
+// Gauges get value of the last sample. Counters are increased by growth of samples since previous
+// request, for series unknown to handler growth is counted from value stored at server.
+// Values of counters are remembered only when returned pending values are committed after update.
+// Stale markers and other not finite samples are skipped.
+func (h *Handler) Metrics(ctx context.Context, req *remotewrite.WriteRequest) ([]api.Metrics, *cumulative.Pending, error) {
+	for _, ts := range req.TimeSeries {
+		if ts.Name() == "" {
+			return nil, nil, ErrNoName
+		}
+	}
+
+	h.learnTypes(req.Metadata)
+
+	var metrics []api.Metrics
+
+	pending := h.counters.Pending()
+
+	for _, ts := range req.TimeSeries {
+		name := ts.Name()
+
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name != remotewrite.NameLabel && l.Value != "" {
+				labels[l.Name] = l.Value
+			}
+		}
+
+		id := api.LabeledName(name, labels)
+
+		if h.isCounter(name) {
+			if m, ok := h.counter(ctx, pending, id, ts.Samples); ok {
+				metrics = append(metrics, m)
+			}
+
+			continue
+		}
+
+		if m, ok := lastGauge(id, ts.Samples); ok {
+			metrics = append(metrics, m)
+		}
+	}
+
+	return metrics, pending, nil
+}
+
+// commit remembers values of applied counters, increases of rejected ones are counted again by the next request.
+func commit(pending *cumulative.Pending, metrics []api.Metrics, err error) {
+	rejected := make(map[int]struct{})
+
+	if err != nil {
+		var batchErr *updating.BatchError
+		if !errors.As(err, &batchErr) || batchErr.Applied == 0 {
+			return
+		}
+
+		for _, item := range batchErr.Items {
+			rejected[item.Index] = struct{}{}
+		}
+	}
+
+	for i, m := range metrics {
+		if _, ok := rejected[i]; !ok && m.MType == api.CounterType {
+			pending.Commit(m.ID)
+		}
+	}
+}
+
+func (h *Handler) counter(ctx context.Context, pending *cumulative.Pending, id string, samples []remotewrite.Sample) (api.Metrics, bool) {
+	var delta int64
+	var ok bool
+
+	stored := func() int64 {
+		value, err := h.a.Counter(ctx, id)
+		if err != nil {
+			return 0
+		}
+
+		return value
+	}
+
+	for _, s := range samples {
+		if !valid(s.Value) || s.Value < 0 || s.Value >= math.MaxInt64 {
+			continue
+		}
+
+		delta += pending.Delta(id, int64(math.Round(s.Value)), stored)
+		ok = true
+	}
+
+	return api.Metrics{ID: id, MType: api.CounterType, Delta: &delta}, ok
+}
+
+func lastGauge(id string, samples []remotewrite.Sample) (api.Metrics, bool) {
+	var last *remotewrite.Sample
+
+	for i := range samples {
+		if valid(samples[i].Value) && (last == nil || samples[i].Timestamp >= last.Timestamp) {
+			last = &samples[i]
+		}
+	}
+
+	if last == nil {
+		return api.Metrics{}, false
+	}
+
+	value := last.Value
+
+	return api.Metrics{ID: id, MType: api.GaugeType, Value: &value}, true
+}
+
+func valid(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// learnTypes remembers types of metric families, Prometheus sends metadata only from time to time.
+// Amount of remembered families is limited by maxFamilies.
+func (h *Handler) learnTypes(metadata []remotewrite.Metadata) {
+	if len(metadata) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, m := range metadata {
+		if _, ok := h.types[m.FamilyName]; !ok && len(h.types) >= h.maxFamilies {
+			continue
+		}
+
+		h.types[m.FamilyName] = m.Type
+	}
+}
+
+// isCounter returns true if series with given name is counter.
+//
+// Family may be named with or without _total suffix of counter. Buckets and counts of histograms
+// and summaries are counters, sums and quantiles are gauges.
+func (h *Handler) isCounter(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if t, ok := h.types[name]; ok {
+		return t == remotewrite.CounterType
+	}
+
+	for _, suffix := range []string{"_total", "_bucket", "_count", "_sum"} {
+		family, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+
+		t, ok := h.types[family]
+		if !ok {
+			continue
+		}
+
+		switch suffix {
+		case "_total":
+			return t == remotewrite.CounterType
+		case "_sum":
+			return false
+		default:
+			return t == remotewrite.HistogramType || t == remotewrite.SummaryType
+		}
+	}
+
+	for _, suffix := range _counterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,244 @@
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/remotewrite"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/limit"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+var errNotFound = errors.New("counter not found")
+
+func series(name string, values ...float64) remotewrite.TimeSeries {
+	ts := remotewrite.TimeSeries{Labels: []remotewrite.Label{{Name: remotewrite.NameLabel, Value: name}}}
+
+	for i, v := range values {
+		ts.Samples = append(ts.Samples, remotewrite.Sample{Value: v, Timestamp: int64(1700000000000 + i*15000)})
+	}
+
+	return ts
+}
+
+func encode(req *remotewrite.WriteRequest) []byte {
+	return snappy.Encode(nil, remotewrite.Marshal(req))
+}
+
+func gauge(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
+
+func counter(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func newServer(t *testing.T, s updating.Service, wantErrors bool) *httptest.Server {
+	var h *Handler
+
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.write(w, r)
+	}))
+
+	if wantErrors {
+		l.On("Error", mock.Anything).Return().Maybe()
+	}
+
+	h = NewHandler(s, l)
+
+	r := chi.NewRouter()
+	r.Mount("/api/v1/write", limit.Middleware(1024, h.Write("", nil)))
+
+	return httptest.NewServer(r)
+}
+
+func post(t *testing.T, url string, body []byte) int {
+	resp, err := http.Post(url+"/api/v1/write", "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestWriteHandler(t *testing.T) {
+	requests := series("http_requests_total", 10, 12)
+	requests.Labels = append(requests.Labels, remotewrite.Label{Name: "code", Value: "200"})
+
+	staleMarker := math.Float64frombits(0x7ff0000000000002)
+
+	tests := []struct {
+		name       string
+		body       []byte
+		want       []api.Metrics
+		serviceErr error
+		wantCode   int
+	}{
+		{
+			name: "success case",
+			body: encode(&remotewrite.WriteRequest{
+				TimeSeries: []remotewrite.TimeSeries{
+					requests,
+					series("temperature", 21.5, 22, staleMarker),
+					series("request_duration_seconds_bucket", 4),
+					series("request_duration_seconds_sum", 0.75),
+					series("up", staleMarker),
+				},
+				Metadata: []remotewrite.Metadata{
+					{Type: remotewrite.CounterType, FamilyName: "http_requests"},
+					{Type: remotewrite.HistogramType, FamilyName: "request_duration_seconds"},
+				},
+			}),
+			want: []api.Metrics{
+				counter("http_requests_total;code=200", 12),
+				gauge("temperature", 22),
+				counter("request_duration_seconds_bucket", 4),
+				gauge("request_duration_seconds_sum", 0.75),
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "invalid snappy",
+			body:     []byte("not snappy"),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid protobuf",
+			body:     snappy.Encode(nil, []byte{0x0a, 0x05}),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "series without name",
+			body:     encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{{}}}),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "too large request",
+			body:     bytes.Repeat([]byte{0}, 2048),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "service error",
+			body:       encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("temperature", 1)}}),
+			want:       []api.Metrics{gauge("temperature", 1)},
+			serviceErr: errors.New("storage is unavailable"),
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := updating.NewMockService(t)
+
+			s.On("Counter", mock.Anything, mock.Anything).Return(int64(0), errNotFound).Maybe()
+
+			if test.want != nil {
+				s.On("Updates", mock.Anything, test.want).Return(test.serviceErr)
+			}
+
+			srv := newServer(t, s, test.wantCode != http.StatusNoContent)
+			defer srv.Close()
+
+			assert.Equal(t, test.wantCode, post(t, srv.URL, test.body))
+		})
+	}
+}
+
+func TestWriteHandler_rejected(t *testing.T) {
+	s := updating.NewMockService(t)
+	s.On("Updates", mock.Anything, []api.Metrics{gauge("temperature", 1), gauge("humidity", 2)}).Return(&updating.BatchError{
+		Items:   []updating.ItemError{{Index: 1, ID: "humidity", Err: updating.ErrTypeConflict}},
+		Applied: 1,
+	})
+
+	srv := newServer(t, s, true)
+	defer srv.Close()
+
+	body := encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("temperature", 1), series("humidity", 2)}})
+
+	resp, err := http.Post(srv.URL+"/api/v1/write", "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var p problem.Problem
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, []problem.Item{{Index: 1, ID: "humidity", Code: problem.CodeTypeConflict, Detail: "metric type conflict"}}, p.Errors)
+}
+
+func TestWriteHandler_cumulativeCounters(t *testing.T) {
+	s := updating.NewMockService(t)
+
+	// Counter stored before restart of server is used as previous value
+	s.On("Counter", mock.Anything, "jobs_total").Return(int64(100), nil).Once()
+
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 20)}).Return(nil).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 5)}).Return(nil).Once()
+	// Counter is reset
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 3)}).Return(nil).Once()
+
+	srv := newServer(t, s, false)
+	defer srv.Close()
+
+	for _, value := range []float64{120, 125, 3} {
+		body := encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("jobs_total", value)}})
+		assert.Equal(t, http.StatusNoContent, post(t, srv.URL, body))
+	}
+}
+
+func TestWriteHandler_counterNotApplied(t *testing.T) {
+	s := updating.NewMockService(t)
+	s.On("Counter", mock.Anything, mock.Anything).Return(int64(0), errNotFound)
+
+	// Failed and rejected increases are counted again by the next request
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 10)}).Return(errors.New("storage is unavailable")).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 10), counter("errors_total", 2)}).Return(&updating.BatchError{
+		Items:   []updating.ItemError{{Index: 0, ID: "jobs_total", Err: updating.ErrTypeConflict}},
+		Applied: 1,
+	}).Once()
+	s.On("Updates", mock.Anything, []api.Metrics{counter("jobs_total", 12), counter("errors_total", 1)}).Return(nil).Once()
+
+	srv := newServer(t, s, true)
+	defer srv.Close()
+
+	body := encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("jobs_total", 10)}})
+	assert.Equal(t, http.StatusInternalServerError, post(t, srv.URL, body))
+
+	// Series without name rejects request before counters are tracked
+	body = encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("jobs_total", 11), {}}})
+	assert.Equal(t, http.StatusBadRequest, post(t, srv.URL, body))
+
+	body = encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("jobs_total", 10), series("errors_total", 2)}})
+	assert.Equal(t, http.StatusBadRequest, post(t, srv.URL, body))
+
+	body = encode(&remotewrite.WriteRequest{TimeSeries: []remotewrite.TimeSeries{series("jobs_total", 12), series("errors_total", 3)}})
+	assert.Equal(t, http.StatusNoContent, post(t, srv.URL, body))
+}
+
+func TestHandler_learnTypes(t *testing.T) {
+	h := NewHandler(updating.NewMockService(t), NewMockLogger(t))
+	h.maxFamilies = 1
+
+	h.learnTypes([]remotewrite.Metadata{
+		{Type: remotewrite.CounterType, FamilyName: "jobs"},
+		{Type: remotewrite.CounterType, FamilyName: "errors"},
+	})
+
+	assert.True(t, h.isCounter("jobs"))
+	assert.False(t, h.isCounter("errors"))
+}
@@ -0,0 +1,187 @@
+package updating
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/decrypt"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+// ValueRequest describes body format for request to put metric value.
+type ValueRequest struct {
+	// Delta - value for counter metric, it is added to current value.
+	Delta *int64 `json:"delta,omitempty"`
+	// Value - value for gauge metric.
+	Value *float64 `json:"value,omitempty"`
+	// Metadata - optional metric metadata, not empty fields are set over stored ones.
+	Metadata *api.Metadata `json:"metadata,omitempty"`
+}
+
+// RouteMetrics registers write handlers of /api/v1/metrics resource in router.
+//
+// Metric name is the rest of path, so names with labels may contain slashes.
+func (h *Handler) RouteMetrics(r chi.Router, key string, privateKey *rsa.PrivateKey) {
+	r.Post("/", h.l.RequestLogger(
+		hash.Middleware(
+			key,
+			decrypt.Middleware(
+				privateKey,
+				gzip.Middleware(h.batch),
+			),
+		),
+	))
+	r.Put("/{type}/*", h.l.RequestLogger(
+		hash.Middleware(
+			key,
+			decrypt.Middleware(
+				privateKey,
+				gzip.Middleware(h.put),
+			),
+		),
+	))
+	r.Delete("/{type}/*", h.l.RequestLogger(
+		hash.RequestMiddleware(
+			key,
+			h.delete,
+		),
+	))
+}
+
+//			@Tags	 Metrics
+//			@Summary Request to update some metrics simultaneously
+//			@Description Invalid metrics are listed in errors of response. In atomic batch mode the whole batch is rejected,
+//			@Description in partial mode valid metrics are applied and status is 200 if any of them is applied.
+//		    @Accept     json
+//			@Produce    json
+//			@Produce    application/problem+json
+//			@Param	    request    body       []api.Metrics  true "Batch metric update request"
+//			@Success	200        {object}   BatchReport "Applied metrics, rejected ones are listed in partial batch mode"
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value with rejected metrics"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/metrics	[post]
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	var req []api.Metrics
+
+	dec := json.NewDecoder(r.Body)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err := dec.Decode(&req); err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for updates: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	if len(req) == 0 {
+		h.l.Error("metric batch is empty")
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Metric batch is empty").Write(w, r)
+		return
+	}
+
+	if err := h.a.Updates(r.Context(), req); err != nil {
+		h.l.Error(fmt.Sprintf("batch update failed: %v", err.Error()))
+
+		var batchErr *updating.BatchError
+		if errors.As(err, &batchErr) && batchErr.Applied > 0 {
+			h.report(w, BatchReport{Applied: batchErr.Applied, Errors: problem.FromError(batchErr).Errors})
+			return
+		}
+
+		problem.Error(w, r, err)
+		return
+	}
+
+	h.report(w, BatchReport{Applied: len(req), Errors: []problem.Item{}})
+}
+
+//			@Tags	 Metrics
+//			@Summary Request to put metric value
+//			@Description Gauge value replaces current one, counter delta is added to current value.
+//		    @Accept     json
+//			@Produce    json
+//			@Produce    application/problem+json
+//			@Param	    type       path       string  true "Metric type" Enums(gauge, counter)
+//	        @Param      id         path       string  true "Metric name"
+//			@Param	    request    body       ValueRequest  true "Metric value"
+//			@Success	200        {object}   api.Metrics "Metric after update"
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value"
+//			@Failure    409        {object}   problem.Problem "type_conflict"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/metrics/{type}/{id}	[put]
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	var req ValueRequest
+
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
+		}
+	}()
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.l.Error(fmt.Sprintf("failed decoding body for put: %v", err))
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
+		return
+	}
+
+	m := api.Metrics{
+		ID:       chi.URLParam(r, "*"),
+		MType:    api.MetricsType(chi.URLParam(r, "type")),
+		Delta:    req.Delta,
+		Value:    req.Value,
+		Metadata: req.Metadata,
+	}
+
+	res, err := h.a.Update(r.Context(), m)
+	if err != nil {
+		h.l.Error(err.Error())
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error(fmt.Sprintf("failed encoding body for put: %v", err))
+	}
+}
+
+//			@Tags	 Metrics
+//			@Summary Request to delete metric
+//			@Description Metadata of metric is kept, it is managed with /metadata routes.
+//			@Produce    application/problem+json
+//			@Param	    type       path       string  true "Metric type" Enums(gauge, counter)
+//	        @Param      id         path       string  true "Metric name"
+//	        @Param      Hash       header     string  false "HMAC-SHA256 of method and path, required if server key is set"
+//			@Success	204
+//			@Failure    400
+//			@Failure    404        {object}   problem.Problem "metric_not_found"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/metrics/{type}/{id}	[delete]
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	mType := api.MetricsType(chi.URLParam(r, "type"))
+	name := chi.URLParam(r, "*")
+
+	if err := h.a.Delete(r.Context(), mType, name); err != nil {
+		h.l.Error(err.Error())
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
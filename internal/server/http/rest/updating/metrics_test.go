@@ -0,0 +1,180 @@
+package updating
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+	"github.com/kaa-it/go-devops/internal/server/updating"
+)
+
+func newMetricsServer(t *testing.T, s updating.Service) *httptest.Server {
+	return newKeyMetricsServer(t, s, "")
+}
+
+func newKeyMetricsServer(t *testing.T, s updating.Service, key string) *httptest.Server {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return().Maybe()
+
+	r := chi.NewRouter()
+	r.Route("/api/v1/metrics", func(r chi.Router) {
+		NewHandler(s, l).RouteMetrics(r, key, nil)
+	})
+
+	return httptest.NewServer(r)
+}
+
+func TestPutHandler(t *testing.T) {
+	delta := int64(5)
+	total := int64(12)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		want       api.Metrics
+		serviceErr error
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name: "counter with metadata",
+			path: "/counter/http.requests;path=/a/b",
+			body: `{"delta": 5, "metadata": {"unit": "requests"}}`,
+			want: api.Metrics{
+				ID:       "http.requests;path=/a/b",
+				MType:    api.CounterType,
+				Delta:    &delta,
+				Metadata: &api.Metadata{Unit: "requests"},
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"id":"http.requests;path=/a/b","type":"counter","delta":12,"metadata":{"unit":"requests"}}`,
+		},
+		{
+			name: "type conflict",
+			path: "/counter/Alloc",
+			body: `{"delta": 5}`,
+			want: api.Metrics{ID: "Alloc", MType: api.CounterType, Delta: &delta},
+			serviceErr: fmt.Errorf("metric %q: %w: stored as %s",
+				"Alloc", updating.ErrTypeConflict, api.GaugeType),
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "malformed body",
+			path:     "/gauge/Alloc",
+			body:     `{"value": "big"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := updating.NewMockService(t)
+
+			if test.want.ID != "" {
+				res := test.want
+				res.Delta = &total
+				s.On("Update", mock.Anything, test.want).Return(res, test.serviceErr)
+			}
+
+			srv := newMetricsServer(t, s)
+			defer srv.Close()
+
+			req := resty.New().R()
+			req.Method = http.MethodPut
+			req.URL = srv.URL + "/api/v1/metrics" + test.path
+			req.SetHeader("Content-Type", "application/json")
+			req.SetBody(test.body)
+
+			resp, err := req.Send()
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+
+			if test.wantBody != "" {
+				assert.JSONEq(t, test.wantBody, string(resp.Body()))
+			}
+		})
+	}
+}
+
+func TestDeleteHandler(t *testing.T) {
+	s := updating.NewMockService(t)
+	s.On("Delete", mock.Anything, api.GaugeType, "Alloc").Return(nil)
+	s.On("Delete", mock.Anything, api.CounterType, "PollCount").Return(
+		fmt.Errorf("failed to delete counter PollCount: %w", memory.ErrCounterNotFound),
+	)
+
+	srv := newMetricsServer(t, s)
+	defer srv.Close()
+
+	resp, err := resty.New().R().Delete(srv.URL + "/api/v1/metrics/gauge/Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	assert.Empty(t, resp.Body())
+
+	resp, err = resty.New().R().Delete(srv.URL + "/api/v1/metrics/counter/PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	assertProblem(t, resp, problem.CodeMetricNotFound)
+}
+
+func TestDeleteHandler_hash(t *testing.T) {
+	s := updating.NewMockService(t)
+
+	srv := newKeyMetricsServer(t, s, "secret")
+	defer srv.Close()
+
+	s.On("Delete", mock.Anything, api.GaugeType, "Alloc").Return(nil).Once()
+
+	for _, h := range []string{"", "aW52YWxpZA==", hash.RequestHash("secret", http.MethodDelete, "/api/v1/metrics/gauge/Other")} {
+		resp, err := resty.New().R().SetHeader("Hash", h).Delete(srv.URL + "/api/v1/metrics/gauge/Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	}
+
+	resp, err := resty.New().R().
+		SetHeader("Hash", hash.RequestHash("secret", http.MethodDelete, "/api/v1/metrics/gauge/Alloc")).
+		Delete(srv.URL + "/api/v1/metrics/gauge/Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+}
+
+func TestBatchHandler(t *testing.T) {
+	s := updating.NewMockService(t)
+	s.On("Updates", mock.Anything, mock.Anything).Return(nil)
+
+	srv := newMetricsServer(t, s)
+	defer srv.Close()
+
+	req := resty.New().R()
+	req.Method = http.MethodPost
+	req.URL = srv.URL + "/api/v1/metrics"
+	req.SetHeader("Content-Type", "application/json")
+	req.SetBody(`[{"id": "Alloc", "type": "gauge", "value": 1.5}, {"id": "PollCount", "type": "counter", "delta": 1}]`)
+
+	resp, err := req.Send()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.JSONEq(t, `{"applied":2,"errors":[]}`, string(resp.Body()))
+
+	resp, err = resty.New().R().SetHeader("Content-Type", "application/json").SetBody(`[]`).Post(srv.URL + "/api/v1/metrics")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	assertProblem(t, resp, problem.CodeInvalidRequest)
+
+	s.AssertNumberOfCalls(t, "Updates", 1)
+}
@@ -5,7 +5,6 @@ import (
 	"crypto/rsa"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -15,6 +14,7 @@ import (
 	"github.com/kaa-it/go-devops/internal/gzip"
 	"github.com/kaa-it/go-devops/internal/server/decrypt"
 	"github.com/kaa-it/go-devops/internal/server/hash"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
 	"github.com/kaa-it/go-devops/internal/server/updating"
 )
 
@@ -71,53 +71,48 @@ func (h *Handler) Updates(key string, privateKey *rsa.PrivateKey) http.HandlerFu
 //			@Param	    category   path       string  true "Metric type"
 //		    @Param      name       path       string  true "Metric name"
 //	        @Param      value      path       string  true "New metric value"
+//			@Produce    application/problem+json
 //			@Success	200
-//			@Failure    404        {string}   string
-//			@Failure	501        {string}   string "Metric type is not supported"
-//			@Failure    500
+//	        @Failure    400        {object}   problem.Problem "invalid_value"
+//			@Failure    409        {object}   problem.Problem "type_conflict"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
 //			@Router	    /update/{category}/{name}/{value}	[post]
 func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 	category := chi.URLParam(r, "category")
 
 	if category != "gauge" && category != "counter" {
 		h.l.Error("Metric type is not supported")
-		http.Error(w, "Metric type is not supported", http.StatusNotImplemented)
+		problem.New(http.StatusNotImplemented, problem.CodeTypeUnsupported, "Metric type is not supported").Write(w, r)
 		return
 	}
 
-	name := chi.URLParam(r, "name")
+	m := api.Metrics{ID: chi.URLParam(r, "name"), MType: api.MetricsType(category)}
 
 	valueStr := chi.URLParam(r, "value")
 
-	ctx := r.Context()
+	var err error
 
-	switch category {
-	case "gauge":
-		value, err := strconv.ParseFloat(valueStr, 64)
-		if err != nil {
-			h.l.Error("Invalid metric value")
-			http.Error(w, "Invalid metric value", http.StatusBadRequest)
-			return
-		}
+	if m.MType == api.GaugeType {
+		var value float64
+		value, err = strconv.ParseFloat(valueStr, 64)
+		m.Value = &value
+	} else {
+		var delta int64
+		delta, err = strconv.ParseInt(valueStr, 10, 64)
+		m.Delta = &delta
+	}
 
-		if err := h.a.UpdateGauge(ctx, name, value); err != nil {
-			h.l.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "counter":
-		value, err := strconv.ParseInt(valueStr, 10, 64)
-		if err != nil {
-			h.l.Error("Invalid metric value")
-			http.Error(w, "Invalid metric value", http.StatusBadRequest)
-			return
-		}
+	if err != nil {
+		h.l.Error("Invalid metric value")
+		problem.New(http.StatusBadRequest, problem.CodeInvalidValue, "Invalid metric value").Write(w, r)
+		return
+	}
 
-		if err := h.a.UpdateCounter(ctx, name, value); err != nil {
-			h.l.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if _, err := h.a.Update(r.Context(), m); err != nil {
+		h.l.Error(err.Error())
+		problem.Error(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -128,11 +123,12 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 //		    @Accept     json
 //			@Produce    json
 //			@Param	    request    body       api.Metrics  true "Metric update request"
-//			@Success	200
-//	        @Failure    400        {string}   string
-//			@Failure    404        {string}   string
-//			@Failure	501        {string}   string "Metric type is not supported"
-//			@Failure    500
+//			@Produce    application/problem+json
+//			@Success	200        {object}   api.Metrics
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value"
+//			@Failure    409        {object}   problem.Problem "type_conflict"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
 //			@Router	    /update/	[post]
 func (h *Handler) updateJSON(w http.ResponseWriter, r *http.Request) {
 	var req api.Metrics
@@ -146,59 +142,14 @@ func (h *Handler) updateJSON(w http.ResponseWriter, r *http.Request) {
 
 	if err := dec.Decode(&req); err != nil {
 		h.l.Error(fmt.Sprintf("failed decoding body for update: %v", err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
 		return
 	}
 
-	ctx := r.Context()
-
-	switch req.MType {
-	case api.GaugeType:
-		if req.Value == nil {
-			h.l.Error("metric value not found")
-			http.Error(w, "Metric value not found", http.StatusBadRequest)
-			return
-		}
-
-		if err := h.a.UpdateGauge(ctx, req.ID, *req.Value); err != nil {
-			h.l.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		val, err := h.a.Gauge(ctx, req.ID)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get %s metric: %v", req.ID, err))
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		*req.Value = val
-
-	case api.CounterType:
-		if req.Delta == nil {
-			h.l.Error("metric value not found")
-			http.Error(w, "Metric value not found", http.StatusBadRequest)
-			return
-		}
-
-		if err := h.a.UpdateCounter(ctx, req.ID, *req.Delta); err != nil {
-			h.l.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		val, err := h.a.Counter(ctx, req.ID)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get %s metric: %v", req.ID, err))
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		*req.Delta = val
-
-	default:
-		h.l.Error(fmt.Sprintf("metric type %s not supported", req.MType))
-		http.Error(w, "Metric type is not supported", http.StatusNotImplemented)
+	res, err := h.a.Update(r.Context(), req)
+	if err != nil {
+		h.l.Error(err.Error())
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -206,7 +157,7 @@ func (h *Handler) updateJSON(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	enc := json.NewEncoder(w)
-	if err := enc.Encode(req); err != nil {
+	if err := enc.Encode(res); err != nil {
 		h.l.Error(fmt.Sprintf("failed encoding body for update: %v", err))
 		return
 	}
@@ -214,45 +165,33 @@ func (h *Handler) updateJSON(w http.ResponseWriter, r *http.Request) {
 
 //			@Tags	 Update
 //			@Summary Request to update some metric value simultaneously in JSON format
+//			@Description Legacy route of POST /api/v1/metrics.
 //		    @Accept     json
 //			@Produce    json
+//			@Produce    application/problem+json
 //			@Param	    request    body       []api.Metrics  true "Batch metric update request"
-//			@Success	200
-//	        @Failure    400        {string}   string
-//			@Failure    404        {string}   string
-//			@Failure    500
+//			@Success	200        {object}   BatchReport "Applied metrics, rejected ones are listed in partial batch mode"
+//	        @Failure    400        {object}   problem.Problem "invalid_request or invalid_value with rejected metrics"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
 //			@Router	    /updates	[post]
 func (h *Handler) updates(w http.ResponseWriter, r *http.Request) {
-	var req []api.Metrics
-
-	dec := json.NewDecoder(r.Body)
-	defer func() {
-		if err := r.Body.Close(); err != nil {
-			h.l.Error(fmt.Sprintf("failed to close body: %v", err))
-		}
-	}()
-
-	if err := dec.Decode(&req); err != nil {
-		h.l.Error(fmt.Sprintf("failed decoding body for updates: %v", err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if len(req) == 0 {
-		h.l.Error("metric batch is empty")
-		http.Error(w, "Metric batch is empty", http.StatusBadRequest)
-		return
-	}
+	h.batch(w, r)
+}
 
-	log.Println(req[0].ID)
+// BatchReport describes response for applied batch.
+type BatchReport struct {
+	// Applied - amount of applied metrics.
+	Applied int `json:"applied"`
+	// Errors - rejected metrics.
+	Errors []problem.Item `json:"errors"`
+}
 
-	ctx := r.Context()
+// report writes result of applied batch.
+func (h *Handler) report(w http.ResponseWriter, res BatchReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 
-	if err := h.a.Updates(ctx, req); err != nil {
-		h.l.Error(fmt.Sprintf("batch update failed: %v", err.Error()))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error(fmt.Sprintf("failed encoding body for updates: %v", err))
 	}
-
-	w.WriteHeader(http.StatusOK)
 }
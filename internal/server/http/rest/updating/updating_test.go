@@ -3,6 +3,8 @@ package updating
 import (
 	"bytes"
 	gzipLib "compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,7 +18,9 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kaa-it/go-devops/internal/api"
 	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
 	"github.com/kaa-it/go-devops/internal/server/updating"
 )
 
@@ -24,6 +28,7 @@ func TestUpdateHandler(t *testing.T) {
 	type want struct {
 		code     int
 		response string
+		problem  string
 	}
 
 	tests := []struct {
@@ -57,14 +62,13 @@ func TestUpdateHandler(t *testing.T) {
 			},
 		},
 		{
-			name:             "metric type is not supported",
-			metricType:       "test",
-			metricName:       "test",
-			metricValue:      "4.5",
-			checkServiceCall: true,
+			name:        "metric type is not supported",
+			metricType:  "test",
+			metricName:  "test",
+			metricValue: "4.5",
 			want: want{
-				code:     http.StatusNotImplemented,
-				response: "Metric type is not supported\n",
+				code:    http.StatusNotImplemented,
+				problem: problem.CodeTypeUnsupported,
 			},
 		},
 		{
@@ -73,8 +77,8 @@ func TestUpdateHandler(t *testing.T) {
 			metricName:  "test",
 			metricValue: "ax",
 			want: want{
-				code:     http.StatusBadRequest,
-				response: "Invalid metric value\n",
+				code:    http.StatusBadRequest,
+				problem: problem.CodeInvalidValue,
 			},
 		},
 		{
@@ -83,8 +87,8 @@ func TestUpdateHandler(t *testing.T) {
 			metricName:  "test",
 			metricValue: "ax",
 			want: want{
-				code:     http.StatusBadRequest,
-				response: "Invalid metric value\n",
+				code:    http.StatusBadRequest,
+				problem: problem.CodeInvalidValue,
 			},
 		},
 	}
@@ -93,12 +97,7 @@ func TestUpdateHandler(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			s := updating.NewMockService(t)
 			if test.checkServiceCall {
-				switch test.metricType {
-				case "gauge":
-					s.On("UpdateGauge", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-				case "counter":
-					s.On("UpdateCounter", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-				}
+				s.On("Update", mock.Anything, mock.Anything).Return(api.Metrics{}, nil)
 			}
 
 			var h *Handler
@@ -136,6 +135,10 @@ func TestUpdateHandler(t *testing.T) {
 				assert.Equal(t, test.want.response, string(resp.Body()))
 			}
 
+			if test.want.problem != "" {
+				assertProblem(t, resp, test.want.problem)
+			}
+
 			if test.want.code != http.StatusOK {
 				l.AssertNumberOfCalls(t, "Error", 1)
 			}
@@ -143,15 +146,19 @@ func TestUpdateHandler(t *testing.T) {
 			l.AssertNumberOfCalls(t, "RequestLogger", 2)
 
 			if test.checkServiceCall {
+				s.AssertNumberOfCalls(t, "Update", 1)
+
+				m := s.Calls[0].Arguments.Get(1).(api.Metrics)
+				assert.Equal(t, test.metricName, m.ID)
+				assert.Equal(t, api.MetricsType(test.metricType), m.MType)
+
 				switch test.metricType {
 				case "gauge":
 					value, _ := strconv.ParseFloat(test.metricValue, 64)
-					s.AssertCalled(t, "UpdateGauge", mock.Anything, test.metricName, value)
-					s.AssertNumberOfCalls(t, "UpdateGauge", 1)
+					assert.Equal(t, value, *m.Value)
 				case "counter":
 					value, _ := strconv.ParseInt(test.metricValue, 10, 64)
-					s.AssertCalled(t, "UpdateCounter", mock.Anything, test.metricName, value)
-					s.AssertNumberOfCalls(t, "UpdateCounter", 1)
+					assert.Equal(t, value, *m.Delta)
 				}
 			}
 		})
@@ -162,6 +169,7 @@ func TestJSONUpdateHandler(t *testing.T) {
 	type want struct {
 		code     int
 		response string
+		problem  string
 	}
 
 	tests := []struct {
@@ -172,6 +180,7 @@ func TestJSONUpdateHandler(t *testing.T) {
 		startDelta       int64
 		delta            int64
 		value            float64
+		serviceErr       error
 		checkServiceCall bool
 		want             want
 	}{
@@ -201,35 +210,38 @@ func TestJSONUpdateHandler(t *testing.T) {
 			},
 		},
 		{
-			name: "metric type is not supported",
-			body: `{"id": "test", "type": "test", "delta": 45}`,
+			name:       "metric type is not supported",
+			body:       `{"id": "test", "type": "test", "delta": 45}`,
+			serviceErr: fmt.Errorf("metric %q: %w: %q", "test", updating.ErrUnsupportedType, "test"),
 			want: want{
-				code:     http.StatusNotImplemented,
-				response: "Metric type is not supported\n",
+				code:    http.StatusNotImplemented,
+				problem: problem.CodeTypeUnsupported,
 			},
 		},
 		{
-			name: "no gauge metric value",
-			body: `{"id": "test", "type": "gauge", "delta": 45}`,
+			name:       "no gauge metric value",
+			body:       `{"id": "test", "type": "gauge", "delta": 45}`,
+			serviceErr: fmt.Errorf("metric %q: %w", "test", updating.ErrMissingValue),
 			want: want{
-				code:     http.StatusBadRequest,
-				response: "Metric value not found\n",
+				code:    http.StatusBadRequest,
+				problem: problem.CodeInvalidValue,
 			},
 		},
 		{
-			name: "no counter metric value",
-			body: `{"id": "test", "type": "counter", "value": 45.2}`,
+			name:       "no counter metric value",
+			body:       `{"id": "test", "type": "counter", "value": 45.2}`,
+			serviceErr: fmt.Errorf("metric %q: %w", "test", updating.ErrMissingValue),
 			want: want{
-				code:     http.StatusBadRequest,
-				response: "Metric value not found\n",
+				code:    http.StatusBadRequest,
+				problem: problem.CodeInvalidValue,
 			},
 		},
 		{
 			name: "failed to parse request",
 			body: `{"id": "test", "type": "counter", "delta": "45""}`,
 			want: want{
-				code:     http.StatusBadRequest,
-				response: "invalid character '\"' after object key:value pair\n",
+				code:    http.StatusBadRequest,
+				problem: problem.CodeInvalidRequest,
 			},
 		},
 	}
@@ -239,11 +251,21 @@ func TestJSONUpdateHandler(t *testing.T) {
 			s := updating.NewMockService(t)
 			switch test.metricType {
 			case "gauge":
-				s.On("UpdateGauge", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-				s.On("Gauge", mock.Anything, mock.Anything, mock.Anything).Return(test.value, nil)
+				value := test.value
+				s.On("Update", mock.Anything, mock.Anything).Return(
+					api.Metrics{ID: test.metricName, MType: api.GaugeType, Value: &value},
+					nil,
+				)
 			case "counter":
-				s.On("UpdateCounter", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-				s.On("Counter", mock.Anything, mock.Anything, mock.Anything).Return(test.delta+test.startDelta, nil)
+				delta := test.delta + test.startDelta
+				s.On("Update", mock.Anything, mock.Anything).Return(
+					api.Metrics{ID: test.metricName, MType: api.CounterType, Delta: &delta},
+					nil,
+				)
+			default:
+				if test.serviceErr != nil {
+					s.On("Update", mock.Anything, mock.Anything).Return(api.Metrics{}, test.serviceErr)
+				}
 			}
 
 			var h *Handler
@@ -284,27 +306,26 @@ func TestJSONUpdateHandler(t *testing.T) {
 			}
 
 			if test.want.response != "" {
-				if test.checkServiceCall {
-					assert.JSONEq(t, test.want.response, string(resp.Body()))
-				} else {
-					assert.Equal(t, test.want.response, string(resp.Body()))
-				}
+				assert.JSONEq(t, test.want.response, string(resp.Body()))
+			}
+
+			if test.want.problem != "" {
+				assertProblem(t, resp, test.want.problem)
 			}
 
 			l.AssertNumberOfCalls(t, "RequestLogger", 2)
 
 			if test.checkServiceCall {
+				s.AssertNumberOfCalls(t, "Update", 1)
+
+				m := s.Calls[0].Arguments.Get(1).(api.Metrics)
+				assert.Equal(t, test.metricName, m.ID)
+
 				switch test.metricType {
 				case "gauge":
-					s.AssertCalled(t, "UpdateGauge", mock.Anything, test.metricName, test.value)
-					s.AssertNumberOfCalls(t, "UpdateGauge", 1)
-					s.AssertCalled(t, "Gauge", mock.Anything, test.metricName)
-					s.AssertNumberOfCalls(t, "Gauge", 1)
+					assert.Equal(t, test.value, *m.Value)
 				case "counter":
-					s.AssertCalled(t, "UpdateCounter", mock.Anything, test.metricName, test.delta)
-					s.AssertNumberOfCalls(t, "UpdateCounter", 1)
-					s.AssertCalled(t, "Counter", mock.Anything, test.metricName)
-					s.AssertNumberOfCalls(t, "Counter", 1)
+					assert.Equal(t, test.delta, *m.Delta)
 				}
 			}
 		})
@@ -316,8 +337,11 @@ func TestUpdateGzip(t *testing.T) {
 		response := `{"id": "test", "type": "gauge", "value": 45.2}`
 
 		s := updating.NewMockService(t)
-		s.On("UpdateGauge", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-		s.On("Gauge", mock.Anything, mock.Anything, mock.Anything).Return(45.2, nil)
+		value := 45.2
+		s.On("Update", mock.Anything, mock.Anything).Return(
+			api.Metrics{ID: "test", MType: api.GaugeType, Value: &value},
+			nil,
+		)
 
 		var h *Handler
 
@@ -363,18 +387,19 @@ func TestUpdateGzip(t *testing.T) {
 
 		l.AssertNumberOfCalls(t, "RequestLogger", 2)
 
-		s.AssertCalled(t, "UpdateGauge", mock.Anything, "test", 45.2)
-		s.AssertNumberOfCalls(t, "UpdateGauge", 1)
-		s.AssertCalled(t, "Gauge", mock.Anything, "test")
-		s.AssertNumberOfCalls(t, "Gauge", 1)
+		s.AssertNumberOfCalls(t, "Update", 1)
+		assert.Equal(t, 45.2, *s.Calls[0].Arguments.Get(1).(api.Metrics).Value)
 	})
 
 	t.Run("accept gzip metric", func(t *testing.T) {
 		response := `{"id": "test", "type": "gauge", "value": 45.2}`
 
 		s := updating.NewMockService(t)
-		s.On("UpdateGauge", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-		s.On("Gauge", mock.Anything, mock.Anything, mock.Anything).Return(45.2, nil)
+		value := 45.2
+		s.On("Update", mock.Anything, mock.Anything).Return(
+			api.Metrics{ID: "test", MType: api.GaugeType, Value: &value},
+			nil,
+		)
 
 		var h *Handler
 
@@ -419,9 +444,90 @@ func TestUpdateGzip(t *testing.T) {
 
 		l.AssertNumberOfCalls(t, "RequestLogger", 2)
 
-		s.AssertCalled(t, "UpdateGauge", mock.Anything, "test", 45.2)
-		s.AssertNumberOfCalls(t, "UpdateGauge", 1)
-		s.AssertCalled(t, "Gauge", mock.Anything, "test")
-		s.AssertNumberOfCalls(t, "Gauge", 1)
+		s.AssertNumberOfCalls(t, "Update", 1)
+		assert.Equal(t, 45.2, *s.Calls[0].Arguments.Get(1).(api.Metrics).Value)
 	})
 }
+
+func TestUpdatesHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceErr error
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:     "success",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "batch rejected",
+			serviceErr: &updating.BatchError{
+				Items: []updating.ItemError{{Index: 1, ID: "PollCount", Err: updating.ErrTypeConflict}},
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"type":"https://github.com/kaa-it/go-devops/problems/invalid_value","title":"Metric value is invalid",` +
+				`"status":400,"detail":"Some metrics of batch are rejected","instance":"/updates","code":"invalid_value",` +
+				`"errors":[{"index":1,"id":"PollCount","code":"type_conflict","detail":"metric type conflict"}]}`,
+		},
+		{
+			name: "batch partially applied",
+			serviceErr: &updating.BatchError{
+				Items:   []updating.ItemError{{Index: 1, ID: "PollCount", Err: updating.ErrTypeConflict}},
+				Applied: 1,
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"applied":1,"errors":[{"index":1,"id":"PollCount","code":"type_conflict","detail":"metric type conflict"}]}`,
+		},
+		{
+			name:       "storage error",
+			serviceErr: errors.New("pgx: connection refused"),
+			wantCode:   http.StatusInternalServerError,
+			wantBody: `{"type":"https://github.com/kaa-it/go-devops/problems/storage_unavailable","title":"Storage is unavailable",` +
+				`"status":500,"instance":"/updates","code":"storage_unavailable"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := updating.NewMockService(t)
+			s.On("Updates", mock.Anything, mock.Anything).Return(test.serviceErr)
+
+			l := NewMockLogger(t)
+			l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+			l.On("Error", mock.Anything).Return().Maybe()
+
+			r := chi.NewRouter()
+			r.Mount("/updates", NewHandler(s, l).Updates("", nil))
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = srv.URL + "/updates"
+			req.SetHeader("Content-Type", "application/json")
+			req.SetBody(`[{"id": "Alloc", "type": "gauge", "value": 1.5}, {"id": "PollCount", "type": "gauge", "value": 1}]`)
+
+			resp, err := req.Send()
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+
+			if test.wantBody != "" {
+				assert.JSONEq(t, test.wantBody, string(resp.Body()))
+			}
+		})
+	}
+}
+
+func assertProblem(t *testing.T, resp *resty.Response, code string) {
+	t.Helper()
+
+	var p problem.Problem
+
+	assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(resp.Body(), &p))
+	assert.Equal(t, code, p.Code)
+	assert.Equal(t, resp.StatusCode(), p.Status)
+}
@@ -0,0 +1,114 @@
+package viewing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+// ListResponse describes page of metric list.
+type ListResponse struct {
+	// Metrics - metrics of page.
+	Metrics []api.Metrics `json:"metrics"`
+	// Next - cursor of next page, absent for the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// RouteMetrics registers read handlers of /api/v1/metrics resource in router.
+//
+// Metric name is the rest of path, so names with labels may contain slashes.
+func (h *Handler) RouteMetrics(r chi.Router) {
+	r.Get("/", h.l.RequestLogger(gzip.Middleware(h.list)))
+	r.Get("/{type}/*", h.l.RequestLogger(gzip.Middleware(h.get)))
+}
+
+//			@Tags	Metrics
+//			@Summary Request to list metrics
+//			@Description Metrics are filtered by type, name prefix and regular expression, and sorted by name, type or value.
+//			@Description Ties are broken by name and type. Cursor of next page is returned in next field while pages remain.
+//			@Produce    json
+//			@Produce    application/problem+json
+//			@Param      type       query      string  false "Metric type" Enums(gauge, counter)
+//			@Param      prefix     query      string  false "Prefix of metric names"
+//			@Param      match      query      string  false "Regular expression for metric names"
+//			@Param      sort       query      string  false "Order of metrics" Enums(name, -name, type, value, -value) default(name)
+//			@Param      limit      query      int     false "Maximal amount of metrics in page" minimum(1) maximum(1000) default(100)
+//			@Param      cursor     query      string  false "Cursor of page from previous response"
+//			@Success	200        {object}   ListResponse
+//	        @Failure    400        {object}   problem.Problem "invalid_request"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/metrics	[get]
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q := viewing.Query{
+		Type:   api.MetricsType(params.Get("type")),
+		Prefix: params.Get("prefix"),
+		Match:  params.Get("match"),
+		Sort:   viewing.Sort(params.Get("sort")),
+		Cursor: params.Get("cursor"),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit <= 0 {
+			h.l.Error(fmt.Sprintf("invalid limit of metric list: %q", limit))
+			problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Limit must be positive integer").Write(w, r)
+			return
+		}
+	}
+
+	page, err := h.a.List(r.Context(), q)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to list metrics: %v", err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	h.encode(w, ListResponse{Metrics: page.Metrics, Next: page.Next})
+}
+
+//			@Tags	Metrics
+//			@Summary Request to get metric with its metadata
+//			@Produce    json
+//			@Produce    application/problem+json
+//			@Param	    type       path       string  true "Metric type" Enums(gauge, counter)
+//	        @Param      id         path       string  true "Metric name"
+//			@Success	200        {object}   api.Metrics
+//			@Failure    404        {object}   problem.Problem "metric_not_found"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /api/v1/metrics/{type}/{id}	[get]
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	mType := api.MetricsType(chi.URLParam(r, "type"))
+	name := chi.URLParam(r, "*")
+
+	m, err := h.a.Metric(r.Context(), mType, name)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get %s with name %s: %v", mType, name, err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	h.encode(w, m)
+}
+
+func (h *Handler) encode(w http.ResponseWriter, res any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error(fmt.Sprintf("failed encoding response: %v", err))
+	}
+}
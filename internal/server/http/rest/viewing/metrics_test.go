@@ -0,0 +1,144 @@
+package viewing
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+	"github.com/kaa-it/go-devops/internal/server/viewing"
+)
+
+func newMetricsServer(t *testing.T, s viewing.Service) *httptest.Server {
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(func(h http.HandlerFunc) http.HandlerFunc { return h })
+	l.On("Error", mock.Anything).Return().Maybe()
+
+	r := chi.NewRouter()
+	r.Route("/api/v1/metrics", NewHandler(s, l).RouteMetrics)
+
+	return httptest.NewServer(r)
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(b)
+}
+
+func TestListHandler(t *testing.T) {
+	value := 4.5
+
+	tests := []struct {
+		name       string
+		query      string
+		wantQuery  *viewing.Query
+		page       viewing.Page
+		serviceErr error
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:      "defaults",
+			wantQuery: &viewing.Query{},
+			page:      viewing.Page{Metrics: []api.Metrics{{ID: "Alloc", MType: api.GaugeType, Value: &value}}},
+			wantCode:  http.StatusOK,
+			wantBody:  `{"metrics":[{"id":"Alloc","type":"gauge","value":4.5}]}`,
+		},
+		{
+			name:  "all parameters",
+			query: "?type=gauge&prefix=Heap&match=Alloc%24&sort=-value&limit=1&cursor=abc",
+			wantQuery: &viewing.Query{
+				Type:   api.GaugeType,
+				Prefix: "Heap",
+				Match:  "Alloc$",
+				Sort:   viewing.SortValueDesc,
+				Limit:  1,
+				Cursor: "abc",
+			},
+			page:     viewing.Page{Metrics: []api.Metrics{}, Next: "def"},
+			wantCode: http.StatusOK,
+			wantBody: `{"metrics":[],"next":"def"}`,
+		},
+		{
+			name:     "invalid limit",
+			query:    "?limit=ten",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"type":"https://github.com/kaa-it/go-devops/problems/invalid_request","title":"Request is malformed",` +
+				`"status":400,"detail":"Limit must be positive integer","instance":"/api/v1/metrics","code":"invalid_request"}`,
+		},
+		{
+			name:       "invalid query",
+			query:      "?sort=size",
+			wantQuery:  &viewing.Query{Sort: "size"},
+			serviceErr: fmt.Errorf("%w: unsupported sort %q", viewing.ErrInvalidQuery, "size"),
+			wantCode:   http.StatusBadRequest,
+			wantBody: `{"type":"https://github.com/kaa-it/go-devops/problems/invalid_request","title":"Request is malformed",` +
+				`"status":400,"detail":"invalid query: unsupported sort \"size\"","instance":"/api/v1/metrics","code":"invalid_request"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := viewing.NewMockService(t)
+
+			if test.wantQuery != nil {
+				s.On("List", mock.Anything, *test.wantQuery).Return(test.page, test.serviceErr)
+			}
+
+			srv := newMetricsServer(t, s)
+			defer srv.Close()
+
+			resp, body := get(t, srv.URL+"/api/v1/metrics"+test.query)
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+			assert.JSONEq(t, test.wantBody, body)
+
+			if test.wantCode != http.StatusOK {
+				assert.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestGetHandler(t *testing.T) {
+	s := viewing.NewMockService(t)
+
+	value := 4.5
+	s.On("Metric", mock.Anything, api.GaugeType, "http.duration;path=/a/b").Return(
+		api.Metrics{ID: "http.duration;path=/a/b", MType: api.GaugeType, Value: &value, Metadata: &api.Metadata{Unit: "seconds"}},
+		nil,
+	)
+	s.On("Metric", mock.Anything, api.CounterType, "PollCount").Return(
+		api.Metrics{},
+		fmt.Errorf("failed to get PollCount counter: %w", memory.ErrCounterNotFound),
+	)
+
+	srv := newMetricsServer(t, s)
+	defer srv.Close()
+
+	resp, body := get(t, srv.URL+"/api/v1/metrics/gauge/http.duration;path=/a/b")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"http.duration;path=/a/b","type":"gauge","value":4.5,"metadata":{"unit":"seconds"}}`, body)
+
+	resp, body = get(t, srv.URL+"/api/v1/metrics/counter/PollCount")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.JSONEq(t, `{"type":"https://github.com/kaa-it/go-devops/problems/metric_not_found","title":"Metric not found",`+
+		`"status":404,"detail":"failed to get PollCount counter: counter not found",`+
+		`"instance":"/api/v1/metrics/counter/PollCount","code":"metric_not_found"}`, body)
+}
@@ -0,0 +1,178 @@
+package viewing
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+)
+
+// Content type of Prometheus text exposition format
+const _prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	_helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	_labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// family describes metrics with the same name and type that differ by labels.
+type family struct {
+	name     string
+	mType    api.MetricsType
+	metadata api.Metadata
+	samples  []sample
+}
+
+type sample struct {
+	labels string
+	value  string
+}
+
+// @Tags	View
+// @Summary Request to get all metrics in Prometheus text format
+// @Description Labels of metric names name;label=value become Prometheus labels, invalid characters are replaced with underscore.
+// @Description Metric description and unit are exported in HELP and UNIT comments.
+// @Produce    plain
+// @Success	200        {string}   string
+// @Failure    500        {object}   problem.Problem "storage_unavailable"
+// @Router	    /metrics	[get]
+func (h *Handler) prometheus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	gauges, err := h.a.Gauges(ctx)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get gauges: %v", err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	counters, err := h.a.Counters(ctx)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get counters: %v", err))
+		problem.Error(w, r, err)
+		return
+	}
+
+	families := make(map[string]*family)
+
+	add := func(id string, mType api.MetricsType, metadata api.Metadata, value string) {
+		name, labels := splitName(id)
+		key := string(mType) + " " + name
+
+		f, ok := families[key]
+		if !ok {
+			f = &family{name: name, mType: mType}
+			families[key] = f
+		}
+
+		// Metadata of metric without labels describes the whole family
+		if labels == "" || f.metadata.IsEmpty() {
+			f.metadata = f.metadata.Merge(metadata)
+		}
+
+		f.samples = append(f.samples, sample{labels: labels, value: value})
+	}
+
+	for _, g := range gauges {
+		add(g.Name, api.GaugeType, g.Metadata, strconv.FormatFloat(g.Value, 'g', -1, 64))
+	}
+
+	for _, c := range counters {
+		add(c.Name, api.CounterType, c.Metadata, strconv.FormatInt(c.Value, 10))
+	}
+
+	w.Header().Set("Content-Type", _prometheusContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte(formatFamilies(families))); err != nil {
+		log.Println(err)
+	}
+}
+
+func formatFamilies(families map[string]*family) string {
+	sorted := make([]*family, 0, len(families))
+	for _, f := range families {
+		sorted = append(sorted, f)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].name != sorted[j].name {
+			return sorted[i].name < sorted[j].name
+		}
+
+		return sorted[i].mType < sorted[j].mType
+	})
+
+	var b strings.Builder
+
+	for _, f := range sorted {
+		if f.metadata.Description != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", f.name, _helpReplacer.Replace(f.metadata.Description))
+		}
+
+		if f.metadata.Unit != "" {
+			fmt.Fprintf(&b, "# UNIT %s %s\n", f.name, _helpReplacer.Replace(f.metadata.Unit))
+		}
+
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.mType)
+
+		sort.Slice(f.samples, func(i, j int) bool {
+			return f.samples[i].labels < f.samples[j].labels
+		})
+
+		for _, s := range f.samples {
+			fmt.Fprintf(&b, "%s%s %s\n", f.name, s.labels, s.value)
+		}
+	}
+
+	return b.String()
+}
+
+// splitName converts metric name with labels name;label1=value1 to Prometheus name and labels {label1="value1"}.
+func splitName(id string) (string, string) {
+	parts := strings.Split(id, ";")
+
+	name := sanitize(parts[0], true)
+
+	if len(parts) == 1 {
+		return name, ""
+	}
+
+	labels := make([]string, 0, len(parts)-1)
+
+	for _, part := range parts[1:] {
+		label, value, _ := strings.Cut(part, "=")
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, sanitize(label, false), _labelValueReplacer.Replace(value)))
+	}
+
+	return name, "{" + strings.Join(labels, ",") + "}"
+}
+
+// sanitize replaces characters not allowed in Prometheus metric or label name with underscore.
+//
+// Colons are allowed only in metric names.
+func sanitize(s string, metricName bool) string {
+	var b strings.Builder
+
+	for i, c := range s {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9' && i > 0) || (c == ':' && metricName)
+
+		if valid {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/kaa-it/go-devops/internal/api"
 	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
 	"github.com/kaa-it/go-devops/internal/server/viewing"
 )
 
@@ -37,6 +38,7 @@ func (h *Handler) Route() *chi.Mux {
 	mux := chi.NewRouter()
 
 	mux.Get("/", h.l.RequestLogger(gzip.Middleware(h.home)))
+	mux.Get("/metrics", h.l.RequestLogger(gzip.Middleware(h.prometheus)))
 	mux.Post("/value/", h.l.RequestLogger(gzip.Middleware(h.valueJSON)))
 	mux.Get("/value/{category}/{name}", h.l.RequestLogger(h.value))
 
@@ -47,7 +49,7 @@ func (h *Handler) Route() *chi.Mux {
 //			@Summary Request to get HTML page with all metrics
 //			@Produce    html
 //		    @Success	200
-//		    @Failure	500
+//		    @Failure	500        {object}   problem.Problem "storage_unavailable"
 //	        @Router	    /	[get]
 func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
 	const templ = `
@@ -74,6 +76,27 @@ func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
                     >
                         Value
                     </th>
+                    <th style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+                    >
+                        Unit
+                    </th>
+                    <th style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+                    >
+                        Description
+                    </th>
+                    <th style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+                    >
+                        Owner
+                    </th>
                 </tr>
             </thead>
             <tbody>
@@ -93,6 +116,27 @@ func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
 					>
 						{{ printf "%.3f" .Value }}
 					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Unit}}
+					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Description}}
+					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Owner}}
+					</td>
               </tr>
             {{end}}
             {{range .Counters}}
@@ -111,6 +155,27 @@ func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
 					>
 						{{.Value}}
 					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Unit}}
+					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Description}}
+					</td>
+                    <td style='
+						border: 1px solid rgb(160 160 160);
+						padding: 8px 10px;
+						text-align: left'
+					>
+						{{.Metadata.Owner}}
+					</td>
               </tr>
             {{end}}
             </tbody>
@@ -122,12 +187,16 @@ func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
 
 	gauges, err := h.a.Gauges(ctx)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.l.Error(fmt.Sprintf("failed to get gauges: %v", err))
+		problem.Error(w, r, err)
+		return
 	}
 
 	counters, err := h.a.Counters(ctx)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.l.Error(fmt.Sprintf("failed to get counters: %v", err))
+		problem.Error(w, r, err)
+		return
 	}
 
 	data := struct {
@@ -151,57 +220,36 @@ func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
 //				@Produce    plain
 //		        @Param	    category   path       api.MetricsType  true "Metric type"
 //	            @Param      name       path       string  true "Metric name"
-//				@Success	200
-//				@Failure    404        {string}   string
-//				@Failure	501        {string}   string "Metric type is not supported"
-//			    @Failure    500
+//				@Produce    application/problem+json
+//				@Success	200        {string}   string
+//				@Failure    404        {object}   problem.Problem "metric_not_found"
+//				@Failure	501        {object}   problem.Problem "type_unsupported"
+//			    @Failure    500        {object}   problem.Problem "storage_unavailable"
 //				@Router	    /value/{category}/{name}	[get]
 func (h *Handler) value(w http.ResponseWriter, r *http.Request) {
-	category := chi.URLParam(r, "category")
+	category := api.MetricsType(chi.URLParam(r, "category"))
+	name := chi.URLParam(r, "name")
 
-	if category != "gauge" && category != "counter" {
-		h.l.Error(fmt.Sprintf("metric type %s is not supported", category))
-		http.Error(w, "Metric type is not supported", http.StatusNotImplemented)
+	m, err := h.a.Metric(r.Context(), category, name)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get %s with name %s: %v", category, name, err))
+		problem.Error(w, r, err)
 		return
 	}
 
-	name := chi.URLParam(r, "name")
-
-	ctx := r.Context()
-
-	switch category {
-	case "gauge":
-		value, err := h.a.Gauge(ctx, name)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get gauge with name %s: %v", name, err))
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
+	var str string
 
-		str := strconv.FormatFloat(value, 'f', -1, 64)
-
-		w.Header().Set("Content-Type", "text/plain;charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-
-		if _, err := w.Write([]byte(str)); err != nil {
-			log.Println(err)
-		}
-	case "counter":
-		value, err := h.a.Counter(ctx, name)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get counter with name %s: %v", name, err))
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		str := strconv.FormatInt(value, 10)
+	if m.MType == api.GaugeType {
+		str = strconv.FormatFloat(*m.Value, 'f', -1, 64)
+	} else {
+		str = strconv.FormatInt(*m.Delta, 10)
+	}
 
-		w.Header().Set("Content-Type", "text/plain;charset=utf-8")
-		w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "text/plain;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
 
-		if _, err := w.Write([]byte(str)); err != nil {
-			log.Println(err)
-		}
+	if _, err := w.Write([]byte(str)); err != nil {
+		log.Println(err)
 	}
 }
 
@@ -218,12 +266,13 @@ type MetricRequest struct {
 //		    @Accept     json
 //			@Produce    json
 //			@Param	    request    body       MetricRequest  true "Metric value request"
-//			@Success	200
-//	        @Failure    400        {string}   string
-//			@Failure    404        {string}   string
-//			@Failure	501        {string}   string "Metric type is not supported"
-//			@Failure    500
-//			@Router	    /value/	[get]
+//			@Produce    application/problem+json
+//			@Success	200        {object}   api.Metrics
+//	        @Failure    400        {object}   problem.Problem "invalid_request"
+//			@Failure    404        {object}   problem.Problem "metric_not_found"
+//			@Failure	501        {object}   problem.Problem "type_unsupported"
+//			@Failure    500        {object}   problem.Problem "storage_unavailable"
+//			@Router	    /value/	[post]
 func (h *Handler) valueJSON(w http.ResponseWriter, r *http.Request) {
 	var req MetricRequest
 
@@ -236,41 +285,14 @@ func (h *Handler) valueJSON(w http.ResponseWriter, r *http.Request) {
 
 	if err := dec.Decode(&req); err != nil {
 		h.l.Error(fmt.Sprintf("failed decoding body for update: %v", err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error()).Write(w, r)
 		return
 	}
 
-	ctx := r.Context()
-
-	res := api.Metrics{
-		ID:    req.ID,
-		MType: req.MType,
-	}
-
-	switch req.MType {
-	case api.GaugeType:
-		value, err := h.a.Gauge(ctx, req.ID)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get gauge with ID %s: %v", req.ID, err))
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		res.Value = &value
-
-	case api.CounterType:
-		value, err := h.a.Counter(ctx, req.ID)
-		if err != nil {
-			h.l.Error(fmt.Sprintf("failed to get counter with ID %s: %v", req.ID, err))
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		res.Delta = &value
-
-	default:
-		h.l.Error(fmt.Sprintf("metric type %s not supported", req.MType))
-		http.Error(w, "Metric type is not supported", http.StatusNotImplemented)
+	res, err := h.a.Metric(r.Context(), req.MType, req.ID)
+	if err != nil {
+		h.l.Error(fmt.Sprintf("failed to get %s with ID %s: %v", req.MType, req.ID, err))
+		problem.Error(w, r, err)
 		return
 	}
 
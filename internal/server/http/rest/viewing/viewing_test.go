@@ -2,7 +2,6 @@ package viewing
 
 import (
 	gzipLib "compress/gzip"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,10 +15,42 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kaa-it/go-devops/internal/api"
 	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/http/rest/problem"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
 	"github.com/kaa-it/go-devops/internal/server/viewing"
 )
 
+// mockMetric mocks service to return metric with value, or not found error if value is empty.
+func mockMetric(s *viewing.MockService, metricType, metricName, metricValue string) {
+	mType := api.MetricsType(metricType)
+	res := api.Metrics{ID: metricName, MType: mType}
+
+	var err error
+
+	switch {
+	case mType != api.GaugeType && mType != api.CounterType:
+		err = fmt.Errorf("%w: %q", viewing.ErrUnsupportedType, metricType)
+	case metricValue == "" && mType == api.GaugeType:
+		err = fmt.Errorf("failed to get %s gauge: %w", metricName, memory.ErrGaugeNotFound)
+	case metricValue == "":
+		err = fmt.Errorf("failed to get %s counter: %w", metricName, memory.ErrCounterNotFound)
+	case mType == api.GaugeType:
+		value, _ := strconv.ParseFloat(metricValue, 64)
+		res.Value = &value
+	default:
+		delta, _ := strconv.ParseInt(metricValue, 10, 64)
+		res.Delta = &delta
+	}
+
+	if err != nil {
+		res = api.Metrics{}
+	}
+
+	s.On("Metric", mock.Anything, mType, metricName).Return(res, err)
+}
+
 func TestViewHandler(t *testing.T) {
 	type want struct {
 		code     int
@@ -27,12 +58,11 @@ func TestViewHandler(t *testing.T) {
 	}
 
 	tests := []struct {
-		name              string
-		metricType        string
-		metricName        string
-		metricValue       string
-		checkServiceError bool
-		want              want
+		name        string
+		metricType  string
+		metricName  string
+		metricValue string
+		want        want
 	}{
 		{
 			name:        "success counter case",
@@ -59,28 +89,29 @@ func TestViewHandler(t *testing.T) {
 			metricType: "test",
 			metricName: "test",
 			want: want{
-				code:     http.StatusNotImplemented,
-				response: "Metric type is not supported\n",
+				code: http.StatusNotImplemented,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/type_unsupported", "title": "Metric type is not supported", "status": 501, ` +
+					`"detail": "unsupported metric type: \"test\"", "instance": "/value/test/test", "code": "type_unsupported"}`,
 			},
 		},
 		{
-			name:              "gauge metric not found",
-			metricType:        "gauge",
-			metricName:        "test2",
-			checkServiceError: true,
+			name:       "gauge metric not found",
+			metricType: "gauge",
+			metricName: "test2",
 			want: want{
-				code:     http.StatusNotFound,
-				response: "gauge not found\n",
+				code: http.StatusNotFound,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/metric_not_found", "title": "Metric not found", "status": 404, ` +
+					`"detail": "failed to get test2 gauge: gauge not found", "instance": "/value/gauge/test2", "code": "metric_not_found"}`,
 			},
 		},
 		{
-			name:              "counter metric not found",
-			metricType:        "counter",
-			metricName:        "test2",
-			checkServiceError: true,
+			name:       "counter metric not found",
+			metricType: "counter",
+			metricName: "test2",
 			want: want{
-				code:     http.StatusNotFound,
-				response: "counter not found\n",
+				code: http.StatusNotFound,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/metric_not_found", "title": "Metric not found", "status": 404, ` +
+					`"detail": "failed to get test2 counter: counter not found", "instance": "/value/counter/test2", "code": "metric_not_found"}`,
 			},
 		},
 	}
@@ -89,28 +120,7 @@ func TestViewHandler(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			s := viewing.NewMockService(t)
 
-			switch test.metricType {
-			case "gauge":
-				if test.checkServiceError {
-					s.On("Gauge", mock.Anything, test.metricName).Return(
-						float64(0),
-						errors.New("gauge not found"),
-					)
-				} else {
-					value, _ := strconv.ParseFloat(test.metricValue, 64)
-					s.On("Gauge", mock.Anything, test.metricName).Return(value, nil)
-				}
-			case "counter":
-				if test.checkServiceError {
-					s.On("Counter", mock.Anything, test.metricName).Return(
-						int64(0),
-						errors.New("counter not found"),
-					)
-				} else {
-					value, _ := strconv.ParseInt(test.metricValue, 10, 64)
-					s.On("Counter", mock.Anything, test.metricName).Return(value, nil)
-				}
-			}
+			mockMetric(s, test.metricType, test.metricName, test.metricValue)
 
 			var h *Handler
 
@@ -141,22 +151,21 @@ func TestViewHandler(t *testing.T) {
 			resp, err := req.Send()
 
 			assert.NoError(t, err, "error making HTTP request")
-			assert.Equal(t, test.want.response, string(resp.Body()))
+			if test.want.code == http.StatusOK {
+				assert.Equal(t, test.want.response, string(resp.Body()))
+			} else {
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+				assert.JSONEq(t, test.want.response, string(resp.Body()))
+			}
 
 			if test.want.code != http.StatusOK {
 				l.AssertNumberOfCalls(t, "Error", 1)
 			}
 
-			l.AssertNumberOfCalls(t, "RequestLogger", 3)
+			l.AssertNumberOfCalls(t, "RequestLogger", 4)
 
-			switch test.metricType {
-			case "gauge":
-				s.AssertCalled(t, "Gauge", mock.Anything, test.metricName)
-				s.AssertNumberOfCalls(t, "Gauge", 1)
-			case "counter":
-				s.AssertCalled(t, "Counter", mock.Anything, test.metricName)
-				s.AssertNumberOfCalls(t, "Counter", 1)
-			}
+			s.AssertCalled(t, "Metric", mock.Anything, api.MetricsType(test.metricType), test.metricName)
+			s.AssertNumberOfCalls(t, "Metric", 1)
 		})
 	}
 }
@@ -168,13 +177,12 @@ func TestViewJSONHandler(t *testing.T) {
 	}
 
 	tests := []struct {
-		name              string
-		body              string
-		metricType        string
-		metricName        string
-		metricValue       string
-		checkServiceError bool
-		want              want
+		name        string
+		body        string
+		metricType  string
+		metricName  string
+		metricValue string
+		want        want
 	}{
 		{
 			name:        "success counter case",
@@ -199,36 +207,36 @@ func TestViewJSONHandler(t *testing.T) {
 			},
 		},
 		{
-			name:              "metric type is not supported",
-			body:              `{"id": "test", "type": "test"}`,
-			metricType:        "test",
-			metricName:        "test",
-			checkServiceError: true,
+			name:       "metric type is not supported",
+			body:       `{"id": "test", "type": "test"}`,
+			metricType: "test",
+			metricName: "test",
 			want: want{
-				code:     http.StatusNotImplemented,
-				response: "Metric type is not supported\n",
+				code: http.StatusNotImplemented,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/type_unsupported", "title": "Metric type is not supported", "status": 501, ` +
+					`"detail": "unsupported metric type: \"test\"", "instance": "/value/", "code": "type_unsupported"}`,
 			},
 		},
 		{
-			name:              "gauge metric not found",
-			body:              `{"id": "test2", "type": "gauge"}`,
-			metricType:        "gauge",
-			metricName:        "test2",
-			checkServiceError: true,
+			name:       "gauge metric not found",
+			body:       `{"id": "test2", "type": "gauge"}`,
+			metricType: "gauge",
+			metricName: "test2",
 			want: want{
-				code:     http.StatusNotFound,
-				response: "gauge not found\n",
+				code: http.StatusNotFound,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/metric_not_found", "title": "Metric not found", "status": 404, ` +
+					`"detail": "failed to get test2 gauge: gauge not found", "instance": "/value/", "code": "metric_not_found"}`,
 			},
 		},
 		{
-			name:              "counter metric not found",
-			body:              `{"id": "test2", "type": "counter"}`,
-			metricType:        "counter",
-			metricName:        "test2",
-			checkServiceError: true,
+			name:       "counter metric not found",
+			body:       `{"id": "test2", "type": "counter"}`,
+			metricType: "counter",
+			metricName: "test2",
 			want: want{
-				code:     http.StatusNotFound,
-				response: "counter not found\n",
+				code: http.StatusNotFound,
+				response: `{"type": "https://github.com/kaa-it/go-devops/problems/metric_not_found", "title": "Metric not found", "status": 404, ` +
+					`"detail": "failed to get test2 counter: counter not found", "instance": "/value/", "code": "metric_not_found"}`,
 			},
 		},
 	}
@@ -237,28 +245,7 @@ func TestViewJSONHandler(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			s := viewing.NewMockService(t)
 
-			switch test.metricType {
-			case "gauge":
-				if test.checkServiceError {
-					s.On("Gauge", mock.Anything, test.metricName).Return(
-						float64(0),
-						errors.New("gauge not found"),
-					)
-				} else {
-					value, _ := strconv.ParseFloat(test.metricValue, 64)
-					s.On("Gauge", mock.Anything, test.metricName).Return(value, nil)
-				}
-			case "counter":
-				if test.checkServiceError {
-					s.On("Counter", mock.Anything, test.metricName).Return(
-						int64(0),
-						errors.New("counter not found"),
-					)
-				} else {
-					value, _ := strconv.ParseInt(test.metricValue, 10, 64)
-					s.On("Counter", mock.Anything, test.metricName).Return(value, nil)
-				}
-			}
+			mockMetric(s, test.metricType, test.metricName, test.metricValue)
 
 			var h *Handler
 
@@ -298,22 +285,12 @@ func TestViewJSONHandler(t *testing.T) {
 				l.AssertNumberOfCalls(t, "Error", 1)
 			}
 
-			if !test.checkServiceError {
-				assert.JSONEq(t, test.want.response, string(resp.Body()))
-			} else {
-				assert.Equal(t, test.want.response, string(resp.Body()))
-			}
+			assert.JSONEq(t, test.want.response, string(resp.Body()))
 
-			l.AssertNumberOfCalls(t, "RequestLogger", 3)
+			l.AssertNumberOfCalls(t, "RequestLogger", 4)
 
-			switch test.metricType {
-			case "gauge":
-				s.AssertCalled(t, "Gauge", mock.Anything, test.metricName)
-				s.AssertNumberOfCalls(t, "Gauge", 1)
-			case "counter":
-				s.AssertCalled(t, "Counter", mock.Anything, test.metricName)
-				s.AssertNumberOfCalls(t, "Counter", 1)
-			}
+			s.AssertCalled(t, "Metric", mock.Anything, api.MetricsType(test.metricType), test.metricName)
+			s.AssertNumberOfCalls(t, "Metric", 1)
 		})
 	}
 }
@@ -321,11 +298,15 @@ func TestViewJSONHandler(t *testing.T) {
 func TestViewJSONGzip(t *testing.T) {
 	t.Run("test view gauge gzip", func(t *testing.T) {
 		body := `{"id": "test", "type": "gauge"}`
-		response := `{"id": "test", "type": "gauge", "value": 45.2}`
+		response := `{"id": "test", "type": "gauge", "value": 45.2, "metadata": {"unit": "bytes"}}`
 
 		s := viewing.NewMockService(t)
 
-		s.On("Gauge", mock.Anything, "test").Return(45.2, nil)
+		value := 45.2
+		s.On("Metric", mock.Anything, api.GaugeType, "test").Return(
+			api.Metrics{ID: "test", MType: api.GaugeType, Value: &value, Metadata: &api.Metadata{Unit: "bytes"}},
+			nil,
+		)
 
 		var h *Handler
 
@@ -369,9 +350,59 @@ func TestViewJSONGzip(t *testing.T) {
 
 		assert.JSONEq(t, response, string(b))
 
-		l.AssertNumberOfCalls(t, "RequestLogger", 3)
+		l.AssertNumberOfCalls(t, "RequestLogger", 4)
 
-		s.AssertCalled(t, "Gauge", mock.Anything, "test")
-		s.AssertNumberOfCalls(t, "Gauge", 1)
+		s.AssertCalled(t, "Metric", mock.Anything, api.GaugeType, "test")
+		s.AssertNumberOfCalls(t, "Metric", 1)
 	})
 }
+
+func TestPrometheusHandler(t *testing.T) {
+	s := viewing.NewMockService(t)
+
+	s.On("Gauges", mock.Anything).Return([]viewing.Gauge{
+		{Name: "OtherSys", Value: 1024, Metadata: api.Metadata{Unit: "bytes", Description: "Other runtime\nallocations"}},
+		{Name: "http.duration;code=200;path=/a\"b", Value: 0.25},
+		{Name: "http.duration", Value: 0.5, Metadata: api.Metadata{Unit: "seconds"}},
+	}, nil)
+	s.On("Counters", mock.Anything).Return([]viewing.Counter{{Name: "PollCount", Value: 7}}, nil)
+
+	var h *Handler
+
+	l := NewMockLogger(t)
+	l.On("RequestLogger", mock.Anything).Return(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.prometheus(w, r)
+	}))
+
+	h = NewHandler(s, l)
+
+	r := chi.NewRouter()
+	r.Mount("/", h.Route())
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	want := `# HELP OtherSys Other runtime\nallocations
+# UNIT OtherSys bytes
+# TYPE OtherSys gauge
+OtherSys 1024
+# TYPE PollCount counter
+PollCount 7
+# UNIT http_duration seconds
+# TYPE http_duration gauge
+http_duration 0.5
+http_duration{code="200",path="/a\"b"} 0.25
+`
+
+	assert.Equal(t, want, string(body))
+}
@@ -0,0 +1,35 @@
+// Package limit contains middleware for limiting size of request body.
+package limit
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Middleware wraps request handler to reject requests with body larger than maxSize bytes.
+//
+// Requests with too large Content-Length are rejected at once, body of other requests
+// fails to read after maxSize bytes, handler may check it with TooLarge.
+// Zero maxSize disables limit.
+func Middleware(maxSize int64, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if maxSize <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > maxSize {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		h.ServeHTTP(w, r)
+	}
+}
+
+// TooLarge returns true if error is caused by reading body larger than limit.
+func TooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
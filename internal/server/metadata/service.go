@@ -0,0 +1,98 @@
+// Package metadata provides service for metric metadata context boundary.
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+// Entry describes metadata of one metric.
+type Entry struct {
+	// Name - metric name.
+	Name string `json:"name"`
+	api.Metadata
+}
+
+// Service describes methods provided by the service.
+type Service interface {
+	// SetMetadata replaces metadata of metric with given name.
+	SetMetadata(ctx context.Context, name string, m api.Metadata) error
+	// Metadata returns metadata of metric with given name.
+	Metadata(ctx context.Context, name string) (api.Metadata, error)
+	// DeleteMetadata deletes metadata of metric with given name.
+	DeleteMetadata(ctx context.Context, name string) error
+	// Entries returns metadata of all metrics sorted by name.
+	Entries(ctx context.Context) ([]Entry, error)
+}
+
+// Repository describes methods for repository that must be provided to the service.
+// The service uses this repository to keep metadata in storage.
+type Repository interface {
+	// SetMetadata replaces metadata of metric with given name in storage.
+	SetMetadata(ctx context.Context, name string, m api.Metadata) error
+	// Metadata returns metadata of metric with given name from storage.
+	Metadata(ctx context.Context, name string) (api.Metadata, error)
+	// DeleteMetadata deletes metadata of metric with given name from storage.
+	DeleteMetadata(ctx context.Context, name string) error
+	// ForEachMetadata applies given function to metadata of every metric in storage.
+	ForEachMetadata(ctx context.Context, fn func(name string, m api.Metadata)) error
+}
+
+type service struct {
+	r Repository
+}
+
+// NewService creates new service instance.
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+func (s *service) SetMetadata(ctx context.Context, name string, m api.Metadata) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.r.SetMetadata(ctx, name, m); err != nil {
+		return fmt.Errorf("failed to set metadata of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *service) Metadata(ctx context.Context, name string) (api.Metadata, error) {
+	m, err := s.r.Metadata(ctx, name)
+	if err != nil {
+		return api.Metadata{}, fmt.Errorf("failed to get metadata of %s: %w", name, err)
+	}
+
+	return m, nil
+}
+
+func (s *service) DeleteMetadata(ctx context.Context, name string) error {
+	if err := s.r.DeleteMetadata(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete metadata of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *service) Entries(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+
+	err := s.r.ForEachMetadata(ctx, func(name string, m api.Metadata) {
+		entries = append(entries, Entry{Name: name, Metadata: m})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
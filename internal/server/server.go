@@ -19,11 +19,20 @@ import (
 	"github.com/go-chi/chi/v5"
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"github.com/kaa-it/go-devops/internal/server/graphite"
+	grafanaRest "github.com/kaa-it/go-devops/internal/server/http/rest/grafana"
+	influxRest "github.com/kaa-it/go-devops/internal/server/http/rest/influx"
+	metadataRest "github.com/kaa-it/go-devops/internal/server/http/rest/metadata"
+	otlpRest "github.com/kaa-it/go-devops/internal/server/http/rest/otlp"
+	remoteWriteRest "github.com/kaa-it/go-devops/internal/server/http/rest/remotewrite"
 	serviceRest "github.com/kaa-it/go-devops/internal/server/http/rest/service"
 	updatingRest "github.com/kaa-it/go-devops/internal/server/http/rest/updating"
 	viewingRest "github.com/kaa-it/go-devops/internal/server/http/rest/viewing"
+	"github.com/kaa-it/go-devops/internal/server/limit"
 	"github.com/kaa-it/go-devops/internal/server/logger"
+	"github.com/kaa-it/go-devops/internal/server/metadata"
 	"github.com/kaa-it/go-devops/internal/server/service"
+	"github.com/kaa-it/go-devops/internal/server/statsd"
 	"github.com/kaa-it/go-devops/internal/server/storage/db"
 	"github.com/kaa-it/go-devops/internal/server/storage/memory"
 	"github.com/kaa-it/go-devops/internal/server/updating"
@@ -35,6 +44,7 @@ import (
 type Server struct {
 	config     *Config
 	privateKey *rsa.PrivateKey
+	updater    updating.Service
 }
 
 // New creates metric server instance.
@@ -63,7 +73,7 @@ func New(config *Config) (*Server, error) {
 
 // Run runs server and controls its lifecycle.
 //
-// Except metric server runs pprof server on port 7777.
+// Except metric server runs pprof server on port 7777 and configured Graphite and StatsD listeners.
 func (s *Server) Run() {
 	log, err := logger.New(s.config.Server.LogLevel)
 	if err != nil {
@@ -102,10 +112,25 @@ func (s *Server) Run() {
 		Addr: ":7777",
 	}
 
+	listenerCtx, stopListener := context.WithCancel(context.Background())
+	defer stopListener()
+
 	var wg sync.WaitGroup
 
 	wg.Add(2)
 
+	for _, listener := range s.listeners(log) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := listener.Run(listenerCtx); err != nil {
+				log.Error(fmt.Sprintf("listener failed: %s", err.Error()))
+			}
+		}()
+	}
+
 	go func() {
 		defer wg.Done()
 
@@ -131,6 +156,8 @@ func (s *Server) Run() {
 			log.Error(err.Error())
 		}
 
+		stopListener()
+
 		wg.Done()
 	}()
 
@@ -150,23 +177,66 @@ func (s *Server) Run() {
 	}
 }
 
+// limit wraps handler accepting request body with limit of request size.
+func (s *Server) limit(h http.Handler) http.HandlerFunc {
+	return limit.Middleware(s.config.Server.MaxRequestSize, h.ServeHTTP)
+}
+
+// listener describes ingestion listener running alongside HTTP server.
+type listener interface {
+	// Run listens until context is cancelled and returns after received metrics are applied.
+	Run(ctx context.Context) error
+}
+
+// listeners creates configured ingestion listeners.
+func (s *Server) listeners(log *logger.Logger) []listener {
+	var listeners []listener
+
+	if s.config.Graphite.Address != "" {
+		listeners = append(listeners, graphite.New(&s.config.Graphite, s.updater, log))
+	}
+
+	if s.config.StatsD.Address != "" {
+		listeners = append(listeners, statsd.New(&s.config.StatsD, s.updater, log))
+	}
+
+	return listeners
+}
+
 func (s *Server) initMemory(log *logger.Logger) (*chi.Mux, *memory.Storage, error) {
 	storage, err := memory.NewStorage(&s.config.Storage)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	updater := updating.NewService(storage)
+	updater := updating.NewService(storage, s.config.Server.BatchMode)
+	s.updater = updater
 	viewer := viewing.NewService(storage)
+	describer := metadata.NewService(storage)
 
 	updatingHandler := updatingRest.NewHandler(updater, log)
 	viewingHandler := viewingRest.NewHandler(viewer, log)
+	influxHandler := influxRest.NewHandler(updater, log)
+	remoteWriteHandler := remoteWriteRest.NewHandler(updater, log)
+	otlpHandler := otlpRest.NewHandler(updater, log)
+	grafanaHandler := grafanaRest.NewHandler(viewer, log)
+	metadataHandler := metadataRest.NewHandler(describer, log)
+
+	metricsRouter := chi.NewRouter()
+	viewingHandler.RouteMetrics(metricsRouter)
+	updatingHandler.RouteMetrics(metricsRouter, s.config.Server.Key, s.privateKey)
 
 	r := chi.NewRouter()
 
-	r.Mount("/update", updatingHandler.Route(s.config.Server.Key, s.privateKey))
+	r.Mount("/update", s.limit(updatingHandler.Route(s.config.Server.Key, s.privateKey)))
 	r.Mount("/", viewingHandler.Route())
-	r.Mount("/updates", updatingHandler.Updates(s.config.Server.Key, s.privateKey))
+	r.Mount("/updates", s.limit(updatingHandler.Updates(s.config.Server.Key, s.privateKey)))
+	r.Mount("/write", s.limit(influxHandler.Write(s.config.Server.Key, s.privateKey)))
+	r.Mount("/api/v1/write", s.limit(remoteWriteHandler.Write(s.config.Server.Key, s.privateKey)))
+	r.Mount("/api/v1/metrics", s.limit(metricsRouter))
+	r.Mount("/v1/metrics", s.limit(otlpHandler.Export(s.config.Server.Key, s.privateKey)))
+	r.Mount("/grafana", s.limit(grafanaHandler.Route()))
+	r.Mount("/metadata", s.limit(metadataHandler.Route(s.config.Server.Key)))
 	r.Mount("/swagger", httpSwagger.WrapHandler)
 
 	return r, storage, nil
@@ -182,20 +252,37 @@ func (s *Server) initDB(log *logger.Logger) (*chi.Mux, *db.Storage, error) {
 		return nil, nil, err
 	}
 
-	updater := updating.NewService(storage)
+	updater := updating.NewService(storage, s.config.Server.BatchMode)
+	s.updater = updater
 	viewer := viewing.NewService(storage)
+	describer := metadata.NewService(storage)
 	service := service.NewService(storage)
 
 	updatingHandler := updatingRest.NewHandler(updater, log)
 	viewingHandler := viewingRest.NewHandler(viewer, log)
+	influxHandler := influxRest.NewHandler(updater, log)
+	remoteWriteHandler := remoteWriteRest.NewHandler(updater, log)
+	otlpHandler := otlpRest.NewHandler(updater, log)
+	grafanaHandler := grafanaRest.NewHandler(viewer, log)
+	metadataHandler := metadataRest.NewHandler(describer, log)
 	serviceHandler := serviceRest.NewHandler(service, log)
 
+	metricsRouter := chi.NewRouter()
+	viewingHandler.RouteMetrics(metricsRouter)
+	updatingHandler.RouteMetrics(metricsRouter, s.config.Server.Key, s.privateKey)
+
 	r := chi.NewRouter()
 
-	r.Mount("/update", updatingHandler.Route(s.config.Server.Key, s.privateKey))
+	r.Mount("/update", s.limit(updatingHandler.Route(s.config.Server.Key, s.privateKey)))
 	r.Mount("/", viewingHandler.Route())
 	r.Mount("/ping", serviceHandler.Route())
-	r.Mount("/updates", updatingHandler.Updates(s.config.Server.Key, s.privateKey))
+	r.Mount("/updates", s.limit(updatingHandler.Updates(s.config.Server.Key, s.privateKey)))
+	r.Mount("/write", s.limit(influxHandler.Write(s.config.Server.Key, s.privateKey)))
+	r.Mount("/api/v1/write", s.limit(remoteWriteHandler.Write(s.config.Server.Key, s.privateKey)))
+	r.Mount("/api/v1/metrics", s.limit(metricsRouter))
+	r.Mount("/v1/metrics", s.limit(otlpHandler.Export(s.config.Server.Key, s.privateKey)))
+	r.Mount("/grafana", s.limit(grafanaHandler.Route()))
+	r.Mount("/metadata", s.limit(metadataHandler.Route(s.config.Server.Key)))
 	r.Mount("/swagger", httpSwagger.WrapHandler)
 
 	return r, storage, nil
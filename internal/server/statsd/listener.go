@@ -0,0 +1,304 @@
+// Package statsd contains StatsD UDP listener for metric server.
+//
+// Received metrics are aggregated over flush interval and applied to server as one batch:
+// counters are summed, gauges keep the last value with relative updates added to it,
+// timers and histograms become gauges name.min, name.max, name.mean and counter name.count,
+// sets become gauges with amount of unique members. Sample rates are taken into account
+// for counters, timers and histograms.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	statsdLib "github.com/kaa-it/go-devops/internal/statsd"
+)
+
+const (
+	_maxPacketSize = 65535
+)
+
+// Updater describes service that applies aggregated metrics.
+type Updater interface {
+	// Gauge returns gauge metric value with given name.
+	Gauge(ctx context.Context, name string) (float64, error)
+	// Updates updates some metrics simultaneously.
+	Updates(ctx context.Context, metrics []api.Metrics) error
+}
+
+// Logger describes logger used by listener.
+type Logger interface {
+	Info(args ...interface{})
+	Error(args ...interface{})
+}
+
+// Config describes configuration of StatsD listener.
+type Config struct {
+	// Address - UDP address to listen, empty value disables listener.
+	Address string
+	// FlushInterval - interval for applying aggregated metrics to server.
+	FlushInterval time.Duration
+}
+
+type timer struct {
+	count float64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// gauge describes gauge changes in one window.
+type gauge struct {
+	// value - the last absolute value, nil if there were only relative updates.
+	value *float64
+	// delta - sum of relative updates after the last absolute value.
+	delta float64
+}
+
+// Listener describes StatsD listener.
+type Listener struct {
+	config  *Config
+	updater Updater
+	log     Logger
+
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]*gauge
+	timers   map[string]*timer
+	sets     map[string]map[string]struct{}
+
+	// last contains gauge values applied by listener, it is used only by flush
+	last map[string]float64
+}
+
+// New creates new StatsD listener that applies received metrics with given updater.
+func New(config *Config, updater Updater, log Logger) *Listener {
+	l := &Listener{
+		config:  config,
+		updater: updater,
+		log:     log,
+		last:    make(map[string]float64),
+	}
+
+	l.reset()
+
+	return l
+}
+
+// Run listens configured address until context is cancelled.
+//
+// Aggregated metrics are applied before return.
+func (l *Listener) Run(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", l.config.Address)
+	if err != nil {
+		return err
+	}
+
+	l.log.Info(fmt.Sprintf("StatsD listener started on %s", conn.LocalAddr()))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		l.serve(conn)
+	}()
+
+	flushTicker := time.NewTicker(l.config.FlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			<-done
+
+			l.flush()
+
+			l.log.Info("StatsD listener terminated")
+			return nil
+		case <-flushTicker.C:
+			l.flush()
+		}
+	}
+}
+
+func (l *Listener) serve(conn net.PacketConn) {
+	buf := make([]byte, _maxPacketSize)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				l.log.Error(fmt.Sprintf("failed to read StatsD packet: %s", err))
+			}
+
+			return
+		}
+
+		l.handle(buf[:n])
+	}
+}
+
+func (l *Listener) handle(packet []byte) {
+	samples, errs := statsdLib.ParsePacket(packet)
+
+	for _, err := range errs {
+		l.log.Error(fmt.Sprintf("invalid StatsD line: %s", err))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, s := range samples {
+		l.add(s)
+	}
+}
+
+func (l *Listener) add(s statsdLib.Sample) {
+	switch s.Type {
+	case statsdLib.CounterType:
+		l.counters[s.Name] += s.Value / s.SampleRate
+	case statsdLib.GaugeType:
+		g, ok := l.gauges[s.Name]
+		if !ok {
+			g = &gauge{}
+			l.gauges[s.Name] = g
+		}
+
+		if s.Relative {
+			g.delta += s.Value
+			return
+		}
+
+		value := s.Value
+		g.value = &value
+		g.delta = 0
+	case statsdLib.TimerType, statsdLib.HistogramType:
+		weight := 1 / s.SampleRate
+
+		t, ok := l.timers[s.Name]
+		if !ok {
+			l.timers[s.Name] = &timer{
+				count: weight,
+				sum:   s.Value * weight,
+				min:   s.Value,
+				max:   s.Value,
+			}
+
+			return
+		}
+
+		t.count += weight
+		t.sum += s.Value * weight
+		t.min = math.Min(t.min, s.Value)
+		t.max = math.Max(t.max, s.Value)
+	case statsdLib.SetType:
+		members, ok := l.sets[s.Name]
+		if !ok {
+			members = make(map[string]struct{})
+			l.sets[s.Name] = members
+		}
+
+		members[s.Member] = struct{}{}
+	}
+}
+
+func (l *Listener) reset() {
+	l.counters = make(map[string]float64)
+	l.gauges = make(map[string]*gauge)
+	l.timers = make(map[string]*timer)
+	l.sets = make(map[string]map[string]struct{})
+}
+
+// flush applies metrics aggregated in current window as one batch and starts new window.
+func (l *Listener) flush() {
+	l.mu.Lock()
+	counters, gauges, timers, sets := l.counters, l.gauges, l.timers, l.sets
+	l.reset()
+	l.mu.Unlock()
+
+	var metrics []api.Metrics
+
+	for _, name := range sortedKeys(counters) {
+		metrics = append(metrics, counterMetric(name, int64(math.Round(counters[name]))))
+	}
+
+	for _, name := range sortedKeys(gauges) {
+		metrics = append(metrics, gaugeMetric(name, l.gaugeValue(name, gauges[name])))
+	}
+
+	for _, name := range sortedKeys(timers) {
+		t := timers[name]
+
+		metrics = append(metrics,
+			gaugeMetric(name+".min", t.min),
+			gaugeMetric(name+".max", t.max),
+			gaugeMetric(name+".mean", t.sum/t.count),
+			counterMetric(name+".count", int64(math.Round(t.count))),
+		)
+	}
+
+	for _, name := range sortedKeys(sets) {
+		metrics = append(metrics, gaugeMetric(name, float64(len(sets[name]))))
+	}
+
+	if len(metrics) == 0 {
+		return
+	}
+
+	// Aggregated metrics are applied even if listener is stopping
+	if err := l.updater.Updates(context.Background(), metrics); err != nil {
+		l.log.Error(fmt.Sprintf("failed to apply StatsD metrics: %s", err))
+	}
+}
+
+// gaugeValue returns new value of gauge changed in window.
+//
+// Relative updates are added to the last value applied by listener, or to the value
+// stored at server for gauge not yet seen by listener, unknown gauges start from zero.
+func (l *Listener) gaugeValue(name string, g *gauge) float64 {
+	var value float64
+
+	switch last, ok := l.last[name]; {
+	case g.value != nil:
+		value = *g.value
+	case ok:
+		value = last
+	default:
+		if stored, err := l.updater.Gauge(context.Background(), name); err == nil {
+			value = stored
+		}
+	}
+
+	value += g.delta
+	l.last[name] = value
+
+	return value
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func counterMetric(name string, delta int64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.CounterType, Delta: &delta}
+}
+
+func gaugeMetric(name string, value float64) api.Metrics {
+	return api.Metrics{ID: name, MType: api.GaugeType, Value: &value}
+}
@@ -0,0 +1,132 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+var errNotFound = errors.New("gauge not found")
+
+type updater struct {
+	mu      sync.Mutex
+	stored  map[string]float64
+	batches [][]api.Metrics
+}
+
+func (u *updater) Gauge(_ context.Context, name string) (float64, error) {
+	value, ok := u.stored[name]
+	if !ok {
+		return 0, errNotFound
+	}
+
+	return value, nil
+}
+
+func (u *updater) Updates(_ context.Context, metrics []api.Metrics) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.batches = append(u.batches, metrics)
+
+	return nil
+}
+
+func (u *updater) total() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return len(u.batches)
+}
+
+type logger struct{}
+
+func (logger) Info(_ ...interface{})  {}
+func (logger) Error(_ ...interface{}) {}
+
+func TestListener_flush(t *testing.T) {
+	u := &updater{stored: map[string]float64{"queue": 10}}
+	l := New(&Config{FlushInterval: time.Second}, u, logger{})
+
+	l.handle([]byte("requests:1|c\nrequests:2|c|@0.5\ntemp:10|g\ntemp:-3|g\ninvalid\nqueue:+5|g\nfree:-2|g"))
+	l.handle([]byte("latency:100|ms\nlatency:300|ms|@0.5\nusers:alice|s\nusers:bob|s\nusers:alice|s"))
+
+	l.flush()
+
+	require.Len(t, u.batches, 1)
+	assert.Equal(t, []api.Metrics{
+		counterMetric("requests", 5),
+		gaugeMetric("free", -2),
+		gaugeMetric("queue", 15),
+		gaugeMetric("temp", 7),
+		gaugeMetric("latency.min", 100),
+		gaugeMetric("latency.max", 300),
+		gaugeMetric("latency.mean", 700.0/3),
+		counterMetric("latency.count", 3),
+		gaugeMetric("users", 2),
+	}, u.batches[0])
+
+	// Empty window is not applied
+	l.flush()
+	require.Len(t, u.batches, 1)
+
+	// Relative updates are added to values applied before
+	l.handle([]byte("queue:-1|g\ntemp:+1|g\nrequests:1|c"))
+	l.flush()
+
+	require.Len(t, u.batches, 2)
+	assert.Equal(t, []api.Metrics{
+		counterMetric("requests", 1),
+		gaugeMetric("queue", 14),
+		gaugeMetric("temp", 8),
+	}, u.batches[1])
+}
+
+func TestListener_Run(t *testing.T) {
+	u := &updater{}
+	l := New(&Config{Address: "127.0.0.1:0", FlushInterval: 50 * time.Millisecond}, u, logger{})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := conn.LocalAddr().String()
+	require.NoError(t, conn.Close())
+
+	l.config.Address = addr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- l.Run(ctx)
+	}()
+
+	client, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Packets are resent until listener is ready
+	assert.Eventually(t, func() bool {
+		_, err := client.Write([]byte("requests:1|c"))
+		require.NoError(t, err)
+
+		return u.total() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("listener is not stopped")
+	}
+}
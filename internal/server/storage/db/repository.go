@@ -14,9 +14,11 @@ import (
 
 // Sentinel errors for database storage.
 var (
-	ErrGaugeNotFound   = errors.New("gauge not found")
-	ErrCounterNotFound = errors.New("counter not found")
-	ErrNoConfig        = errors.New("no configuration found")
+	ErrGaugeNotFound    = errors.New("gauge not found")
+	ErrCounterNotFound  = errors.New("counter not found")
+	ErrMetadataNotFound = errors.New("metadata not found")
+	ErrInvalidMetric    = errors.New("invalid metric")
+	ErrNoConfig         = errors.New("no configuration found")
 )
 
 // StorageConfig describes configuration of database storage.
@@ -24,6 +26,12 @@ type StorageConfig struct {
 	DSN string
 }
 
+// querier is implemented by connection pool and transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
 // Storage describes database storage.
 type Storage struct {
 	config *StorageConfig
@@ -75,6 +83,17 @@ func (s *Storage) Initialize(ctx context.Context) error {
 			" (name TEXT PRIMARY KEY, value BIGINT NOT NULL)",
 	)
 
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dbpool.Exec(
+		ctx,
+		"CREATE TABLE IF NOT EXISTS metadata"+
+			" (name TEXT PRIMARY KEY, unit TEXT NOT NULL, description TEXT NOT NULL,"+
+			" owner TEXT NOT NULL, type TEXT NOT NULL)",
+	)
+
 	return err
 }
 
@@ -110,6 +129,41 @@ func (s *Storage) UpdateCounter(ctx context.Context, name string, value int64) e
 	return err
 }
 
+// DeleteGauge deletes gauge metric with given name from database.
+//
+// If metric with given name is not found returns ErrGaugeNotFound.
+func (s *Storage) DeleteGauge(ctx context.Context, name string) error {
+	return s.delete(ctx, "DELETE FROM gauges WHERE name = @name", name, ErrGaugeNotFound)
+}
+
+// DeleteCounter deletes counter metric with given name from database.
+//
+// If metric with given name is not found returns ErrCounterNotFound.
+func (s *Storage) DeleteCounter(ctx context.Context, name string) error {
+	return s.delete(ctx, "DELETE FROM counters WHERE name = @name", name, ErrCounterNotFound)
+}
+
+// delete executes query deleting row with given name, notFound is returned if there is no such row.
+func (s *Storage) delete(ctx context.Context, query string, name string, notFound error) error {
+	tag, err := s.dbpool.Exec(
+		ctx,
+		query,
+		pgx.NamedArgs{
+			"name": name,
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
 // Gauge returns value of gauge metric by its name.
 //
 // If metric with given name is not found returns ErrGaugeNotFound
@@ -230,6 +284,61 @@ func (s *Storage) TotalGauges(ctx context.Context) (int, error) {
 
 // Updates does batch update of metrics in database.
 func (s *Storage) Updates(ctx context.Context, metrics []api.Metrics) error {
+	return updates(ctx, s.dbpool, metrics)
+}
+
+// UpdatesChecked updates metrics returned by check in database.
+//
+// Check gets required types of metrics with given names like Types. Types are read and metrics
+// are updated in one transaction holding advisory locks of names, so concurrent updates can not
+// store metric with both types even if it is not stored yet. Nothing is updated if check returns error.
+func (s *Storage) UpdatesChecked(
+	ctx context.Context,
+	names []string,
+	check func(types map[string]api.MetricsType) ([]api.Metrics, error),
+) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Rollback of committed transaction does nothing
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// Names are locked in order, so concurrent transactions do not deadlock
+	_, err = tx.Exec(
+		ctx,
+		"SELECT pg_advisory_xact_lock(hashtext(name))"+
+			" FROM (SELECT DISTINCT unnest(@names::TEXT[]) AS name ORDER BY name) AS names",
+		pgx.NamedArgs{
+			"names": names,
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	types, err := queryTypes(ctx, tx, names)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := check(types)
+	if err != nil {
+		return err
+	}
+
+	if err := updates(ctx, tx, metrics); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func updates(ctx context.Context, q querier, metrics []api.Metrics) error {
 	queryGauge := "INSERT INTO gauges (name, value) VALUES (@name, @value)" +
 		" ON CONFLICT (name) DO UPDATE" +
 		" SET value = EXCLUDED.value"
@@ -238,8 +347,29 @@ func (s *Storage) Updates(ctx context.Context, metrics []api.Metrics) error {
 		" ON CONFLICT (name) DO UPDATE" +
 		" SET value = EXCLUDED.value + counters.value"
 
+	// Not empty fields of reported metadata replace stored ones
+	queryMetadata := "INSERT INTO metadata (name, unit, description, owner, type)" +
+		" VALUES (@name, @unit, @description, @owner, @type)" +
+		" ON CONFLICT (name) DO UPDATE SET" +
+		" unit = COALESCE(NULLIF(EXCLUDED.unit, ''), metadata.unit)," +
+		" description = COALESCE(NULLIF(EXCLUDED.description, ''), metadata.description)," +
+		" owner = COALESCE(NULLIF(EXCLUDED.owner, ''), metadata.owner)," +
+		" type = COALESCE(NULLIF(EXCLUDED.type, ''), metadata.type)"
+
 	batch := &pgx.Batch{}
 	for _, metric := range metrics {
+		invalid := metric.MType == api.CounterType && metric.Delta == nil ||
+			metric.MType == api.GaugeType && metric.Value == nil ||
+			metric.MType != api.CounterType && metric.MType != api.GaugeType
+
+		if invalid {
+			return fmt.Errorf("%w: %q of type %q", ErrInvalidMetric, metric.ID, metric.MType)
+		}
+
+		if metric.Metadata != nil {
+			batch.Queue(queryMetadata, metadataArgs(metric.ID, *metric.Metadata))
+		}
+
 		if metric.MType == api.CounterType {
 			args := pgx.NamedArgs{
 				"name":  metric.ID,
@@ -255,10 +385,164 @@ func (s *Storage) Updates(ctx context.Context, metrics []api.Metrics) error {
 		}
 	}
 
-	results := s.dbpool.SendBatch(ctx, batch)
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	results := q.SendBatch(ctx, batch)
 	defer results.Close()
 
 	_, err := results.Exec()
 
 	return err
 }
+
+// SetMetadata replaces metadata of metric with given name in database.
+func (s *Storage) SetMetadata(ctx context.Context, name string, m api.Metadata) error {
+	_, err := s.dbpool.Exec(
+		ctx,
+		"INSERT INTO metadata (name, unit, description, owner, type)"+
+			" VALUES (@name, @unit, @description, @owner, @type)"+
+			" ON CONFLICT (name) DO UPDATE"+
+			" SET unit = EXCLUDED.unit, description = EXCLUDED.description,"+
+			" owner = EXCLUDED.owner, type = EXCLUDED.type",
+		metadataArgs(name, m),
+	)
+
+	return err
+}
+
+// Metadata returns metadata of metric by its name.
+//
+// If metadata for given name is not found returns ErrMetadataNotFound.
+func (s *Storage) Metadata(ctx context.Context, name string) (api.Metadata, error) {
+	var m api.Metadata
+
+	err := s.dbpool.QueryRow(
+		ctx,
+		"SELECT unit, description, owner, type FROM metadata WHERE name = @name",
+		pgx.NamedArgs{
+			"name": name,
+		},
+	).Scan(&m.Unit, &m.Description, &m.Owner, &m.Type)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return api.Metadata{}, ErrMetadataNotFound
+	}
+
+	return m, err
+}
+
+// DeleteMetadata deletes metadata of metric with given name from database.
+//
+// If metadata for given name is not found returns ErrMetadataNotFound.
+func (s *Storage) DeleteMetadata(ctx context.Context, name string) error {
+	tag, err := s.dbpool.Exec(
+		ctx,
+		"DELETE FROM metadata WHERE name = @name",
+		pgx.NamedArgs{
+			"name": name,
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrMetadataNotFound
+	}
+
+	return nil
+}
+
+// ForEachMetadata applies given function to metadata of every metric in database.
+func (s *Storage) ForEachMetadata(ctx context.Context, fn func(name string, m api.Metadata)) error {
+	rows, err := s.dbpool.Query(
+		ctx,
+		"SELECT name, unit, description, owner, type FROM metadata",
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var m api.Metadata
+		if err := rows.Scan(&name, &m.Unit, &m.Description, &m.Owner, &m.Type); err != nil {
+			return err
+		}
+
+		fn(name, m)
+	}
+
+	return rows.Err()
+}
+
+// Types returns required types of metrics with given names from database.
+//
+// Type is expected type from metadata if it is set, otherwise type of stored metric.
+// Unknown names and names stored with both types are absent in result.
+func (s *Storage) Types(ctx context.Context, names []string) (map[string]api.MetricsType, error) {
+	return queryTypes(ctx, s.dbpool, names)
+}
+
+func queryTypes(ctx context.Context, q querier, names []string) (map[string]api.MetricsType, error) {
+	rows, err := q.Query(
+		ctx,
+		"SELECT name, 'metadata', type FROM metadata WHERE name = ANY(@names) AND type <> ''"+
+			" UNION ALL SELECT name, 'stored', 'gauge' FROM gauges WHERE name = ANY(@names)"+
+			" UNION ALL SELECT name, 'stored', 'counter' FROM counters WHERE name = ANY(@names)",
+		pgx.NamedArgs{
+			"names": names,
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	expected := make(map[string]api.MetricsType)
+	stored := make(map[string][]api.MetricsType)
+
+	for rows.Next() {
+		var name, source string
+		var t api.MetricsType
+		if err := rows.Scan(&name, &source, &t); err != nil {
+			return nil, err
+		}
+
+		if source == "metadata" {
+			expected[name] = t
+		} else {
+			stored[name] = append(stored[name], t)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, types := range stored {
+		if _, ok := expected[name]; !ok && len(types) == 1 {
+			expected[name] = types[0]
+		}
+	}
+
+	return expected, nil
+}
+
+func metadataArgs(name string, m api.Metadata) pgx.NamedArgs {
+	return pgx.NamedArgs{
+		"name":        name,
+		"unit":        m.Unit,
+		"description": m.Description,
+		"owner":       m.Owner,
+		"type":        string(m.Type),
+	}
+}
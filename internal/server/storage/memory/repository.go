@@ -15,13 +15,16 @@ import (
 
 type gauges = map[string]float64
 type counters = map[string]int64
+type metadata = map[string]api.Metadata
 
 // Sentinel errors for in-memory storage.
 var (
-	ErrGaugeNotFound   = errors.New("gauge not found")
-	ErrCounterNotFound = errors.New("counter not found")
-	ErrNoConfig        = errors.New("no configuration found")
-	ErrInvalidConfig   = errors.New("invalid configuration")
+	ErrGaugeNotFound    = errors.New("gauge not found")
+	ErrCounterNotFound  = errors.New("counter not found")
+	ErrMetadataNotFound = errors.New("metadata not found")
+	ErrInvalidMetric    = errors.New("invalid metric")
+	ErrNoConfig         = errors.New("no configuration found")
+	ErrInvalidConfig    = errors.New("invalid configuration")
 )
 
 // StorageConfig describes configuration of in-memory storage.
@@ -37,6 +40,7 @@ type StorageConfig struct {
 type fileStorage struct {
 	Gauges   gauges   `json:"gauges"`
 	Counters counters `json:"counters"`
+	Metadata metadata `json:"metadata,omitempty"`
 }
 
 // Storage describes in-memory storage.
@@ -44,6 +48,7 @@ type Storage struct {
 	mu       sync.RWMutex
 	gauges   gauges
 	counters counters
+	metadata metadata
 	config   *StorageConfig
 	wg       sync.WaitGroup
 	done     chan struct{}
@@ -75,11 +80,17 @@ func NewStorage(config *StorageConfig) (*Storage, error) {
 
 		s.gauges = data.Gauges
 		s.counters = data.Counters
+		s.metadata = data.Metadata
 	} else {
 		s.gauges = make(gauges)
 		s.counters = make(counters)
 	}
 
+	// Backups made before metadata support have no metadata
+	if s.metadata == nil {
+		s.metadata = make(metadata)
+	}
+
 	if config.StoreInterval != 0 {
 		s.wg.Add(1)
 		go s.saver()
@@ -145,6 +156,52 @@ func (s *Storage) UpdateCounter(_ context.Context, name string, value int64) err
 
 }
 
+// DeleteGauge deletes gauge metric with given name from hashmap.
+//
+// If metric with given name is not found returns ErrGaugeNotFound.
+// May return output errors if backup enabled. Thread-safe.
+func (s *Storage) DeleteGauge(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.gauges[name]; !ok {
+		return ErrGaugeNotFound
+	}
+
+	delete(s.gauges, name)
+
+	if s.config.StoreInterval == 0 {
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteCounter deletes counter metric with given name from hashmap.
+//
+// If metric with given name is not found returns ErrCounterNotFound.
+// May return output errors if backup enabled. Thread-safe.
+func (s *Storage) DeleteCounter(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.counters[name]; !ok {
+		return ErrCounterNotFound
+	}
+
+	delete(s.counters, name)
+
+	if s.config.StoreInterval == 0 {
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ForEachGauge applies given function to every gauge metric in storage. Thread-safe.
 func (s *Storage) ForEachGauge(_ context.Context, fn func(key string, value float64)) error {
 	s.mu.RLock()
@@ -232,12 +289,48 @@ func (s *Storage) Updates(_ context.Context, metrics []api.Metrics) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.updates(metrics)
+}
+
+// UpdatesChecked updates metrics returned by check in storage. Thread-safe.
+//
+// Check gets required types of metrics with given names like Types. Types are read and metrics
+// are updated under the same lock, so concurrent updates can not store metric with both types.
+// Nothing is updated if check returns error.
+func (s *Storage) UpdatesChecked(
+	_ context.Context,
+	names []string,
+	check func(types map[string]api.MetricsType) ([]api.Metrics, error),
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics, err := check(s.types(names))
+	if err != nil {
+		return err
+	}
+
+	return s.updates(metrics)
+}
+
+func (s *Storage) updates(metrics []api.Metrics) error {
+	// Batch is checked before changes to be applied as a whole
+	for _, m := range metrics {
+		if err := checkMetric(m); err != nil {
+			return err
+		}
+	}
+
 	for _, m := range metrics {
 		if m.MType == api.CounterType {
 			s.counters[m.ID] += *m.Delta
 		} else {
 			s.gauges[m.ID] = *m.Value
 		}
+
+		if m.Metadata != nil {
+			s.metadata[m.ID] = s.metadata[m.ID].Merge(*m.Metadata)
+		}
 	}
 
 	if s.config.StoreInterval == 0 {
@@ -249,6 +342,120 @@ func (s *Storage) Updates(_ context.Context, metrics []api.Metrics) error {
 	return nil
 }
 
+// SetMetadata replaces metadata of metric with given name.
+//
+// May return output errors if backup enabled. Thread-safe.
+func (s *Storage) SetMetadata(_ context.Context, name string, m api.Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata[name] = m
+
+	if s.config.StoreInterval == 0 {
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Metadata returns metadata of metric by its name.
+//
+// If metadata for given name is not found returns ErrMetadataNotFound.
+//
+// Method is thread-safe.
+func (s *Storage) Metadata(_ context.Context, name string) (api.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.metadata[name]
+	if !ok {
+		return api.Metadata{}, ErrMetadataNotFound
+	}
+
+	return m, nil
+}
+
+// DeleteMetadata deletes metadata of metric with given name.
+//
+// If metadata for given name is not found returns ErrMetadataNotFound.
+// May return output errors if backup enabled. Thread-safe.
+func (s *Storage) DeleteMetadata(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.metadata[name]; !ok {
+		return ErrMetadataNotFound
+	}
+
+	delete(s.metadata, name)
+
+	if s.config.StoreInterval == 0 {
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachMetadata applies given function to metadata of every metric in storage. Thread-safe.
+func (s *Storage) ForEachMetadata(_ context.Context, fn func(name string, m api.Metadata)) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, m := range s.metadata {
+		fn(name, m)
+	}
+
+	return nil
+}
+
+// Types returns required types of metrics with given names. Thread-safe.
+//
+// Type is expected type from metadata if it is set, otherwise type of stored metric.
+// Unknown names and names stored with both types are absent in result.
+func (s *Storage) Types(_ context.Context, names []string) (map[string]api.MetricsType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.types(names), nil
+}
+
+func (s *Storage) types(names []string) map[string]api.MetricsType {
+	types := make(map[string]api.MetricsType, len(names))
+
+	for _, name := range names {
+		if t := s.metadata[name].Type; t != "" {
+			types[name] = t
+			continue
+		}
+
+		_, gauge := s.gauges[name]
+		_, counter := s.counters[name]
+
+		switch {
+		case gauge && !counter:
+			types[name] = api.GaugeType
+		case counter && !gauge:
+			types[name] = api.CounterType
+		}
+	}
+
+	return types
+}
+
+// checkMetric returns ErrInvalidMetric if metric has unsupported type or no value for its type.
+func checkMetric(m api.Metrics) error {
+	switch {
+	case m.MType == api.CounterType && m.Delta != nil, m.MType == api.GaugeType && m.Value != nil:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q of type %q", ErrInvalidMetric, m.ID, m.MType)
+	}
+}
+
 func (s *Storage) save() error {
 	if s.config.StoreFilePath == "" {
 		return nil
@@ -264,6 +471,7 @@ func (s *Storage) save() error {
 	data := fileStorage{
 		Gauges:   s.gauges,
 		Counters: s.counters,
+		Metadata: s.metadata,
 	}
 
 	encoder := json.NewEncoder(file)
@@ -231,3 +231,98 @@ func TestRepository_ForEachCounter(t *testing.T) {
 
 	assert.Equal(t, called, 1)
 }
+
+func TestRepository_Metadata(t *testing.T) {
+	s, err := NewStorage(&StorageConfig{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = s.Metadata(ctx, "Alloc")
+	assert.ErrorIs(t, err, ErrMetadataNotFound)
+
+	err = s.SetMetadata(ctx, "Alloc", api.Metadata{Unit: "bytes", Owner: "runtime"})
+	assert.NoError(t, err)
+
+	var value = 1.5
+
+	// Metadata in reports is merged with stored one
+	err = s.Updates(ctx, []api.Metrics{
+		{ID: "Alloc", MType: api.GaugeType, Value: &value, Metadata: &api.Metadata{Description: "Allocated heap"}},
+	})
+	assert.NoError(t, err)
+
+	m, err := s.Metadata(ctx, "Alloc")
+	assert.NoError(t, err)
+	assert.Equal(t, api.Metadata{Unit: "bytes", Description: "Allocated heap", Owner: "runtime"}, m)
+
+	all := make(map[string]api.Metadata)
+	err = s.ForEachMetadata(ctx, func(name string, m api.Metadata) {
+		all[name] = m
+	})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	assert.NoError(t, s.DeleteMetadata(ctx, "Alloc"))
+	assert.ErrorIs(t, s.DeleteMetadata(ctx, "Alloc"), ErrMetadataNotFound)
+}
+
+func TestRepository_UpdatesInvalid(t *testing.T) {
+	s, err := NewStorage(&StorageConfig{})
+	assert.NoError(t, err)
+
+	var value = 1.5
+
+	// Gauge without value must not be dereferenced, the whole batch is rejected
+	err = s.Updates(context.Background(), []api.Metrics{
+		{ID: "Alloc", MType: api.GaugeType, Value: &value},
+		{ID: "Frees", MType: api.GaugeType},
+		{ID: "Mallocs", MType: "histogram", Value: &value},
+	})
+	assert.ErrorIs(t, err, ErrInvalidMetric)
+
+	gauges, err := s.TotalGauges(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, gauges)
+}
+
+func TestRepository_Types(t *testing.T) {
+	s, err := NewStorage(&StorageConfig{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, s.UpdateGauge(ctx, "Alloc", 1.5))
+	assert.NoError(t, s.UpdateCounter(ctx, "PollCount", 1))
+	assert.NoError(t, s.UpdateGauge(ctx, "Both", 1.5))
+	assert.NoError(t, s.UpdateCounter(ctx, "Both", 1))
+	assert.NoError(t, s.SetMetadata(ctx, "Declared", api.Metadata{Type: api.CounterType}))
+	assert.NoError(t, s.SetMetadata(ctx, "Alloc", api.Metadata{Unit: "bytes"}))
+
+	types, err := s.Types(ctx, []string{"Alloc", "PollCount", "Both", "Declared", "Unknown"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]api.MetricsType{
+		"Alloc":     api.GaugeType,
+		"PollCount": api.CounterType,
+		"Declared":  api.CounterType,
+	}, types)
+}
+
+func TestRepository_Delete(t *testing.T) {
+	s, err := NewStorage(&StorageConfig{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, s.UpdateGauge(ctx, "Alloc", 1.5))
+	assert.NoError(t, s.UpdateCounter(ctx, "PollCount", 1))
+
+	assert.NoError(t, s.DeleteGauge(ctx, "Alloc"))
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Alloc"), ErrGaugeNotFound)
+	assert.ErrorIs(t, s.DeleteCounter(ctx, "Alloc"), ErrCounterNotFound)
+
+	assert.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+
+	_, err = s.Counter(ctx, "PollCount")
+	assert.ErrorIs(t, err, ErrCounterNotFound)
+}
@@ -3,10 +3,79 @@ package updating
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 
 	"github.com/kaa-it/go-devops/internal/api"
 )
 
+// BatchMode describes how batches with invalid metrics are applied.
+type BatchMode string
+
+// Supported batch modes
+const (
+	AtomicMode  BatchMode = "atomic"  // batch with invalid metrics is rejected as a whole
+	PartialMode BatchMode = "partial" // valid metrics of batch are applied, invalid ones are rejected
+)
+
+// Sentinel errors for invalid metrics.
+var (
+	ErrUnsupportedType = errors.New("unsupported metric type")
+	ErrMissingValue    = errors.New("missing metric value")
+	ErrInvalidValue    = errors.New("invalid metric value")
+	ErrTypeConflict    = errors.New("metric type conflict")
+)
+
+// ItemError describes rejected metric of batch.
+type ItemError struct {
+	// Index - position of metric in batch.
+	Index int
+	// ID - metric name.
+	ID string
+	// Err - reason of rejection.
+	Err error
+}
+
+// Error returns text of error.
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("metric %d %q: %v", e.Index, e.ID, e.Err)
+}
+
+// Unwrap returns reason of rejection.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError describes batch with rejected metrics.
+type BatchError struct {
+	// Items - rejected metrics in order of batch.
+	Items []ItemError
+	// Applied - amount of applied metrics, always zero in atomic mode.
+	Applied int
+}
+
+// Error returns text of error.
+func (e *BatchError) Error() string {
+	items := make([]string, 0, len(e.Items))
+	for i := range e.Items {
+		items = append(items, e.Items[i].Error())
+	}
+
+	return fmt.Sprintf("%d of %d metrics rejected: %s", len(e.Items), len(e.Items)+e.Applied, strings.Join(items, "; "))
+}
+
+// Unwrap returns errors of rejected metrics, so errors.Is matches their reasons.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Items))
+	for i := range e.Items {
+		errs = append(errs, &e.Items[i])
+	}
+
+	return errs
+}
+
 // Service describes methods provided by the service.
 type Service interface {
 	// UpdateGauge updates gauge metric with given name and value.
@@ -18,7 +87,13 @@ type Service interface {
 	// Counter returns counter metric value with given name.
 	Counter(ctx context.Context, name string) (int64, error)
 	// Updates updates some metrics simultaneously.
+	//
+	// Returns *BatchError if some metrics are invalid.
 	Updates(ctx context.Context, metrics []api.Metrics) error
+	// Update updates metric with its metadata and returns metric with value after update.
+	Update(ctx context.Context, m api.Metrics) (api.Metrics, error)
+	// Delete deletes metric with given type and name.
+	Delete(ctx context.Context, mType api.MetricsType, name string) error
 }
 
 // Repository describes methods for repository that must be provided to the service.
@@ -34,23 +109,45 @@ type Repository interface {
 	Counter(ctx context.Context, name string) (int64, error)
 	// Updates updates some metrics simultaneously in storage.
 	Updates(ctx context.Context, metrics []api.Metrics) error
+	// DeleteGauge deletes gauge metric with given name from storage.
+	DeleteGauge(ctx context.Context, name string) error
+	// DeleteCounter deletes counter metric with given name from storage.
+	DeleteCounter(ctx context.Context, name string) error
+	// UpdatesChecked updates metrics returned by check in storage.
+	//
+	// Check gets required types of metrics with given names. Type is expected type from metadata
+	// if it is set, otherwise type of stored metric. Unknown names and names stored with both types
+	// are absent in types. Types must be read and metrics updated atomically, so concurrent updates
+	// can not store metric with both types. Nothing is updated if check returns error.
+	UpdatesChecked(
+		ctx context.Context,
+		names []string,
+		check func(types map[string]api.MetricsType) ([]api.Metrics, error),
+	) error
 }
 
 type service struct {
-	r Repository
+	r    Repository
+	mode BatchMode
 }
 
 // NewService creates new service instance.
-func NewService(r Repository) Service {
-	return &service{r}
+//
+// Batches with invalid metrics are applied according to mode, empty mode means AtomicMode.
+func NewService(r Repository, mode BatchMode) Service {
+	if mode == "" {
+		mode = AtomicMode
+	}
+
+	return &service{r, mode}
 }
 
 func (s *service) UpdateGauge(ctx context.Context, name string, value float64) error {
-	return s.r.UpdateGauge(ctx, name, value)
+	return s.updateOne(ctx, api.Metrics{ID: name, MType: api.GaugeType, Value: &value})
 }
 
 func (s *service) UpdateCounter(ctx context.Context, name string, value int64) error {
-	return s.r.UpdateCounter(ctx, name, value)
+	return s.updateOne(ctx, api.Metrics{ID: name, MType: api.CounterType, Delta: &value})
 }
 
 func (s *service) Gauge(ctx context.Context, name string) (float64, error) {
@@ -62,5 +159,161 @@ func (s *service) Counter(ctx context.Context, name string) (int64, error) {
 }
 
 func (s *service) Updates(ctx context.Context, metrics []api.Metrics) error {
-	return s.r.Updates(ctx, metrics)
+	names := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		names = append(names, m.ID)
+	}
+
+	var batchErr BatchError
+
+	// Metrics are validated against types read in the same storage operation as update
+	err := s.r.UpdatesChecked(ctx, names, func(types map[string]api.MetricsType) ([]api.Metrics, error) {
+		valid, rejected := validate(metrics, types)
+
+		batchErr = BatchError{Items: rejected}
+
+		if len(rejected) > 0 && s.mode == AtomicMode {
+			return nil, nil
+		}
+
+		batchErr.Applied = len(valid)
+
+		return valid, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(batchErr.Items) > 0 {
+		return &batchErr
+	}
+
+	return nil
+}
+
+func (s *service) Update(ctx context.Context, m api.Metrics) (api.Metrics, error) {
+	if err := s.updateOne(ctx, m); err != nil {
+		return api.Metrics{}, err
+	}
+
+	res := api.Metrics{ID: m.ID, MType: m.MType, Metadata: m.Metadata}
+
+	switch m.MType {
+	case api.GaugeType:
+		value, err := s.r.Gauge(ctx, m.ID)
+		if err != nil {
+			return api.Metrics{}, fmt.Errorf("failed to get %s gauge: %w", m.ID, err)
+		}
+
+		res.Value = &value
+	case api.CounterType:
+		delta, err := s.r.Counter(ctx, m.ID)
+		if err != nil {
+			return api.Metrics{}, fmt.Errorf("failed to get %s counter: %w", m.ID, err)
+		}
+
+		res.Delta = &delta
+	}
+
+	return res, nil
+}
+
+func (s *service) Delete(ctx context.Context, mType api.MetricsType, name string) error {
+	var err error
+
+	switch mType {
+	case api.GaugeType:
+		err = s.r.DeleteGauge(ctx, name)
+	case api.CounterType:
+		err = s.r.DeleteCounter(ctx, name)
+	default:
+		return fmt.Errorf("metric %q: %w: %q", name, ErrUnsupportedType, mType)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", name, mType, err)
+	}
+
+	return nil
+}
+
+// updateOne updates single metric and returns reason of rejection.
+func (s *service) updateOne(ctx context.Context, m api.Metrics) error {
+	err := s.Updates(ctx, []api.Metrics{m})
+
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return fmt.Errorf("metric %q: %w", m.ID, batchErr.Items[0].Err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", m.ID, m.MType, err)
+	}
+
+	return nil
+}
+
+// validate splits batch to valid metrics and errors of rejected ones.
+//
+// Type of metric must match type required by storage and type of the first valid metric
+// with the same name in batch.
+func validate(metrics []api.Metrics, types map[string]api.MetricsType) ([]api.Metrics, []ItemError) {
+	valid := make([]api.Metrics, 0, len(metrics))
+
+	var rejected []ItemError
+
+	for i, m := range metrics {
+		if err := check(m, types); err != nil {
+			rejected = append(rejected, ItemError{Index: i, ID: m.ID, Err: err})
+			continue
+		}
+
+		if _, ok := types[m.ID]; !ok {
+			types[m.ID] = m.MType
+		}
+
+		valid = append(valid, m)
+	}
+
+	return valid, rejected
+}
+
+func check(m api.Metrics, types map[string]api.MetricsType) error {
+	if m.ID == "" {
+		return fmt.Errorf("%w: metric name is empty", ErrInvalidValue)
+	}
+
+	switch m.MType {
+	case api.GaugeType:
+		if m.Value == nil {
+			return ErrMissingValue
+		}
+
+		if math.IsNaN(*m.Value) || math.IsInf(*m.Value, 0) {
+			return fmt.Errorf("%w: %v", ErrInvalidValue, *m.Value)
+		}
+	case api.CounterType:
+		if m.Delta == nil {
+			return ErrMissingValue
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedType, m.MType)
+	}
+
+	if m.Metadata != nil {
+		if err := m.Metadata.Validate(); err != nil {
+			return err
+		}
+
+		if m.Metadata.Type != "" && m.Metadata.Type != m.MType {
+			return fmt.Errorf("%w: %s reported with expected type %s", ErrTypeConflict, m.MType, m.Metadata.Type)
+		}
+	}
+
+	if t, ok := types[m.ID]; ok && t != m.MType {
+		return fmt.Errorf("%w: %s is %s", ErrTypeConflict, m.MType, t)
+	}
+
+	return nil
 }
@@ -0,0 +1,220 @@
+package updating
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+)
+
+func newStorage(t *testing.T) *memory.Storage {
+	s, err := memory.NewStorage(&memory.StorageConfig{})
+	require.NoError(t, err)
+
+	return s
+}
+
+func gauge(id string, value float64) api.Metrics {
+	return api.Metrics{ID: id, MType: api.GaugeType, Value: &value}
+}
+
+func counter(id string, delta int64) api.Metrics {
+	return api.Metrics{ID: id, MType: api.CounterType, Delta: &delta}
+}
+
+func TestService_Updates(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        BatchMode
+		metrics     []api.Metrics
+		wantIndexes []int
+		wantErrs    []error
+		wantApplied int
+		wantGauges  int
+	}{
+		{
+			name:       "valid batch",
+			metrics:    []api.Metrics{gauge("Alloc", 1.5), counter("PollCount", 1), counter("PollCount", 2)},
+			wantGauges: 2,
+		},
+		{
+			name: "atomic mode rejects whole batch",
+			mode: AtomicMode,
+			metrics: []api.Metrics{
+				gauge("Alloc", 1.5),
+				{ID: "Frees", MType: api.GaugeType},
+				{ID: "Mallocs", MType: "histogram"},
+				gauge("Lookups", math.NaN()),
+				counter("Stored", 1),
+			},
+			wantIndexes: []int{1, 2, 3, 4},
+			wantErrs:    []error{ErrMissingValue, ErrUnsupportedType, ErrInvalidValue, ErrTypeConflict},
+			wantGauges:  1,
+		},
+		{
+			name: "partial mode applies valid metrics",
+			mode: PartialMode,
+			metrics: []api.Metrics{
+				gauge("Alloc", 1.5),
+				gauge("Lookups", math.Inf(1)),
+				counter("Alloc", 1),
+				gauge("", 1),
+			},
+			wantIndexes: []int{1, 2, 3},
+			wantErrs:    []error{ErrInvalidValue, ErrTypeConflict, ErrInvalidValue},
+			wantApplied: 1,
+			wantGauges:  2,
+		},
+		{
+			name:        "partial mode without valid metrics",
+			mode:        PartialMode,
+			metrics:     []api.Metrics{counter("Stored", 1)},
+			wantIndexes: []int{0},
+			wantErrs:    []error{ErrTypeConflict},
+			wantGauges:  1,
+		},
+		{
+			name: "metadata type conflict",
+			metrics: []api.Metrics{
+				{ID: "Sys", MType: api.GaugeType, Value: new(float64), Metadata: &api.Metadata{Type: api.CounterType}},
+			},
+			wantIndexes: []int{0},
+			wantErrs:    []error{ErrTypeConflict},
+			wantGauges:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newStorage(t)
+			ctx := context.Background()
+
+			require.NoError(t, r.UpdateGauge(ctx, "Stored", 1))
+
+			err := NewService(r, test.mode).Updates(ctx, test.metrics)
+
+			if test.wantErrs == nil {
+				assert.NoError(t, err)
+			} else {
+				var batchErr *BatchError
+				require.True(t, errors.As(err, &batchErr))
+
+				assert.Equal(t, test.wantApplied, batchErr.Applied)
+				require.Len(t, batchErr.Items, len(test.wantErrs))
+
+				for i, item := range batchErr.Items {
+					assert.Equal(t, test.wantIndexes[i], item.Index)
+					assert.ErrorIs(t, item.Err, test.wantErrs[i])
+				}
+			}
+
+			gauges, err := r.TotalGauges(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantGauges, gauges)
+		})
+	}
+}
+
+func TestService_UpdateGauge(t *testing.T) {
+	r := newStorage(t)
+	ctx := context.Background()
+	s := NewService(r, "")
+
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 1))
+
+	assert.NoError(t, s.UpdateGauge(ctx, "Alloc", 1.5))
+	assert.ErrorIs(t, s.UpdateGauge(ctx, "Lookups", math.NaN()), ErrInvalidValue)
+	assert.ErrorIs(t, s.UpdateGauge(ctx, "PollCount", 1.5), ErrTypeConflict)
+	assert.ErrorIs(t, s.UpdateCounter(ctx, "Alloc", 1), ErrTypeConflict)
+}
+
+func TestService_Update(t *testing.T) {
+	r := newStorage(t)
+	ctx := context.Background()
+	s := NewService(r, "")
+
+	_, err := s.Update(ctx, counter("PollCount", 2))
+	require.NoError(t, err)
+
+	res, err := s.Update(ctx, counter("PollCount", 3))
+	require.NoError(t, err)
+	require.NotNil(t, res.Delta)
+	assert.Equal(t, int64(5), *res.Delta)
+
+	_, err = s.Update(ctx, gauge("PollCount", 1.5))
+	assert.ErrorIs(t, err, ErrTypeConflict)
+
+	_, err = s.Update(ctx, api.Metrics{ID: "Alloc", MType: api.GaugeType})
+	assert.ErrorIs(t, err, ErrMissingValue)
+
+	_, err = s.Update(ctx, gauge("", 1.5))
+	assert.ErrorIs(t, err, ErrInvalidValue)
+}
+
+func TestService_Delete(t *testing.T) {
+	r := newStorage(t)
+	ctx := context.Background()
+	s := NewService(r, "")
+
+	require.NoError(t, r.UpdateGauge(ctx, "Alloc", 1.5))
+
+	assert.ErrorIs(t, s.Delete(ctx, "histogram", "Alloc"), ErrUnsupportedType)
+	assert.ErrorIs(t, s.Delete(ctx, api.CounterType, "Alloc"), memory.ErrCounterNotFound)
+	assert.NoError(t, s.Delete(ctx, api.GaugeType, "Alloc"))
+	assert.ErrorIs(t, s.Delete(ctx, api.GaugeType, "Alloc"), memory.ErrGaugeNotFound)
+}
+
+// slowRepository widens window between reading types and update, so races of storage are exposed.
+type slowRepository struct {
+	*memory.Storage
+}
+
+func (r slowRepository) UpdatesChecked(
+	ctx context.Context,
+	names []string,
+	check func(types map[string]api.MetricsType) ([]api.Metrics, error),
+) error {
+	return r.Storage.UpdatesChecked(ctx, names, func(types map[string]api.MetricsType) ([]api.Metrics, error) {
+		time.Sleep(time.Millisecond)
+		return check(types)
+	})
+}
+
+func TestService_Updates_concurrent(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		r := newStorage(t)
+		s := NewService(slowRepository{r}, AtomicMode)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+
+		errs := make([]error, 2)
+
+		for j, m := range []api.Metrics{gauge("Alloc", 1), counter("Alloc", 1)} {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				errs[j] = s.Updates(ctx, []api.Metrics{m})
+			}()
+		}
+
+		wg.Wait()
+
+		// Exactly one of updates is applied, the other one sees stored type
+		require.NotEqual(t, errs[0] == nil, errs[1] == nil, "errors: %v", errs)
+		assert.ErrorIs(t, errors.Join(errs...), ErrTypeConflict)
+
+		_, gaugeErr := r.Gauge(ctx, "Alloc")
+		_, counterErr := r.Counter(ctx, "Alloc")
+		assert.NotEqual(t, gaugeErr == nil, counterErr == nil)
+	}
+}
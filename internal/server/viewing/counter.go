@@ -1,7 +1,10 @@
 package viewing
 
+import "github.com/kaa-it/go-devops/internal/api"
+
 // Counter describes counter metric.
 type Counter struct {
-	Name  string
-	Value int64
+	Name     string
+	Value    int64
+	Metadata api.Metadata
 }
@@ -1,7 +1,10 @@
 package viewing
 
+import "github.com/kaa-it/go-devops/internal/api"
+
 // Gauge describes gauge metric.
 type Gauge struct {
-	Name  string
-	Value float64
+	Name     string
+	Value    float64
+	Metadata api.Metadata
 }
@@ -0,0 +1,156 @@
+package viewing
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+// Limits of metric list page
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Sentinel errors for metric queries.
+var (
+	ErrUnsupportedType = errors.New("unsupported metric type")
+	ErrInvalidQuery    = errors.New("invalid query")
+)
+
+// Sort describes order of metric list.
+type Sort string
+
+// Supported orders, ties are broken by name and type
+const (
+	SortName      Sort = "name"
+	SortNameDesc  Sort = "-name"
+	SortType      Sort = "type"
+	SortValue     Sort = "value"
+	SortValueDesc Sort = "-value"
+)
+
+// Query describes filter, order and page of metric list.
+type Query struct {
+	// Type - type of metrics, empty means all types.
+	Type api.MetricsType
+	// Prefix - required prefix of metric names.
+	Prefix string
+	// Match - regular expression metric names must match.
+	Match string
+	// Sort - order of metrics, empty means SortName.
+	Sort Sort
+	// Limit - maximal amount of metrics in page, zero means DefaultLimit.
+	Limit int
+	// Cursor - position after the last metric of previous page, empty means the first page.
+	Cursor string
+}
+
+// Page describes page of metric list.
+type Page struct {
+	// Metrics - metrics of page.
+	Metrics []api.Metrics
+	// Next - cursor of next page, empty for the last page.
+	Next string
+}
+
+// key describes position of metric in list, it is encoded to cursor.
+type key struct {
+	Sort  Sort            `json:"s"`
+	Type  api.MetricsType `json:"t"`
+	Name  string          `json:"n"`
+	Value float64         `json:"v"`
+}
+
+// compare returns negative number if k is before o in order of k.Sort, zero if they are equal.
+func (k key) compare(o key) int {
+	var c int
+
+	switch k.Sort {
+	case SortNameDesc:
+		c = strings.Compare(o.Name, k.Name)
+	case SortType:
+		c = strings.Compare(string(k.Type), string(o.Type))
+	case SortValue:
+		c = cmp.Compare(k.Value, o.Value)
+	case SortValueDesc:
+		c = cmp.Compare(o.Value, k.Value)
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	if c = strings.Compare(k.Name, o.Name); c != 0 {
+		return c
+	}
+
+	return strings.Compare(string(k.Type), string(o.Type))
+}
+
+func (k key) encode() string {
+	b, _ := json.Marshal(k)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string, sort Sort) (*key, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	var k key
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	if k.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor is issued for sort %q", ErrInvalidQuery, k.Sort)
+	}
+
+	return &k, nil
+}
+
+// normalize sets defaults of query and checks it.
+func (q *Query) normalize() (*regexp.Regexp, error) {
+	if q.Type != "" && q.Type != api.GaugeType && q.Type != api.CounterType {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedType, q.Type)
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = SortName
+	case SortName, SortNameDesc, SortType, SortValue, SortValueDesc:
+	default:
+		return nil, fmt.Errorf("%w: unsupported sort %q", ErrInvalidQuery, q.Sort)
+	}
+
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultLimit
+	case q.Limit < 0 || q.Limit > MaxLimit:
+		return nil, fmt.Errorf("%w: limit must be from 1 to %d, got %d", ErrInvalidQuery, MaxLimit, q.Limit)
+	}
+
+	if q.Match == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(q.Match)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	return re, nil
+}
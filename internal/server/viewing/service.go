@@ -4,6 +4,10 @@ package viewing
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kaa-it/go-devops/internal/api"
 )
 
 // Service describes methods provided by the service.
@@ -16,6 +20,12 @@ type Service interface {
 	Gauges(ctx context.Context) ([]Gauge, error)
 	// Counters returns all counter metrics.
 	Counters(ctx context.Context) ([]Counter, error)
+	// Metadata returns metadata of metric with given name.
+	Metadata(ctx context.Context, name string) (api.Metadata, error)
+	// Metric returns metric with given type and name with its metadata.
+	Metric(ctx context.Context, mType api.MetricsType, name string) (api.Metrics, error)
+	// List returns page of metrics filtered and sorted by query.
+	List(ctx context.Context, q Query) (Page, error)
 }
 
 // Repository describes methods for repository that must be provided to the service.
@@ -33,6 +43,10 @@ type Repository interface {
 	TotalGauges(ctx context.Context) (int, error)
 	// TotalCounters returns total amount of counter metrics in storage.
 	TotalCounters(ctx context.Context) (int, error)
+	// Metadata returns metadata of metric with given name.
+	Metadata(ctx context.Context, name string) (api.Metadata, error)
+	// ForEachMetadata applies given function to metadata of every metric in storage.
+	ForEachMetadata(ctx context.Context, fn func(name string, m api.Metadata)) error
 }
 
 type service struct {
@@ -68,12 +82,18 @@ func (s *service) Gauges(ctx context.Context) ([]Gauge, error) {
 		return nil, fmt.Errorf("failed to get total gauges: %w", err)
 	}
 
+	metadata, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	gauges := make([]Gauge, 0, total)
 
 	err = s.r.ForEachGauge(ctx, func(key string, value float64) {
 		gauges = append(gauges, Gauge{
-			Name:  key,
-			Value: value,
+			Name:     key,
+			Value:    value,
+			Metadata: metadata[key],
 		})
 	})
 
@@ -90,12 +110,18 @@ func (s *service) Counters(ctx context.Context) ([]Counter, error) {
 		return nil, fmt.Errorf("failed to get total counters: %w", err)
 	}
 
+	metadata, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	counters := make([]Counter, 0, total)
 
 	err = s.r.ForEachCounter(ctx, func(key string, value int64) {
 		counters = append(counters, Counter{
-			Name:  key,
-			Value: value,
+			Name:     key,
+			Value:    value,
+			Metadata: metadata[key],
 		})
 	})
 
@@ -105,3 +131,137 @@ func (s *service) Counters(ctx context.Context) ([]Counter, error) {
 
 	return counters, nil
 }
+
+func (s *service) Metadata(ctx context.Context, name string) (api.Metadata, error) {
+	m, err := s.r.Metadata(ctx, name)
+	if err != nil {
+		return api.Metadata{}, fmt.Errorf("failed to get metadata of %s: %w", name, err)
+	}
+
+	return m, nil
+}
+
+func (s *service) Metric(ctx context.Context, mType api.MetricsType, name string) (api.Metrics, error) {
+	res := api.Metrics{ID: name, MType: mType}
+
+	switch mType {
+	case api.GaugeType:
+		value, err := s.Gauge(ctx, name)
+		if err != nil {
+			return api.Metrics{}, err
+		}
+
+		res.Value = &value
+	case api.CounterType:
+		delta, err := s.Counter(ctx, name)
+		if err != nil {
+			return api.Metrics{}, err
+		}
+
+		res.Delta = &delta
+	default:
+		return api.Metrics{}, fmt.Errorf("%w: %q", ErrUnsupportedType, mType)
+	}
+
+	// Metadata is optional, so metric without it is returned as is
+	if m, err := s.r.Metadata(ctx, name); err == nil {
+		res.Metadata = &m
+	}
+
+	return res, nil
+}
+
+func (s *service) List(ctx context.Context, q Query) (Page, error) {
+	re, err := q.normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return Page{}, err
+	}
+
+	type item struct {
+		key    key
+		metric api.Metrics
+	}
+
+	var items []item
+
+	add := func(m api.Metrics, value float64, metadata api.Metadata) {
+		if !strings.HasPrefix(m.ID, q.Prefix) || (re != nil && !re.MatchString(m.ID)) {
+			return
+		}
+
+		if !metadata.IsEmpty() {
+			m.Metadata = &metadata
+		}
+
+		items = append(items, item{key{q.Sort, m.MType, m.ID, value}, m})
+	}
+
+	if q.Type == "" || q.Type == api.GaugeType {
+		gauges, err := s.Gauges(ctx)
+		if err != nil {
+			return Page{}, err
+		}
+
+		for _, g := range gauges {
+			value := g.Value
+			add(api.Metrics{ID: g.Name, MType: api.GaugeType, Value: &value}, value, g.Metadata)
+		}
+	}
+
+	if q.Type == "" || q.Type == api.CounterType {
+		counters, err := s.Counters(ctx)
+		if err != nil {
+			return Page{}, err
+		}
+
+		for _, c := range counters {
+			delta := c.Value
+			add(api.Metrics{ID: c.Name, MType: api.CounterType, Delta: &delta}, float64(delta), c.Metadata)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key.compare(items[j].key) < 0
+	})
+
+	// Keyset pagination keeps pages stable when metrics are added or deleted between requests
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return after.compare(items[i].key) < 0
+		})
+	}
+
+	end := min(start+q.Limit, len(items))
+
+	page := Page{Metrics: make([]api.Metrics, 0, end-start)}
+	for _, it := range items[start:end] {
+		page.Metrics = append(page.Metrics, it.metric)
+	}
+
+	if end < len(items) {
+		page.Next = items[end-1].key.encode()
+	}
+
+	return page, nil
+}
+
+// metadata returns metadata of all metrics by their names.
+func (s *service) metadata(ctx context.Context) (map[string]api.Metadata, error) {
+	metadata := make(map[string]api.Metadata)
+
+	err := s.r.ForEachMetadata(ctx, func(name string, m api.Metadata) {
+		metadata[name] = m
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,138 @@
+package viewing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/api"
+	"github.com/kaa-it/go-devops/internal/server/storage/memory"
+)
+
+func newService(t *testing.T) Service {
+	r, err := memory.NewStorage(&memory.StorageConfig{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, r.UpdateGauge(ctx, "HeapAlloc", 300))
+	require.NoError(t, r.UpdateGauge(ctx, "HeapIdle", 100))
+	require.NoError(t, r.UpdateGauge(ctx, "Alloc", 200))
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 5))
+	require.NoError(t, r.SetMetadata(ctx, "Alloc", api.Metadata{Unit: "bytes"}))
+
+	return NewService(r)
+}
+
+func names(page Page) []string {
+	res := make([]string, 0, len(page.Metrics))
+	for _, m := range page.Metrics {
+		res = append(res, m.ID)
+	}
+
+	return res
+}
+
+func TestService_List(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{
+			name: "all metrics by name",
+			want: []string{"Alloc", "HeapAlloc", "HeapIdle", "PollCount"},
+		},
+		{
+			name:  "type filter",
+			query: Query{Type: api.CounterType},
+			want:  []string{"PollCount"},
+		},
+		{
+			name:  "prefix",
+			query: Query{Prefix: "Heap", Sort: SortNameDesc},
+			want:  []string{"HeapIdle", "HeapAlloc"},
+		},
+		{
+			name:  "regular expression",
+			query: Query{Match: "Alloc$", Sort: SortValueDesc},
+			want:  []string{"HeapAlloc", "Alloc"},
+		},
+		{
+			name:  "by value",
+			query: Query{Sort: SortValue},
+			want:  []string{"PollCount", "HeapIdle", "Alloc", "HeapAlloc"},
+		},
+		{
+			name:  "by type",
+			query: Query{Sort: SortType},
+			want:  []string{"PollCount", "Alloc", "HeapAlloc", "HeapIdle"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := newService(t).List(context.Background(), test.query)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, names(page))
+			assert.Empty(t, page.Next)
+		})
+	}
+}
+
+func TestService_List_pages(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	page, err := s.List(ctx, Query{Sort: SortValue, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PollCount", "HeapIdle", "Alloc"}, names(page))
+	assert.Equal(t, &api.Metadata{Unit: "bytes"}, page.Metrics[2].Metadata)
+	require.NotEmpty(t, page.Next)
+
+	next, err := s.List(ctx, Query{Sort: SortValue, Limit: 3, Cursor: page.Next})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HeapAlloc"}, names(next))
+	assert.Empty(t, next.Next)
+
+	_, err = s.List(ctx, Query{Sort: SortName, Cursor: page.Next})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestService_List_invalid(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	for _, q := range []Query{
+		{Sort: "size"},
+		{Limit: MaxLimit + 1},
+		{Match: "("},
+		{Cursor: "!"},
+	} {
+		_, err := s.List(ctx, q)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	}
+
+	_, err := s.List(ctx, Query{Type: "histogram"})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestService_Metric(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	m, err := s.Metric(ctx, api.GaugeType, "Alloc")
+	require.NoError(t, err)
+	require.NotNil(t, m.Value)
+	assert.Equal(t, 200.0, *m.Value)
+	assert.Equal(t, &api.Metadata{Unit: "bytes"}, m.Metadata)
+
+	_, err = s.Metric(ctx, api.CounterType, "Alloc")
+	assert.ErrorIs(t, err, memory.ErrCounterNotFound)
+
+	_, err = s.Metric(ctx, "histogram", "Alloc")
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
@@ -0,0 +1,138 @@
+// Package statsd contains parser for StatsD protocol.
+//
+// Every line of StatsD packet has format <name>:<value>|<type>[|@<sample rate>].
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type describes type of StatsD metric.
+type Type string
+
+// Supported StatsD metric types
+const (
+	CounterType   Type = "c"  // counter metric type
+	GaugeType     Type = "g"  // gauge metric type
+	TimerType     Type = "ms" // timer metric type
+	HistogramType Type = "h"  // histogram metric type
+	SetType       Type = "s"  // set metric type
+)
+
+// Sentinel errors for StatsD parser.
+var (
+	ErrInvalidFormat     = errors.New("invalid format")
+	ErrEmptyName         = errors.New("empty metric name")
+	ErrInvalidValue      = errors.New("invalid metric value")
+	ErrInvalidSampleRate = errors.New("invalid sample rate")
+	ErrUnsupportedType   = errors.New("unsupported metric type")
+)
+
+// Sample describes one parsed StatsD line.
+type Sample struct {
+	// Name - metric name.
+	Name string
+	// Type - metric type.
+	Type Type
+	// Value - numeric value of metric, for set metric is zero.
+	Value float64
+	// Member - value of set metric, for other metric types is empty.
+	Member string
+	// Relative - true if gauge value has explicit sign and must be added to current value.
+	Relative bool
+	// SampleRate - rate the sample was sent with, in range (0, 1].
+	SampleRate float64
+}
+
+// Parse parses one line of StatsD packet.
+func Parse(line string) (Sample, error) {
+	sections := strings.Split(line, "|")
+	if len(sections) < 2 || len(sections) > 3 {
+		return Sample{}, fmt.Errorf("%w: %q", ErrInvalidFormat, line)
+	}
+
+	sep := strings.LastIndexByte(sections[0], ':')
+	if sep == -1 {
+		return Sample{}, fmt.Errorf("%w: %q", ErrInvalidFormat, line)
+	}
+
+	s := Sample{
+		Name:       sections[0][:sep],
+		Type:       Type(sections[1]),
+		SampleRate: 1,
+	}
+
+	if s.Name == "" {
+		return Sample{}, ErrEmptyName
+	}
+
+	if strings.ContainsAny(s.Name, " \t\r\n") {
+		return Sample{}, fmt.Errorf("%w: %q", ErrInvalidFormat, s.Name)
+	}
+
+	value := sections[0][sep+1:]
+
+	switch s.Type {
+	case SetType:
+		if value == "" {
+			return Sample{}, ErrInvalidValue
+		}
+
+		s.Member = value
+	case CounterType, GaugeType, TimerType, HistogramType:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Sample{}, fmt.Errorf("%w: %q", ErrInvalidValue, value)
+		}
+
+		s.Value = v
+		s.Relative = s.Type == GaugeType && (value[0] == '+' || value[0] == '-')
+	default:
+		return Sample{}, fmt.Errorf("%w: %q", ErrUnsupportedType, sections[1])
+	}
+
+	if len(sections) == 3 {
+		rate, ok := strings.CutPrefix(sections[2], "@")
+		if !ok {
+			return Sample{}, fmt.Errorf("%w: %q", ErrInvalidFormat, sections[2])
+		}
+
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || !(r > 0 && r <= 1) {
+			return Sample{}, fmt.Errorf("%w: %q", ErrInvalidSampleRate, rate)
+		}
+
+		s.SampleRate = r
+	}
+
+	return s, nil
+}
+
+// ParsePacket parses all lines of StatsD packet.
+//
+// Returns successfully parsed samples and errors for invalid lines. Empty lines are skipped.
+func ParsePacket(packet []byte) ([]Sample, []error) {
+	var samples []Sample
+	var errs []error
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		s, err := Parse(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, errs
+}
@@ -0,0 +1,170 @@
+package statsd
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Sample
+		wantErr error
+	}{
+		{
+			name: "counter",
+			line: "requests:1|c",
+			want: Sample{Name: "requests", Type: CounterType, Value: 1, SampleRate: 1},
+		},
+		{
+			name: "counter with sample rate",
+			line: "requests:3|c|@0.1",
+			want: Sample{Name: "requests", Type: CounterType, Value: 3, SampleRate: 0.1},
+		},
+		{
+			name: "gauge",
+			line: "temperature:3.2|g",
+			want: Sample{Name: "temperature", Type: GaugeType, Value: 3.2, SampleRate: 1},
+		},
+		{
+			name: "relative gauge increment",
+			line: "temperature:+4|g",
+			want: Sample{Name: "temperature", Type: GaugeType, Value: 4, Relative: true, SampleRate: 1},
+		},
+		{
+			name: "relative gauge decrement",
+			line: "temperature:-4|g",
+			want: Sample{Name: "temperature", Type: GaugeType, Value: -4, Relative: true, SampleRate: 1},
+		},
+		{
+			name: "timer",
+			line: "latency:320|ms|@0.5",
+			want: Sample{Name: "latency", Type: TimerType, Value: 320, SampleRate: 0.5},
+		},
+		{
+			name: "histogram",
+			line: "size:12.5|h",
+			want: Sample{Name: "size", Type: HistogramType, Value: 12.5, SampleRate: 1},
+		},
+		{
+			name: "set",
+			line: "users:alice|s",
+			want: Sample{Name: "users", Type: SetType, Member: "alice", SampleRate: 1},
+		},
+		{
+			name: "name with colon",
+			line: "app:requests:1|c",
+			want: Sample{Name: "app:requests", Type: CounterType, Value: 1, SampleRate: 1},
+		},
+		{
+			name:    "no type",
+			line:    "requests:1",
+			wantErr: ErrInvalidFormat,
+		},
+		{
+			name:    "no value",
+			line:    "requests|c",
+			wantErr: ErrInvalidFormat,
+		},
+		{
+			name:    "empty name",
+			line:    ":1|c",
+			wantErr: ErrEmptyName,
+		},
+		{
+			name:    "invalid value",
+			line:    "requests:abc|c",
+			wantErr: ErrInvalidValue,
+		},
+		{
+			name:    "not finite value",
+			line:    "requests:NaN|g",
+			wantErr: ErrInvalidValue,
+		},
+		{
+			name:    "unsupported type",
+			line:    "requests:1|x",
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "zero sample rate",
+			line:    "requests:1|c|@0",
+			wantErr: ErrInvalidSampleRate,
+		},
+		{
+			name:    "sample rate above one",
+			line:    "requests:1|c|@2",
+			wantErr: ErrInvalidSampleRate,
+		},
+		{
+			name:    "sample rate without prefix",
+			line:    "requests:1|c|0.5",
+			wantErr: ErrInvalidFormat,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse(test.line)
+
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, s)
+		})
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	samples, errs := ParsePacket([]byte("a:1|c\r\n\nb:2|g\nbad\nc:3|ms\n"))
+
+	assert.Len(t, samples, 3)
+	assert.Len(t, errs, 1)
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"requests:1|c",
+		"requests:3|c|@0.1",
+		"temperature:-3.2|g",
+		"latency:320|ms|@0.5",
+		"users:alice|s",
+		":|",
+		"a:b:c|@|",
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		s, err := Parse(line)
+		if err != nil {
+			return
+		}
+
+		if s.Name == "" || strings.ContainsAny(s.Name, "|\n") {
+			t.Fatalf("invalid name %q for line %q", s.Name, line)
+		}
+
+		if !(s.SampleRate > 0 && s.SampleRate <= 1) {
+			t.Fatalf("invalid sample rate %v for line %q", s.SampleRate, line)
+		}
+
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			t.Fatalf("not finite value for line %q", line)
+		}
+
+		if s.Type == SetType && s.Member == "" {
+			t.Fatalf("empty set member for line %q", line)
+		}
+	})
+}
@@ -0,0 +1,429 @@
+// Package client contains client for pushing metrics to metric server and reading them back.
+//
+// Batches are sent to /updates/ as gzip compressed JSON. If key is set, request body is signed
+// with HMAC-SHA256 in Hash header. If public key is set, compressed body is encrypted with RSA
+// before signing. Metric names may carry labels in format name;label1=value1;label2=value2.
+// Metrics rejected by server in partial batch mode are returned as *RejectedError.
+//
+// Client is safe for concurrent use.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaa-it/go-devops/internal/api"
+)
+
+// Metrics describes one metric.
+type Metrics = api.Metrics
+
+// MetricsType describes type of metric.
+type MetricsType = api.MetricsType
+
+// Metadata describes unit, description, owner and expected type of metric.
+type Metadata = api.Metadata
+
+// Supported metric types
+const (
+	GaugeType   = api.GaugeType   // gauge metric type
+	CounterType = api.CounterType // counter metric type
+)
+
+// Transports for requests to server
+const (
+	HTTPTransport  = "http"
+	HTTPSTransport = "https"
+)
+
+const _defaultTimeout = 30 * time.Second
+
+var (
+	// ErrInvalidConfig is returned for invalid client configuration.
+	ErrInvalidConfig = errors.New("invalid client configuration")
+	// ErrNotFound is returned by Get if server has no metric with given name and type.
+	ErrNotFound = errors.New("metric not found")
+)
+
+// StatusError describes unsuccessful response of server.
+type StatusError struct {
+	// StatusCode - HTTP status code of response.
+	StatusCode int
+	// RetryAfter - delay requested by server in Retry-After header, zero if absent.
+	RetryAfter time.Duration
+}
+
+// Error returns text of error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
+
+// NewStatusError creates error for response with given status code and Retry-After header value.
+func NewStatusError(statusCode int, retryAfter string) *StatusError {
+	return &StatusError{
+		StatusCode: statusCode,
+		RetryAfter: parseRetryAfter(retryAfter, time.Now()),
+	}
+}
+
+// RejectedMetric describes metric of batch rejected by server.
+type RejectedMetric struct {
+	// Index - index of metric in batch.
+	Index int `json:"index"`
+	// ID - metric name.
+	ID string `json:"id"`
+	// Code - stable error code, for example type_conflict.
+	Code string `json:"code"`
+	// Detail - description of error.
+	Detail string `json:"detail"`
+}
+
+// RejectedError describes batch partially applied by server.
+//
+// Batch should not be sent again, otherwise applied counters are increased twice.
+type RejectedError struct {
+	// Applied - amount of applied metrics.
+	Applied int
+	// Rejected - rejected metrics.
+	Rejected []RejectedMetric
+}
+
+// Error returns text of error.
+func (e *RejectedError) Error() string {
+	items := make([]string, 0, len(e.Rejected))
+	for _, m := range e.Rejected {
+		items = append(items, fmt.Sprintf("%s (%s)", m.ID, m.Code))
+	}
+
+	return fmt.Sprintf("%d metrics of batch are rejected, %d metrics are applied: %s",
+		len(e.Rejected), e.Applied, strings.Join(items, ", "))
+}
+
+// BatchInfo describes encoded batch of metrics.
+type BatchInfo struct {
+	// Metrics - amount of metrics in batch.
+	Metrics int
+	// Bytes - size of JSON encoded batch.
+	Bytes int
+	// GzipBytes - size of compressed batch.
+	GzipBytes int
+	// Encryption - duration of encryption, zero if public key is not set.
+	Encryption time.Duration
+}
+
+// Config describes configuration of client.
+type Config struct {
+	// Address - address of metric server as host:port.
+	Address string
+	// Transport - protocol of requests, http or https. Empty value means http.
+	Transport string
+	// Key - key for signing requests, empty value disables signing.
+	Key string
+	// PublicKey - public RSA key of server for encrypting requests, nil disables encryption.
+	PublicKey *rsa.PublicKey
+	// HTTPClient - client for requests, client with 30 seconds timeout is used if nil.
+	HTTPClient *http.Client
+	// OnBatch - optional callback called for every encoded batch before it is sent.
+	OnBatch func(info BatchInfo)
+}
+
+// Client describes client of metric server.
+type Client struct {
+	config  Config
+	baseURL string
+}
+
+// New creates new client.
+func New(config *Config) (*Client, error) {
+	c := &Client{config: *config}
+
+	if c.config.Address == "" {
+		return nil, fmt.Errorf("%w: empty address", ErrInvalidConfig)
+	}
+
+	if c.config.Transport == "" {
+		c.config.Transport = HTTPTransport
+	}
+
+	if c.config.Transport != HTTPTransport && c.config.Transport != HTTPSTransport {
+		return nil, fmt.Errorf("%w: unsupported transport %q", ErrInvalidConfig, c.config.Transport)
+	}
+
+	if c.config.HTTPClient == nil {
+		c.config.HTTPClient = &http.Client{Timeout: _defaultTimeout}
+	}
+
+	c.baseURL = fmt.Sprintf("%s://%s", c.config.Transport, c.config.Address)
+
+	return c, nil
+}
+
+// UpdateGauge sets value of gauge.
+func (c *Client) UpdateGauge(ctx context.Context, name string, value float64) error {
+	return c.Batch(ctx, []Metrics{{ID: name, MType: GaugeType, Value: &value}})
+}
+
+// AddCounter adds delta to counter.
+func (c *Client) AddCounter(ctx context.Context, name string, delta int64) error {
+	return c.Batch(ctx, []Metrics{{ID: name, MType: CounterType, Delta: &delta}})
+}
+
+// Batch sends batch of metrics with one request.
+//
+// Unsuccessful response is returned as *StatusError, partially applied batch is returned as *RejectedError.
+func (c *Client) Batch(ctx context.Context, metrics []Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	url := c.baseURL + "/updates/"
+
+	body, info, err := c.encode(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics for %s: %w", url, err)
+	}
+
+	if c.config.OnBatch != nil {
+		c.config.OnBatch(info)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+
+	if c.config.PublicKey != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Header.Set("Content-Encoding", "gzip")
+
+	if c.config.Key != "" {
+		req.Header.Set("Hash", c.hash(body))
+	}
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request for %s: %w", url, err)
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := NewStatusError(resp.StatusCode, resp.Header.Get("Retry-After"))
+		return fmt.Errorf("failed to send request for %s: %w", url, statusErr)
+	}
+
+	var report struct {
+		Applied int              `json:"applied"`
+		Errors  []RejectedMetric `json:"errors"`
+	}
+
+	// Body of response is not checked if it is not a batch report, for example of older server
+	if err := json.NewDecoder(resp.Body).Decode(&report); err == nil && len(report.Errors) > 0 {
+		return fmt.Errorf("failed to send request for %s: %w", url, &RejectedError{Applied: report.Applied, Rejected: report.Errors})
+	}
+
+	return nil
+}
+
+// Get returns metric with given type and name.
+//
+// Returns ErrNotFound if server has no such metric.
+func (c *Client) Get(ctx context.Context, mType MetricsType, name string) (*Metrics, error) {
+	url := c.baseURL + "/value/"
+
+	body, err := json.Marshal(Metrics{ID: name, MType: mType})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request for %s: %w", url, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request for %s: %w", url, err)
+	}
+
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s %s", ErrNotFound, mType, name)
+	default:
+		statusErr := NewStatusError(resp.StatusCode, resp.Header.Get("Retry-After"))
+		return nil, fmt.Errorf("failed to get metric from %s: %w", url, statusErr)
+	}
+
+	var m Metrics
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", url, err)
+	}
+
+	return &m, nil
+}
+
+// encode returns compressed and encrypted body of batch request.
+func (c *Client) encode(metrics []Metrics) ([]byte, BatchInfo, error) {
+	info := BatchInfo{Metrics: len(metrics)}
+
+	buf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(buf)
+	cw := &countingWriter{w: zw}
+
+	if err := json.NewEncoder(cw).Encode(metrics); err != nil {
+		return nil, info, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, info, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	info.Bytes = cw.n
+	info.GzipBytes = buf.Len()
+
+	if c.config.PublicKey == nil {
+		return buf.Bytes(), info, nil
+	}
+
+	start := time.Now()
+
+	body, err := c.encrypt(buf.Bytes())
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to encrypt message: %w", err)
+	}
+
+	info.Encryption = time.Since(start)
+
+	return body, info, nil
+}
+
+// encrypt encrypts message by chunks fitting RSA key size.
+func (c *Client) encrypt(msg []byte) ([]byte, error) {
+	encrypted := make([]byte, 0, len(msg))
+
+	step := c.config.PublicKey.Size() - 11
+	total := len(msg)
+
+	for start := 0; start < total; start += step {
+		finish := min(start+step, total)
+
+		cipher, err := rsa.EncryptPKCS1v15(rand.Reader, c.config.PublicKey, msg[start:finish])
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted = append(encrypted, cipher...)
+	}
+
+	return encrypted, nil
+}
+
+func (c *Client) hash(msg []byte) string {
+	h := hmac.New(sha256.New, []byte(c.config.Key))
+	h.Write(msg)
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// LoadPublicKey reads public RSA key of server from PEM file.
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	publicKeyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyBlock, _ := pem.Decode(publicKeyPEM)
+	if publicKeyBlock == nil {
+		return nil, fmt.Errorf("wrong key format")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(publicKeyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("wrong key format")
+	}
+
+	return pubKey, nil
+}
+
+// LoadCACert reads CA certificates from PEM file to verify server.
+func LoadCACert(path string) (*x509.CertPool, error) {
+	certPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// countingWriter counts bytes written to underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+
+	return n, err
+}
+
+// closeBody reads the rest of response body to reuse connection and closes it.
+func closeBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+
+		return time.Duration(secs) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
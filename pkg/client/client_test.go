@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaa-it/go-devops/internal/gzip"
+	"github.com/kaa-it/go-devops/internal/server/decrypt"
+	"github.com/kaa-it/go-devops/internal/server/hash"
+)
+
+// newTestServer creates server decoding batches with the same middlewares as metric server.
+func newTestServer(t *testing.T, key string, privateKey *rsa.PrivateKey, received *[]Metrics) *httptest.Server {
+	updates := func(w http.ResponseWriter, r *http.Request) {
+		var metrics []Metrics
+		if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		*received = append(*received, metrics...)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/updates/", hash.Middleware(key, decrypt.Middleware(privateKey, gzip.Middleware(updates))))
+	mux.HandleFunc("/value/", func(w http.ResponseWriter, r *http.Request) {
+		var req Metrics
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		if req.ID != "Alloc" || req.MType != GaugeType {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		value := 1.5
+		_ = json.NewEncoder(w).Encode(Metrics{ID: req.ID, MType: req.MType, Value: &value})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_Batch(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		key        string
+		privateKey *rsa.PrivateKey
+	}{
+		{name: "plain"},
+		{name: "signed", key: "secret"},
+		{name: "encrypted and signed", key: "secret", privateKey: privateKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received []Metrics
+
+			server := newTestServer(t, test.key, test.privateKey, &received)
+
+			config := &Config{
+				Address: strings.TrimPrefix(server.URL, "http://"),
+				Key:     test.key,
+			}
+
+			if test.privateKey != nil {
+				config.PublicKey = &test.privateKey.PublicKey
+			}
+
+			var batches []BatchInfo
+
+			config.OnBatch = func(info BatchInfo) {
+				batches = append(batches, info)
+			}
+
+			c, err := New(config)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+
+			require.NoError(t, c.UpdateGauge(ctx, "Alloc", 1.5))
+			require.NoError(t, c.AddCounter(ctx, "PollCount", 3))
+
+			require.Len(t, received, 2)
+			assert.Equal(t, 1.5, *received[0].Value)
+			assert.Equal(t, int64(3), *received[1].Delta)
+
+			require.Len(t, batches, 2)
+			assert.Equal(t, 1, batches[0].Metrics)
+			assert.Positive(t, batches[0].Bytes)
+			assert.Equal(t, test.privateKey != nil, batches[0].Encryption > 0)
+		})
+	}
+}
+
+func TestClient_Batch_statusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c, err := New(&Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+
+	err = c.AddCounter(context.Background(), "PollCount", 1)
+
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(t, 3*time.Second, statusErr.RetryAfter)
+}
+
+func TestClient_Batch_rejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"applied": 1, "errors": [{"index": 1, "id": "PollCount", "code": "type_conflict", "detail": "metric type conflict"}]}`))
+	}))
+	defer server.Close()
+
+	c, err := New(&Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+
+	delta := int64(1)
+	value := 1.5
+
+	err = c.Batch(context.Background(), []Metrics{
+		{ID: "Alloc", MType: GaugeType, Value: &value},
+		{ID: "PollCount", MType: CounterType, Delta: &delta},
+	})
+
+	var rejectedErr *RejectedError
+	require.True(t, errors.As(err, &rejectedErr), "got %v", err)
+	assert.Equal(t, 1, rejectedErr.Applied)
+	assert.Equal(t, []RejectedMetric{{Index: 1, ID: "PollCount", Code: "type_conflict", Detail: "metric type conflict"}}, rejectedErr.Rejected)
+	assert.ErrorContains(t, err, "PollCount (type_conflict)")
+}
+
+func TestClient_Get(t *testing.T) {
+	var received []Metrics
+
+	server := newTestServer(t, "", nil, &received)
+
+	c, err := New(&Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+
+	m, err := c.Get(context.Background(), GaugeType, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, *m.Value)
+
+	_, err = c.Get(context.Background(), CounterType, "Alloc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&Config{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = New(&Config{Address: "localhost:8080", Transport: "ftp"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, 10*time.Second, parseRetryAfter("Mon, 01 Jan 2024 00:00:10 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
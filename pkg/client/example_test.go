@@ -0,0 +1,30 @@
+package client_test
+
+import (
+	"context"
+	"log"
+
+	"github.com/kaa-it/go-devops/pkg/client"
+)
+
+func Example() {
+	c, err := client.New(&client.Config{
+		Address: "localhost:8080",
+		Key:     "secret",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	if err := c.AddCounter(ctx, "orders;region=eu", 1); err != nil {
+		log.Println(err)
+	}
+
+	value := 42.5
+
+	if err := c.Batch(ctx, []client.Metrics{{ID: "cart.total", MType: client.GaugeType, Value: &value}}); err != nil {
+		log.Println(err)
+	}
+}
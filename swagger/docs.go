@@ -31,7 +31,742 @@ const docTemplate = `{
                         "description": "OK"
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/metrics": {
+            "get": {
+                "description": "Metrics are filtered by type, name prefix and regular expression, and sorted by name, type or value.\nTies are broken by name and type. Cursor of next page is returned in next field while pages remain.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Request to list metrics",
+                "parameters": [
+                    {
+                        "enum": [
+                            "gauge",
+                            "counter"
+                        ],
+                        "type": "string",
+                        "description": "Metric type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prefix of metric names",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression for metric names",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "name",
+                            "-name",
+                            "type",
+                            "value",
+                            "-value"
+                        ],
+                        "type": "string",
+                        "default": "name",
+                        "description": "Order of metrics",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximal amount of metrics in page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of page from previous response",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/viewing.ListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Invalid metrics are listed in errors of response. In atomic batch mode the whole batch is rejected,\nin partial mode valid metrics are applied and status is 200 if any of them is applied.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Request to update some metrics simultaneously",
+                "parameters": [
+                    {
+                        "description": "Batch metric update request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Metrics"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Applied metrics, rejected ones are listed in partial batch mode",
+                        "schema": {
+                            "$ref": "#/definitions/updating.BatchReport"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value with rejected metrics",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/metrics/{type}/{id}": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Request to get metric with its metadata",
+                "parameters": [
+                    {
+                        "enum": [
+                            "gauge",
+                            "counter"
+                        ],
+                        "type": "string",
+                        "description": "Metric type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Metrics"
+                        }
+                    },
+                    "404": {
+                        "description": "metric_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Gauge value replaces current one, counter delta is added to current value.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Request to put metric value",
+                "parameters": [
+                    {
+                        "enum": [
+                            "gauge",
+                            "counter"
+                        ],
+                        "type": "string",
+                        "description": "Metric type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Metric value",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/updating.ValueRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Metric after update",
+                        "schema": {
+                            "$ref": "#/definitions/api.Metrics"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "type_conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Metadata of metric is kept, it is managed with /metadata routes.",
+                "produces": [
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Request to delete metric",
+                "parameters": [
+                    {
+                        "enum": [
+                            "gauge",
+                            "counter"
+                        ],
+                        "type": "string",
+                        "description": "Metric type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 of method and path, required if server key is set",
+                        "name": "Hash",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "metric_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/write": {
+            "post": {
+                "description": "Body is snappy compressed protobuf WriteRequest. Counter families from metadata\nand series with _total, _bucket or _count suffixes are counters, other series are gauges.\nCounter values are cumulative, server adds their increase since previous request.",
+                "consumes": [
+                    "application/x-protobuf"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Request to write metrics with Prometheus remote write protocol",
+                "parameters": [
+                    {
+                        "description": "Snappy compressed WriteRequest",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value with rejected metrics",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "request_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/": {
+            "get": {
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Request to test Grafana datasource connection",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/grafana/annotations": {
+            "post": {
+                "description": "Server does not keep events, so list of annotations is always empty.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Request to get annotations for Grafana datasource",
+                "parameters": [
+                    {
+                        "description": "Annotations request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/grafana.AnnotationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/grafana.Annotation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "request_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/query": {
+            "post": {
+                "description": "Time series target is metric name, time series contains current value at the end of range.\nTable target is part of metric name, table contains name, type, value, unit and description of matched metrics.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Request to query metrics for Grafana datasource",
+                "parameters": [
+                    {
+                        "description": "Query request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/grafana.QueryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/grafana.TimeSeries"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "request_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/grafana/search": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Grafana"
+                ],
+                "summary": "Request to search metric names for Grafana datasource",
+                "parameters": [
+                    {
+                        "description": "Search request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/grafana.SearchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "request_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/metadata/": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Request to get metadata of all metrics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/metadata.Entry"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/metadata/{name}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Request to get metadata of metric",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Metadata"
+                        }
+                    },
+                    "404": {
+                        "description": "metadata_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Metadata replaces previous one, type is expected metric type: gauge, counter or empty.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Request to set metadata of metric",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Metric metadata",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.Metadata"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Request to delete metadata of metric",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 of method and path, required if server key is set",
+                        "name": "Hash",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "metadata_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Labels of metric names name;label=value become Prometheus labels, invalid characters are replaced with underscore.\nMetric description and unit are exported in HELP and UNIT comments.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "View"
+                ],
+                "summary": "Request to get all metrics in Prometheus text format",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
@@ -58,7 +793,8 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Update"
@@ -77,27 +813,33 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Metrics"
+                        }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "invalid_request or invalid_value",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "type_conflict",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "501": {
-                        "description": "Metric type is not supported",
+                        "description": "type_unsupported",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
@@ -105,6 +847,9 @@ const docTemplate = `{
         },
         "/update/{category}/{name}/{value}": {
             "post": {
+                "produces": [
+                    "application/problem+json"
+                ],
                 "tags": [
                     "Update"
                 ],
@@ -136,79 +881,147 @@ const docTemplate = `{
                     "200": {
                         "description": "OK"
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "invalid_value",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "type_conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/updates": {
+            "post": {
+                "description": "Legacy route of POST /api/v1/metrics.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Request to update some metric value simultaneously in JSON format",
+                "parameters": [
+                    {
+                        "description": "Batch metric update request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Metrics"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Applied metrics, rejected ones are listed in partial batch mode",
+                        "schema": {
+                            "$ref": "#/definitions/updating.BatchReport"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value with rejected metrics",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error"
-                    },
-                    "501": {
-                        "description": "Metric type is not supported",
+                        "description": "storage_unavailable",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
             }
         },
-        "/updates": {
+        "/v1/metrics": {
             "post": {
+                "description": "Body is ExportMetricsServiceRequest in protobuf or JSON encoding. Monotonic sums are counters,\ncumulative values are converted to increase since previous request. Other sums and gauges are gauges.\nHistograms become counters name.count and name.bucket with label le and gauges name.sum, name.min and name.max.\nResource and data point attributes become labels.",
                 "consumes": [
-                    "application/json"
-                ],
-                "produces": [
+                    "application/x-protobuf",
                     "application/json"
                 ],
                 "tags": [
                     "Update"
                 ],
-                "summary": "Request to update some metric value simultaneously in JSON format",
+                "summary": "Request to export metrics with OpenTelemetry OTLP/HTTP protocol",
                 "parameters": [
                     {
-                        "description": "Batch metric update request",
+                        "description": "ExportMetricsServiceRequest",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Metrics"
-                            }
+                            "type": "string"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "ExportMetricsServiceResponse, partial_success has amount of rejected data points",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "invalid_request or invalid_value with rejected metrics",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "413": {
+                        "description": "request_too_large",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
         },
         "/value/": {
-            "get": {
+            "post": {
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "View"
@@ -227,27 +1040,33 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Metrics"
+                        }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "invalid_request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "metric_not_found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "501": {
-                        "description": "Metric type is not supported",
+                        "description": "type_unsupported",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
@@ -256,7 +1075,8 @@ const docTemplate = `{
         "/value/{category}/{name}": {
             "get": {
                 "produces": [
-                    "text/plain"
+                    "text/plain",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "View"
@@ -284,28 +1104,105 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "metric_not_found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "501": {
-                        "description": "Metric type is not supported",
+                        "description": "type_unsupported",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/write": {
+            "post": {
+                "description": "Integer fields and fields with _total or _count suffix are counters, other numeric fields are gauges.\nMetric name is measurement and field key joined with dot, tags become labels.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Request to update metrics in InfluxDB line protocol",
+                "parameters": [
+                    {
+                        "description": "Lines of line protocol",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "type": "string"
                         }
                     }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid_request or invalid_value with rejected metrics",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "request_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "storage_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
                 }
             }
         }
     },
     "definitions": {
+        "api.Metadata": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "Description - human readable description of metric.",
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Owner - team or person responsible for metric.",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type - expected metric type, empty if any type is allowed.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.MetricsType"
+                        }
+                    ]
+                },
+                "unit": {
+                    "description": "Unit - unit of metric value, for example bytes or seconds.",
+                    "type": "string"
+                }
+            }
+        },
         "api.Metrics": {
             "type": "object",
             "properties": {
@@ -317,6 +1214,14 @@ const docTemplate = `{
                     "description": "ID - unique metric name.",
                     "type": "string"
                 },
+                "metadata": {
+                    "description": "Metadata - optional description of metric, stored at server and merged with known one.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Metadata"
+                        }
+                    ]
+                },
                 "type": {
                     "description": "MType - metric type.",
                     "allOf": [
@@ -346,6 +1251,253 @@ const docTemplate = `{
                 "CounterType"
             ]
         },
+        "grafana.Annotation": {
+            "type": "object",
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "grafana.AnnotationRequest": {
+            "type": "object",
+            "properties": {
+                "annotation": {
+                    "type": "object",
+                    "properties": {
+                        "name": {
+                            "type": "string"
+                        },
+                        "query": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "range": {
+                    "$ref": "#/definitions/grafana.Range"
+                }
+            }
+        },
+        "grafana.QueryRequest": {
+            "type": "object",
+            "properties": {
+                "range": {
+                    "$ref": "#/definitions/grafana.Range"
+                },
+                "targets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/grafana.Target"
+                    }
+                }
+            }
+        },
+        "grafana.Range": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "grafana.SearchRequest": {
+            "type": "object",
+            "properties": {
+                "target": {
+                    "description": "Target - part of metric name to search, empty value matches all metrics.",
+                    "type": "string"
+                }
+            }
+        },
+        "grafana.Target": {
+            "type": "object",
+            "properties": {
+                "refId": {
+                    "description": "RefID - identifier of target in panel.",
+                    "type": "string"
+                },
+                "target": {
+                    "description": "Target - metric name for time series, part of metric name for table.",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type - type of response, timeserie or table.",
+                    "type": "string"
+                }
+            }
+        },
+        "grafana.TimeSeries": {
+            "type": "object",
+            "properties": {
+                "datapoints": {
+                    "description": "DataPoints - pairs of value and timestamp in milliseconds.",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "metadata.Entry": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "Description - human readable description of metric.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name - metric name.",
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Owner - team or person responsible for metric.",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type - expected metric type, empty if any type is allowed.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.MetricsType"
+                        }
+                    ]
+                },
+                "unit": {
+                    "description": "Unit - unit of metric value, for example bytes or seconds.",
+                    "type": "string"
+                }
+            }
+        },
+        "problem.Item": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code - stable error code.",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "Detail - reason of rejection.",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID - metric name.",
+                    "type": "string"
+                },
+                "index": {
+                    "description": "Index - position of item in batch.",
+                    "type": "integer"
+                }
+            }
+        },
+        "problem.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code - stable error code.",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "Detail - explanation of this occurrence of error, internal errors are never exposed.",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors - rejected items of batch request.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.Item"
+                    }
+                },
+                "instance": {
+                    "description": "Instance - path of request.",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status - HTTP status code.",
+                    "type": "integer"
+                },
+                "title": {
+                    "description": "Title - short summary of error code.",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type - URI identifying error code.",
+                    "type": "string"
+                }
+            }
+        },
+        "updating.BatchReport": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied - amount of applied metrics.",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Errors - rejected metrics.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.Item"
+                    }
+                }
+            }
+        },
+        "updating.ValueRequest": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "description": "Delta - value for counter metric, it is added to current value.",
+                    "type": "integer"
+                },
+                "metadata": {
+                    "description": "Metadata - optional metric metadata, not empty fields are set over stored ones.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Metadata"
+                        }
+                    ]
+                },
+                "value": {
+                    "description": "Value - value for gauge metric.",
+                    "type": "number"
+                }
+            }
+        },
+        "viewing.ListResponse": {
+            "type": "object",
+            "properties": {
+                "metrics": {
+                    "description": "Metrics - metrics of page.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Metrics"
+                    }
+                },
+                "next": {
+                    "description": "Next - cursor of next page, absent for the last page.",
+                    "type": "string"
+                }
+            }
+        },
         "viewing.MetricRequest": {
             "type": "object",
             "properties": {
@@ -376,6 +1528,10 @@ const docTemplate = `{
         {
             "description": "\"Request group for updating metrics",
             "name": "Update"
+        },
+        {
+            "description": "\"Request group for versioned metrics API\"",
+            "name": "Metrics"
         }
     ]
 }`